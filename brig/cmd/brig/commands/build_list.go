@@ -123,8 +123,23 @@ func getBuildsForStdout(builds []*brigade.Build) []*buildForStdout {
 
 		bfs.status = "???"
 		bfs.since = "???"
+		switch b.Approval {
+		case brigade.ApprovalPending:
+			bfs.status = "WaitingForApproval"
+		case brigade.ApprovalExpired:
+			bfs.status = "ApprovalExpired"
+		}
 		if b.Worker != nil {
 			bfs.status = b.Worker.Status.String()
+			if b.Worker.TimedOut {
+				bfs.status = "TimedOut"
+			}
+			if b.Worker.WorkspaceSizeExceeded {
+				bfs.status = "WorkspaceSizeExceeded"
+			}
+			if b.RetriedAs != "" {
+				bfs.status = "Retried"
+			}
 			if b.Worker.Status == brigade.JobSucceeded || b.Worker.Status == brigade.JobFailed {
 				bfs.since = duration.ShortHumanDuration(time.Since(b.Worker.StartTime))
 			}
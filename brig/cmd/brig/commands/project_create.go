@@ -163,10 +163,20 @@ func createProject(out io.Writer) error {
 
 	// brig project create --replace
 	if projectCreateReplace {
-		if _, err = store.GetProject(proj.ID); err != nil {
+		current, err := store.GetProject(proj.ID)
+		if err != nil {
 			return fmt.Errorf("project %s could not be found (error: %s). Cannot replace, exiting", proj.Name, err.Error())
 		}
-		return store.ReplaceProject(proj)
+		// A project edited from itself is only replaced if nobody changed it
+		// in the meantime; one copied from another project overwrites it.
+		if proj.ResourceVersion != "" && current.ID != projectCreateFromProject && current.Name != projectCreateFromProject {
+			proj.ResourceVersion = ""
+		}
+		err = store.ReplaceProject(proj)
+		if err == storage.ErrConflict {
+			return fmt.Errorf("project %s was changed while it was being edited. Run the command again to edit the current version", proj.Name)
+		}
+		return err
 	}
 
 	// brig project create # no replace
@@ -48,7 +48,7 @@ func init() {
 	rerun.Flags().BoolVar(&rerunNoProgress, "no-progress", runNoProgress, "Disable progress meter")
 	rerun.Flags().BoolVar(&rerunNoColor, "no-color", runNoColor, "Remove color codes from log output")
 	rerun.Flags().BoolVarP(&rerunBackground, "background", "b", runBackground, "Trigger the event and exit. Let the job rerun in the background.")
-	rerun.Flags().StringVarP(&rerunLogLevel, "level", "l", "log", "Specified log level: log, info, warn, error")
+	rerun.Flags().StringVarP(&rerunLogLevel, "level", "l", "log", "Specified log level: debug, info, warn, error")
 	Root.AddCommand(rerun)
 }
 
@@ -148,9 +148,12 @@ func getUpdatedBuild(r *script.Runner, bid string) (*brigade.Build, error) {
 	}
 
 	// Override a few things
+	build.ParentBuildID = build.ID
 	build.ID = ""
 	build.LogLevel = rerunLogLevel
 	build.Worker = nil
+	build.IdempotencyKey = ""
+	build.FlakyRetry = 0
 
 	return build, nil
 }
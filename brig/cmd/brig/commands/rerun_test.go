@@ -129,6 +129,10 @@ func TestRerun_getUpdatedBuild(t *testing.T) {
 				t.Errorf("expected build.ID to be empty, was: %s", build.ID)
 			}
 
+			if build.ParentBuildID != stubBuild1ID {
+				t.Errorf("expected build.ParentBuildID to be %s, was: %s", stubBuild1ID, build.ParentBuildID)
+			}
+
 			if build.Worker != nil {
 				t.Errorf("expected build.Worker to be %v, was: %v", nil, build.Worker)
 			}
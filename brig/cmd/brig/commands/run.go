@@ -2,12 +2,15 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/decolorizer"
 	"github.com/brigadecore/brigade/pkg/script"
 )
@@ -24,6 +27,8 @@ var (
 	runNoProgress    bool
 	runNoColor       bool
 	runBackground    bool
+	runBuildArgs     []string
+	runPriority      string
 )
 
 const (
@@ -57,6 +62,11 @@ Alternatively to specify it inline, use the '-i' flag:
 
 	$ brig run -i {"key": "value"}
 
+Build arguments are passed to brigade.js as e.buildArgs, overriding the
+project's default build arguments. Use '--arg' once per argument:
+
+	$ brig run --arg suite=integration --arg target=staging brigadecore/empty-testbed
+
 To run the job in the background, use -b/--background. Note, though, that in this
 case the exit code indicates only whether the event was submitted, not whether
 the worker successfully ran to completion.
@@ -73,7 +83,9 @@ func init() {
 	run.Flags().BoolVar(&runNoProgress, "no-progress", false, "Disable progress meter")
 	run.Flags().BoolVar(&runNoColor, "no-color", false, "Remove color codes from log output")
 	run.Flags().BoolVarP(&runBackground, "background", "b", false, "Trigger the event and exit. Let the job run in the background.")
-	run.Flags().StringVarP(&runLogLevel, "level", "l", "log", "Specified log level: log, info, warn, error")
+	run.Flags().StringVarP(&runLogLevel, "level", "l", "log", "Specified log level: debug, info, warn, error")
+	run.Flags().StringArrayVar(&runBuildArgs, "arg", []string{}, "A build argument in the form key=value. May be specified multiple times")
+	run.Flags().StringVar(&runPriority, "priority", "", "The priority of the build: high, normal or low. Defaults to the project's default priority")
 	Root.AddCommand(run)
 }
 
@@ -112,6 +124,18 @@ var run = &cobra.Command{
 			}
 		}
 
+		buildArgs, err := parseBuildArgs(runBuildArgs)
+		if err != nil {
+			return err
+		}
+
+		var priority brigade.BuildPriority
+		if runPriority != "" {
+			if priority, err = brigade.ParseBuildPriority(runPriority); err != nil {
+				return err
+			}
+		}
+
 		var destination io.Writer = os.Stdout
 		if runNoColor {
 			// Pipe the data through a Writer that strips the color codes and then
@@ -132,6 +156,8 @@ var run = &cobra.Command{
 		runner.NoProgress = runNoProgress
 		runner.Background = runBackground
 		runner.Verbose = globalVerbose
+		runner.BuildArgs = buildArgs
+		runner.Priority = priority
 
 		err = runner.SendScript(proj, scr, config, runEvent, runCommitish, runRef, payload, runLogLevel)
 		if err == nil {
@@ -157,3 +183,19 @@ func readFileParam(path string) ([]byte, error) {
 	}
 	return []byte{}, nil
 }
+
+// parseBuildArgs converts a list of key=value pairs into a map of build args.
+func parseBuildArgs(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	args := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("build arg %q must be in the form key=value", pair)
+		}
+		args[parts[0]] = parts[1]
+	}
+	return args, nil
+}
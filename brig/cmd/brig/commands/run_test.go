@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBuildArgs(t *testing.T) {
+	args, err := parseBuildArgs([]string{"suite=integration", "filter=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"suite": "integration", "filter": "a=b", "empty": ""}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+
+	for _, bad := range []string{"suite", "=integration"} {
+		if _, err := parseBuildArgs([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
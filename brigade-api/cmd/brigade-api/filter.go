@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -63,6 +66,62 @@ func NCSACommonLogFormatLogger() restful.FilterFunction {
 	}
 }
 
+// gzipMinSize is the size from which responses are compressed. Smaller
+// responses are not worth the CPU.
+const gzipMinSize = 1024
+
+// GzipFilter compresses the responses of GET requests with the given level
+// when the client accepts gzip and they are at least gzipMinSize bytes long.
+// Other requests, and logs streamed as they are written, are passed through.
+func GzipFilter(level int) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if req.Request.Method != http.MethodGet ||
+			req.QueryParameter("stream") == "true" ||
+			!strings.Contains(req.HeaderParameter("Accept-Encoding"), "gzip") {
+			chain.ProcessFilter(req, resp)
+			return
+		}
+		w := &bufferedResponseWriter{ResponseWriter: resp.ResponseWriter, status: http.StatusOK}
+		resp.ResponseWriter = w
+		chain.ProcessFilter(req, resp)
+		resp.ResponseWriter = w.ResponseWriter
+
+		header := w.Header()
+		header.Add("Vary", "Accept-Encoding")
+		if w.body.Len() < gzipMinSize || header.Get("Content-Encoding") != "" {
+			w.ResponseWriter.WriteHeader(w.status)
+			w.ResponseWriter.Write(w.body.Bytes())
+			return
+		}
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, level)
+		if err != nil {
+			// The level is checked on startup.
+			log.Printf("could not compress response: %s", err)
+			return
+		}
+		gz.Write(w.body.Bytes())
+		gz.Close()
+	}
+}
+
+// bufferedResponseWriter holds a response back until it is complete.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
 func colorForMethod(method string) string {
 	switch method {
 	case "GET":
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+func TestGzipFilter(t *testing.T) {
+	body := strings.Repeat("brigade ", 256)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/big").To(func(req *restful.Request, resp *restful.Response) {
+		resp.Write([]byte(body))
+	}))
+	ws.Route(ws.GET("/small").To(func(req *restful.Request, resp *restful.Response) {
+		resp.WriteHeader(http.StatusAccepted)
+		resp.Write([]byte("ok"))
+	}))
+	ws.Route(ws.POST("/big").To(func(req *restful.Request, resp *restful.Response) {
+		resp.Write([]byte(body))
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+	container.Filter(GzipFilter(gzip.BestSpeed))
+
+	get := func(method, path, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(http.MethodGet, "/big", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped response, got headers %v", w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadAll(gz); err != nil || string(data) != body {
+		t.Errorf("expected the body to survive compression, got %d bytes, %v", len(data), err)
+	}
+
+	for _, tt := range []struct {
+		method, path, encoding string
+		status                 int
+	}{
+		{http.MethodGet, "/big", "", http.StatusOK},
+		{http.MethodGet, "/small", "gzip", http.StatusAccepted},
+		{http.MethodPost, "/big", "gzip", http.StatusOK},
+	} {
+		w := get(tt.method, tt.path, tt.encoding)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s %s: expected an uncompressed response", tt.method, tt.path)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
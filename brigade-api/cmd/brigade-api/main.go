@@ -1,11 +1,13 @@
 package main
 
 import (
+	"compress/gzip"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/brigadecore/brigade/pkg/api"
 	"github.com/brigadecore/brigade/pkg/brigade"
@@ -39,10 +41,21 @@ type jobService struct {
 }
 
 type buildService struct {
-	server api.API
+	server     api.API
+	adminToken string
 }
 
 type projectService struct {
+	server     api.API
+	adminToken string
+}
+
+type reportService struct {
+	server api.API
+	rates  api.CostRates
+}
+
+type metricsService struct {
 	server api.API
 }
 
@@ -98,6 +111,51 @@ func (bs buildService) WebService() *restful.WebService {
 		Returns(200, "OK", brigade.Build{}).
 		Returns(404, "Not Found", nil))
 
+	rr := bs.server.Rerun(bs.adminToken)
+
+	ws.Route(ws.POST("/{id}/rerun").To(rr.Create).
+		Doc("rerun a build").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(brigade.Build{}).
+		Returns(201, "Created", brigade.Build{}).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{id}/diff").To(b.Diff).
+		Doc("compare the environment snapshots of two builds").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.QueryParameter("compare", "id of the build to compare with").DataType("string").Required(true)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(api.BuildDiff{}).
+		Returns(200, "OK", api.BuildDiff{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	a := bs.server.Approval(bs.adminToken)
+
+	ws.Route(ws.GET("/{id}/approval").To(a.Get).
+		Doc("get the approval status of a build").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(api.BuildApproval{}).
+		Returns(200, "OK", api.BuildApproval{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{id}/approval").To(a.Approve).
+		Doc("approve a build waiting for approval").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(api.BuildApproval{}).
+		Returns(200, "OK", api.BuildApproval{}).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Conflict", nil))
+
 	ws.Route(ws.GET("/{id}/jobs").To(b.Jobs).
 		Doc("get jobs of a build").
 		Param(ws.PathParameter("id", "id of the build").DataType("string")).
@@ -143,6 +201,24 @@ func (ps projectService) WebService() *restful.WebService {
 		Returns(200, "OK", brigade.Project{}).
 		Returns(404, "Not Found", nil))
 
+	pa := ps.server.ProjectAdmin(ps.adminToken)
+
+	ws.Route(ws.PUT("/project/{id}").To(pa.Replace).
+		Doc("replace a project, if it has not changed since it was read").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Param(ws.HeaderParameter("If-Match", "the ETag of the project, as returned by GET").DataType("string").Required(true)).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(brigade.Project{}).
+		Writes(brigade.Project{}).
+		Returns(200, "OK", brigade.Project{}).
+		Returns(400, "Bad Request", nil).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Conflict", nil).
+		Returns(428, "Precondition Required", nil))
+
 	ws.Route(ws.GET("/project/{id}/builds").To(p.Builds).
 		Doc("get list of builds for a project").
 		Param(ws.PathParameter("id", "id of the project").DataType("string")).
@@ -151,6 +227,49 @@ func (ps projectService) WebService() *restful.WebService {
 		Returns(200, "OK", []brigade.Build{}).
 		Returns(404, "Not Found", nil))
 
+	tr := ps.server.Trigger(ps.adminToken)
+
+	ws.Route(ws.POST("/project/{id}/builds").To(tr.Create).
+		Doc("create a build for a project").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Param(ws.HeaderParameter(api.IdempotencyKeyHeader, "key that makes retries of the request return the same build").DataType("string")).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(api.BuildRequest{}).
+		Writes(brigade.Build{}).
+		Returns(201, "Created", brigade.Build{}).
+		Returns(200, "OK", brigade.Build{}).
+		Returns(400, "Bad Request", nil).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
+	rb := ps.server.Rollback(ps.adminToken)
+
+	ws.Route(ws.POST("/project/{id}/rollback").To(rb.Create).
+		Doc("roll an environment back to the commit of its previous successful deployment").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Param(ws.QueryParameter("env", "environment to roll back").DataType("string").Required(true)).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(brigade.Build{}).
+		Returns(201, "Created", brigade.Build{}).
+		Returns(400, "Bad Request", nil).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/project/{id}/cache/invalidate").To(pa.InvalidateCache).
+		Doc("reload a project into the API's project cache").
+		Param(ws.PathParameter("id", "id or name of the project").DataType("string")).
+		Param(ws.HeaderParameter("Authorization", "the admin token, as a bearer token").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(brigade.Project{}).
+		Returns(200, "OK", brigade.Project{}).
+		Returns(401, "Unauthorized", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
 	ws.Route(ws.GET("/projects-build").To(p.ListWithLatestBuild).
 		Doc("lists the projects with the latest builds attached.").
 		Metadata(restfulspec.KeyOpenAPITags, tags).
@@ -158,6 +277,55 @@ func (ps projectService) WebService() *restful.WebService {
 		Returns(200, "OK", []api.ProjectBuildSummary{}).
 		Returns(404, "Not Found", nil))
 
+	// Image proxies ask for image/*, which go-restful does not match
+	// against image/svg+xml, so the badge is served for any Accept header.
+	ws.Route(ws.GET("/badge/{id}/{branch:*}").To(p.Badge).
+		Doc("get an SVG badge for the latest build of a branch").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Param(ws.PathParameter("branch", "branch name, followed by .svg").DataType("string")).
+		Produces("image/svg+xml", "*/*").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", nil))
+
+	return ws
+}
+
+func (rs reportService) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	r := rs.server.Report(rs.rates)
+
+	ws.
+		Path("/v1/reports").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"reports"}
+
+	ws.Route(ws.GET("/costs").To(r.Costs).
+		Doc("get the estimated cost of a month's builds by project and cost center").
+		Param(ws.QueryParameter("period", "month of the report, such as 2020-01").DataType("string").Required(true)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(api.CostReport{}).
+		Returns(200, "OK", api.CostReport{}).
+		Returns(400, "Bad Request", nil))
+
+	return ws
+}
+
+func (ms metricsService) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.
+		Path("/metrics").
+		Produces("text/plain", "*/*")
+
+	tags := []string{"metrics"}
+
+	ws.Route(ws.GET("/").To(ms.server.Metrics).
+		Doc("get metrics in the Prometheus text format").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", nil))
+
 	return ws
 }
 
@@ -196,15 +364,20 @@ func main() {
 	storageServer := api.New(storage)
 
 	j := jobService{server: storageServer}
-	b := buildService{server: storageServer}
-	p := projectService{server: storageServer}
+	b := buildService{server: storageServer, adminToken: os.Getenv("BRIGADE_API_ADMIN_TOKEN")}
+	p := projectService{server: storageServer, adminToken: os.Getenv("BRIGADE_API_ADMIN_TOKEN")}
+	r := reportService{server: storageServer, rates: costRates()}
+	m := metricsService{server: storageServer}
 	h := healthService{}
 
 	restful.DefaultContainer.Add(j.WebService())
 	restful.DefaultContainer.Add(b.WebService())
 	restful.DefaultContainer.Add(p.WebService())
+	restful.DefaultContainer.Add(r.WebService())
+	restful.DefaultContainer.Add(m.WebService())
 	restful.DefaultContainer.Add(h.WebService())
 	restful.DefaultContainer.Filter(NCSACommonLogFormatLogger())
+	restful.DefaultContainer.Filter(GzipFilter(gzipLevel()))
 
 	config := restfulspec.Config{
 		WebServices:                   restful.RegisteredWebServices(),
@@ -213,7 +386,7 @@ func main() {
 	restful.DefaultContainer.Add(restfulspec.NewOpenAPIService(config))
 
 	cors := restful.CrossOriginResourceSharing{
-		AllowedHeaders: []string{"Content-Type", "Accept"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
 		CookiesAllowed: false,
 		Container:      restful.DefaultContainer}
@@ -233,6 +406,42 @@ func defaultNamespace() string {
 	return v1.NamespaceDefault
 }
 
+// costRates reads the prices of the cost report from the environment. Costs
+// are estimated as zero unless they are set.
+func costRates() api.CostRates {
+	var rates api.CostRates
+	for name, rate := range map[string]*float64{
+		"BRIGADE_CPU_COST_PER_SECOND":    &rates.CPUPerSecond,
+		"BRIGADE_MEM_COST_PER_GB_SECOND": &rates.MemoryPerGBSecond,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				log.Fatalf("invalid %s %q: %s", name, v, err)
+			}
+			*rate = f
+		}
+	}
+	return rates
+}
+
+// gzipLevel reads the compression level of responses from the environment.
+// It defaults to gzip.BestSpeed.
+func gzipLevel() int {
+	v, ok := os.LookupEnv("BRIGADE_GZIP_LEVEL")
+	if !ok {
+		return gzip.BestSpeed
+	}
+	level, err := strconv.Atoi(v)
+	if err == nil {
+		_, err = gzip.NewWriterLevel(nil, level)
+	}
+	if err != nil {
+		log.Fatalf("invalid BRIGADE_GZIP_LEVEL %q: %s", v, err)
+	}
+	return level
+}
+
 func defaultAPIPort() string {
 	if port, ok := os.LookupEnv("BRIGADE_API_PORT"); ok {
 		return port
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// approvalPollInterval is how often the controller checks whether a build
+// waiting for approval was approved.
+const approvalPollInterval = 10 * time.Second
+
+// awaitApproval returns true if the build must not run yet because it waits
+// for approval, or will never run because its approval expired.
+//
+// Deployment builds of projects that require approval are marked pending
+// and checked again every approvalPollInterval until they are approved or
+// the project's approval timeout passes, in which case they are marked
+// expired.
+func (c *Controller) awaitApproval(build, project *v1.Secret) (bool, error) {
+	if string(build.Data["event_type"]) != "deployment" || string(project.Data["requiresApproval"]) != "true" {
+		return false, nil
+	}
+	switch brigade.ApprovalStatus(build.Annotations[kube.ApprovalAnnotation]) {
+	case brigade.ApprovalApproved:
+		return false, nil
+	case brigade.ApprovalExpired:
+		return true, nil
+	}
+
+	timeout := brigade.DefaultApprovalTimeout
+	if t := project.Data["approvalTimeout"]; len(t) > 0 {
+		if d, err := time.ParseDuration(string(t)); err == nil && d > 0 {
+			timeout = d
+		} else {
+			log.Printf("Warning: ignoring invalid 'approvalTimeout' %q of project %s", t, project.Name)
+		}
+	}
+
+	status := brigade.ApprovalPending
+	if time.Since(build.CreationTimestamp.Time) > timeout {
+		log.Printf("Build %s was not approved within %s and will not run", build.Labels["build"], timeout)
+		status = brigade.ApprovalExpired
+	}
+	if err := c.setApproval(build, status); err != nil {
+		return true, err
+	}
+	if status == brigade.ApprovalPending {
+		key, err := cache.MetaNamespaceKeyFunc(build)
+		if err != nil {
+			return true, err
+		}
+		c.queue.AddAfter(key, buildPriority(build), approvalPollInterval)
+	}
+	return true, nil
+}
+
+// setApproval records the approval status on the build secret, unless it is
+// already recorded.
+func (c *Controller) setApproval(build *v1.Secret, status brigade.ApprovalStatus) error {
+	if build.Annotations[kube.ApprovalAnnotation] == string(status) {
+		return nil
+	}
+	buildCopy := build.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.ApprovalAnnotation] = string(status)
+	_, err := c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestSyncSecret_Approval(t *testing.T) {
+	project := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data: map[string][]byte{
+			"requiresApproval": []byte("true"),
+			"approvalTimeout":  []byte("1h"),
+		},
+	}
+	newBuild := func(eventType string, age time.Duration, approval brigade.ApprovalStatus) *v1.Secret {
+		build := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "moby",
+				Namespace:         v1.NamespaceDefault,
+				Labels:            map[string]string{"build": "queequeg", "project": "ahab"},
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Data: map[string][]byte{"event_type": []byte(eventType)},
+		}
+		if approval != "" {
+			build.Annotations = map[string]string{kube.ApprovalAnnotation: string(approval)}
+		}
+		return build
+	}
+
+	for _, tt := range []struct {
+		name      string
+		build     *v1.Secret
+		wantPod   bool
+		wantState brigade.ApprovalStatus
+	}{
+		{"other events run", newBuild("push", 0, ""), true, ""},
+		{"deployments wait", newBuild("deployment", 0, ""), false, brigade.ApprovalPending},
+		{"approved deployments run", newBuild("deployment", 0, brigade.ApprovalApproved), true, brigade.ApprovalApproved},
+		{"deployments expire", newBuild("deployment", 2*time.Hour, brigade.ApprovalPending), false, brigade.ApprovalExpired},
+		{"expired deployments never run", newBuild("deployment", 2*time.Hour, brigade.ApprovalExpired), false, brigade.ApprovalExpired},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.build, project)
+			controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+			defer controller.queue.ShutDown()
+
+			if err := controller.syncSecret(tt.build); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.CoreV1().Pods(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+			if gotPod := err == nil; gotPod != tt.wantPod {
+				t.Errorf("expected a worker pod: %t, got one: %t", tt.wantPod, gotPod)
+			}
+			build, _ := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+			if got := brigade.ApprovalStatus(build.Annotations[kube.ApprovalAnnotation]); got != tt.wantState {
+				t.Errorf("expected approval %q, got %q", tt.wantState, got)
+			}
+		})
+	}
+}
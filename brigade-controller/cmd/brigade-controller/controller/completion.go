@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// workerBuild is the build run by a worker pod, with its project.
+type workerBuild struct {
+	secret        *v1.Secret
+	projectSecret *v1.Secret
+	project       *brigade.Project
+	worker        *v1.Pod
+	// rerun is set once a flaky build was rerun, and ends its completion.
+	rerun bool
+}
+
+// loadWorkerBuild loads the build run by the worker pod and its project.
+func (c *Controller) loadWorkerBuild(pod *v1.Pod) (*workerBuild, error) {
+	secrets := c.clientset.CoreV1().Secrets(c.Namespace)
+	// The worker pod is named after its build secret.
+	buildSecret, err := secrets.Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not load build for worker %s: %s", pod.Name, err)
+	}
+	projectSecret, err := secrets.Get(context.TODO(), buildSecret.Labels["project"], metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not load project for worker %s: %s", pod.Name, err)
+	}
+	project, err := kube.NewProjectFromSecret(projectSecret, c.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not load project for worker %s: %s", pod.Name, err)
+	}
+	return &workerBuild{secret: buildSecret, projectSecret: projectSecret, project: project, worker: pod}, nil
+}
+
+// completionStep is one of the things done once for every build whose worker
+// completed.
+type completionStep struct {
+	// what names the step in errors, as in "could not <what> of worker moby".
+	what string
+	run  func(*workerBuild) error
+}
+
+// completionSteps returns the steps of completing a build, in order. The
+// build's resource usage, environment snapshot and any duration regression
+// are recorded before a build that failed in a way known to be flaky is
+// rerun, and only the result of a flaky build's last attempt is reported.
+func (c *Controller) completionSteps() []completionStep {
+	return []completionStep{
+		{"start the post-build script", func(b *workerBuild) error {
+			go c.runPostBuildScript(b.secret, b.projectSecret, b.worker)
+			return nil
+		}},
+		{"start the postlude", func(b *workerBuild) error {
+			go c.runPostlude(b.secret, b.projectSecret, b.worker)
+			return nil
+		}},
+		{"record resource usage", func(b *workerBuild) error {
+			return c.recordResourceUsage(b.secret, b.worker, b.project)
+		}},
+		{"record the environment snapshot", func(b *workerBuild) error {
+			return c.recordEnvironmentSnapshot(b.secret, b.projectSecret, b.worker)
+		}},
+		{"compare the duration", func(b *workerBuild) error {
+			return c.recordDurationRegression(b.secret, b.worker, b.project)
+		}},
+		{"retry the flaky build", func(b *workerBuild) (err error) {
+			b.rerun, err = c.retryFlakyBuild(b.secret, b.project, b.worker)
+			return err
+		}},
+		{"notify", func(b *workerBuild) error {
+			c.dispatchNotifications(b.secret, b.project, b.worker)
+			return nil
+		}},
+	}
+}
+
+// completeBuild runs the completion steps of the build of a completed worker,
+// unless they already ran. A failed step is logged, and the next one runs on
+// the build as it is stored.
+func (c *Controller) completeBuild(pod *v1.Pod) {
+	build, err := c.loadWorkerBuild(pod)
+	if err != nil {
+		log.Printf("complete: %s", err)
+		return
+	}
+	if claimed, err := c.claimCompletion(build.secret); err != nil {
+		log.Printf("complete: could not mark the build of worker %s completed: %s", pod.Name, err)
+		return
+	} else if !claimed {
+		return
+	}
+
+	secrets := c.clientset.CoreV1().Secrets(c.Namespace)
+	for _, step := range c.completionSteps() {
+		if build.secret, err = secrets.Get(context.TODO(), pod.Name, metav1.GetOptions{}); err != nil {
+			log.Printf("complete: could not reload build for worker %s: %s", pod.Name, err)
+			return
+		}
+		if err := step.run(build); err != nil {
+			log.Printf("complete: could not %s of worker %s: %s", step.what, pod.Name, err)
+		}
+		if build.rerun {
+			return
+		}
+	}
+}
+
+// claimAttempts is how often claiming a completion is attempted while other
+// updates to the build secret conflict with it.
+const claimAttempts = 3
+
+// claimCompletion records on the build secret that the build's completion
+// is being processed. It returns false if it already was, so that the
+// completion steps of each build run at most once, even across restarts of
+// the controller.
+func (c *Controller) claimCompletion(build *v1.Secret) (bool, error) {
+	secrets := c.clientset.CoreV1().Secrets(build.Namespace)
+	var err error
+	for i := 0; i < claimAttempts; i++ {
+		if i > 0 {
+			// Another update got there first: look at the secret again.
+			if build, err = secrets.Get(context.TODO(), build.Name, metav1.GetOptions{}); err != nil {
+				return false, err
+			}
+		}
+		if build.Annotations[kube.CompletedAnnotation] != "" {
+			return false, nil
+		}
+		buildCopy := build.DeepCopy()
+		if buildCopy.Annotations == nil {
+			buildCopy.Annotations = map[string]string{}
+		}
+		buildCopy.Annotations[kube.CompletedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		if _, err = secrets.Update(context.TODO(), buildCopy, metav1.UpdateOptions{}); !apierrors.IsConflict(err) {
+			return err == nil, err
+		}
+	}
+	return false, err
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// completionFixture returns a completed worker, its build and project, and a
+// controller whose project notifies the returned counter.
+func completionFixture(t *testing.T) (*Controller, *v1.Pod, *int32) {
+	var notified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&notified, 1)
+	}))
+	t.Cleanup(srv.Close)
+
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"build": "queequeg", "project": "ahab"},
+		},
+	}
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"notifications.slack.webhookURL": []byte(srv.URL)},
+	}
+	worker := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "build": "queequeg"},
+		},
+		Status: v1.PodStatus{Phase: v1.PodSucceeded, StartTime: &meta.Time{Time: time.Now()}},
+	}
+	client := fake.NewSimpleClientset(build, project, worker)
+	return NewController(client, &Config{Namespace: v1.NamespaceDefault}), worker, &notified
+}
+
+func TestCompleteBuild_Once(t *testing.T) {
+	controller, worker, notified := completionFixture(t)
+
+	controller.completeBuild(worker)
+	controller.completeBuild(worker)
+
+	if n := atomic.LoadInt32(notified); n != 1 {
+		t.Errorf("expected the build to be reported once, got %d notifications", n)
+	}
+	build, err := controller.clientset.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.Annotations[kube.CompletedAnnotation] == "" {
+		t.Error("expected the build to be marked completed")
+	}
+	if build.Annotations[kube.CPUSecondsAnnotation] == "" {
+		t.Error("expected the resource usage to be recorded")
+	}
+}
+
+func TestWorkerInformer_CompletesListedWorkers(t *testing.T) {
+	// The worker completed before the informer started, as it does while the
+	// controller restarts.
+	controller, _, notified := completionFixture(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.workerInformer.Run(stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(notified) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the listed worker's build to be completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -11,6 +11,10 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/brigadecore/brigade/pkg/notify"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
 )
 
 const (
@@ -35,26 +39,50 @@ type Config struct {
 	WorkerLimitsMemory         string
 	DefaultBuildStorageClass   string
 	DefaultCacheStorageClass   string
+	HTTPProxy                  string
+	HTTPSProxy                 string
+	SMTP                       notify.SMTPConfig
+	// PreludeScript and PostludeScript are shell scripts run around the
+	// builds of every project. See NewWorkerPod and NewPostludePod.
+	PreludeScript string
+	// PreludeBlocking fails builds whose prelude fails.
+	PreludeBlocking bool
+	PostludeScript  string
 }
 
 // Controller listens for new brigade builds and starts the worker pods.
 type Controller struct {
 	*Config
 	indexer  cache.Indexer
-	queue    workqueue.RateLimitingInterface
+	queue    *priorityQueue
 	informer cache.Controller
 
+	workerIndexer  cache.Indexer
+	workerInformer cache.Controller
+	notifiers      *notify.Dispatcher
+	webhooks       *notify.Webhook
+
 	clientset kubernetes.Interface
+	// store creates the builds the controller reruns.
+	store storage.Store
 }
 
 // NewController creates a new Controller.
 func NewController(clientset kubernetes.Interface, config *Config) *Controller {
 	c := &Controller{
 		clientset: clientset,
+		store:     kube.New(clientset, config.Namespace),
 		Config:    config,
-		queue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		queue:     newPriorityQueue(),
+		webhooks:  notify.NewWebhook(),
 	}
+	c.notifiers = notify.NewDispatcher(map[string]notify.Notifier{
+		"chat":     notify.NewChat(notify.NewMemoryResultStore()),
+		"email":    notify.NewEmail(config.SMTP, notify.NewMemoryResultStore(), c.workerLogTail),
+		"webhooks": c.webhooks,
+	})
 	c.createIndexerInformer()
+	c.createWorkerInformer()
 	return c
 }
 
@@ -69,20 +97,21 @@ func (c *Controller) getSecret(key string) (*v1.Secret, bool, error) {
 }
 
 func (c *Controller) processNextItem() bool {
-	// Wait until there is a new item in the working queue
-	key, quit := c.queue.Get()
-	if quit {
+	// Wait until there is a new item in the working queue, taking the
+	// highest priority one first.
+	key, queue := c.queue.Get()
+	if queue == nil {
 		return false
 	}
 	// Tell the queue that we are done with processing this key. This unblocks the key for other workers
 	// This allows safe parallel processing because two secrets with the same key are never processed in
 	// parallel.
-	defer c.queue.Done(key)
+	defer queue.Done(key)
 
 	// Invoke the method containing the business logic
 	err := c.sync(key.(string))
 	// Handle the error if something went wrong during the execution of the business logic
-	c.handleErr(err, key)
+	c.handleErr(err, key, queue)
 	return true
 }
 
@@ -112,26 +141,26 @@ func (c *Controller) sync(key string) error {
 }
 
 // handleErr checks if an error happened and makes sure we will retry later.
-func (c *Controller) handleErr(err error, key interface{}) {
+func (c *Controller) handleErr(err error, key interface{}, queue workqueue.RateLimitingInterface) {
 	if err == nil {
 		// Forget about the #AddRateLimited history of the key on every successful synchronization.
 		// This ensures that future processing of updates for this key is not delayed because of
 		// an outdated error history.
-		c.queue.Forget(key)
+		queue.Forget(key)
 		return
 	}
 
 	// This controller retries 5 times if something goes wrong. After that, it stops trying.
-	if c.queue.NumRequeues(key) < 5 {
+	if queue.NumRequeues(key) < 5 {
 		log.Printf("Error syncing secret %v: %v", key, err)
 
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
-		c.queue.AddRateLimited(key)
+		queue.AddRateLimited(key)
 		return
 	}
 
-	c.queue.Forget(key)
+	queue.Forget(key)
 	// Report to an external entity that, even after several retries, we could not successfully process this key
 	utilruntime.HandleError(err)
 	log.Printf("Dropping secret %q out of the queue: %v", key, err)
@@ -146,9 +175,10 @@ func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
 	log.Print("Starting Secret controller")
 
 	go c.informer.Run(stopCh)
+	go c.workerInformer.Run(stopCh)
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
-	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.HasSynced, c.workerInformer.HasSynced) {
 		utilruntime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 		return
 	}
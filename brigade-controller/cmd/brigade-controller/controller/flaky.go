@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// flakyLogTailLines is the number of log lines of each failed pod searched
+// for the project's flaky patterns.
+const flakyLogTailLines = 200
+
+var flakyRetrySuffix = regexp.MustCompile(` \(flaky retry \d+/\d+\)$`)
+
+// retryFlakyBuild reruns the build of a failed worker if the logs of the
+// worker or of the build's failed jobs match one of the project's flaky
+// patterns, and the build has been retried fewer than FlakyRetryCount times.
+// It returns whether the build was rerun.
+func (c *Controller) retryFlakyBuild(buildSecret *v1.Secret, project *brigade.Project, worker *v1.Pod) (bool, error) {
+	if worker.Status.Phase != v1.PodFailed || len(project.FlakyPatterns) == 0 {
+		return false, nil
+	}
+	build := kube.NewBuildFromSecret(*buildSecret)
+	if build.FlakyRetry >= project.FlakyRetryCount || build.RetriedAs != "" {
+		return false, nil
+	}
+	logs, err := c.failureLogs(build.ID, worker, project)
+	if err != nil {
+		return false, err
+	}
+	return c.rerunFlakyBuild(buildSecret, project, logs)
+}
+
+// rerunFlakyBuild reruns the build if the logs match one of the project's
+// flaky patterns. The rerun's short title is tagged with its attempt, and
+// the failed build records the ID of its rerun.
+func (c *Controller) rerunFlakyBuild(buildSecret *v1.Secret, project *brigade.Project, logs string) (bool, error) {
+	pattern := matchFlakyPattern(project.FlakyPatterns, logs)
+	if pattern == "" {
+		return false, nil
+	}
+	build := kube.NewBuildFromSecret(*buildSecret)
+
+	retry := *build
+	retry.ID = ""
+	retry.ParentBuildID = build.ID
+	retry.FlakyRetry = build.FlakyRetry + 1
+	// The retry was not requested with the build's idempotency key.
+	retry.IdempotencyKey = ""
+	title := flakyRetrySuffix.ReplaceAllString(build.ShortTitle, "")
+	retry.ShortTitle = strings.TrimSpace(fmt.Sprintf("%s (flaky retry %d/%d)", title, retry.FlakyRetry, project.FlakyRetryCount))
+	if err := c.store.CreateBuild(&retry); err != nil {
+		return false, err
+	}
+	log.Printf("build %s failed matching flaky pattern %q; rerunning it as %s (flaky retry %d/%d)",
+		build.ID, pattern, retry.ID, retry.FlakyRetry, project.FlakyRetryCount)
+
+	buildCopy := buildSecret.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.RetriedAsAnnotation] = retry.ID
+	_, err := c.clientset.CoreV1().Secrets(buildSecret.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return true, err
+}
+
+// matchFlakyPattern returns the first of the patterns that matches the logs,
+// or an empty string if none does. Invalid patterns are skipped.
+func matchFlakyPattern(patterns []string, logs string) string {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid flaky pattern %q: %s", pattern, err)
+			continue
+		}
+		if re.MatchString(logs) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// failureLogs returns the last lines of the logs of the worker and of the
+// build's failed job pods, which run in the project's job namespace.
+func (c *Controller) failureLogs(buildID string, worker *v1.Pod, project *brigade.Project) (string, error) {
+	pods := []*v1.Pod{worker}
+	selector := "heritage=brigade,component=job,build=" + buildID
+	jobs, err := c.clientset.CoreV1().Pods(project.Kubernetes.JobPodNamespace()).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", err
+	}
+	for i := range jobs.Items {
+		if jobs.Items[i].Status.Phase == v1.PodFailed {
+			pods = append(pods, &jobs.Items[i])
+		}
+	}
+
+	var logs strings.Builder
+	tail := int64(flakyLogTailLines)
+	for _, pod := range pods {
+		stream, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &tail}).Stream(context.TODO())
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(stream)
+		stream.Close()
+		if err != nil {
+			return "", err
+		}
+		logs.Write(data)
+	}
+	return logs.String(), nil
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestMatchFlakyPattern(t *testing.T) {
+	patterns := []string{"(", "ECONNRESET", `timeout after \d+s`}
+	for logs, want := range map[string]string{
+		"npm ERR! network read ECONNRESET": "ECONNRESET",
+		"dial tcp: timeout after 30s":      `timeout after \d+s`,
+		"FAIL: TestWhale":                  "",
+	} {
+		if got := matchFlakyPattern(patterns, logs); got != want {
+			t.Errorf("expected %q to match %q, got %q", logs, want, got)
+		}
+	}
+}
+
+func TestRerunFlakyBuild(t *testing.T) {
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "brigade-worker-queequeg",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"build": "queequeg", "project": "pequod"},
+		},
+		Data: map[string][]byte{
+			"short_title": []byte("Fix harpoon (flaky retry 1/2)"),
+			"commit_id":   []byte("abc123"),
+			"flaky_retry": []byte("1"),
+		},
+	}
+	project := &brigade.Project{FlakyPatterns: []string{"ECONNRESET"}, FlakyRetryCount: 2}
+	client := fake.NewSimpleClientset(build)
+	controller := &Controller{
+		Config:    &Config{Namespace: v1.NamespaceDefault},
+		clientset: client,
+		store:     kube.New(client, v1.NamespaceDefault),
+	}
+
+	if retried, err := controller.rerunFlakyBuild(build, project, "FAIL: TestWhale"); err != nil || retried {
+		t.Fatalf("expected a genuine failure not to be rerun, got %t, %v", retried, err)
+	}
+
+	retried, err := controller.rerunFlakyBuild(build, project, "npm ERR! network read ECONNRESET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retried {
+		t.Fatal("expected a flaky failure to be rerun")
+	}
+	secrets := client.CoreV1().Secrets(v1.NamespaceDefault)
+	original, err := secrets.Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rerunID := original.Annotations[kube.RetriedAsAnnotation]
+	if rerunID == "" {
+		t.Fatal("expected the failed build to record its rerun")
+	}
+	rerun, err := secrets.Get(context.TODO(), "brigade-worker-"+rerunID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"parent_build_id": "queequeg",
+		"flaky_retry":     "2",
+		"short_title":     "Fix harpoon (flaky retry 2/2)",
+		"commit_id":       "abc123",
+	} {
+		if got := rerun.StringData[key]; got != want {
+			t.Errorf("expected the rerun's %s to be %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestRetryFlakyBuild_Exhausted(t *testing.T) {
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"build": "queequeg"}},
+		Data:       map[string][]byte{"flaky_retry": []byte("2")},
+	}
+	project := &brigade.Project{FlakyPatterns: []string{"ECONNRESET"}, FlakyRetryCount: 2}
+	controller := &Controller{Config: &Config{}, clientset: fake.NewSimpleClientset()}
+	worker := &v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed}}
+
+	// Retries are exhausted before any logs are read.
+	if retried, err := controller.retryFlakyBuild(build, project, worker); err != nil || retried {
+		t.Errorf("expected a build out of retries not to be rerun, got %t, %v", retried, err)
+	}
+}
+
+func TestFailureLogs_JobNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var listedIn string
+	client.PrependReactor("list", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		listedIn = action.GetNamespace()
+		// Stop before reading logs, which the fake clientset cannot serve.
+		return true, nil, errors.New("stop")
+	})
+	controller := &Controller{Config: &Config{Namespace: v1.NamespaceDefault}, clientset: client}
+	worker := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "moby", Namespace: v1.NamespaceDefault}}
+	project := &brigade.Project{Kubernetes: brigade.Kubernetes{Namespace: "builds", JobNamespace: "jobs"}}
+
+	controller.failureLogs("queequeg", worker, project)
+	if listedIn != "jobs" {
+		t.Errorf("expected job pods to be listed in the job namespace, got %q", listedIn)
+	}
+}
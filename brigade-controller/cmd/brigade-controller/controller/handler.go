@@ -2,13 +2,16 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -60,7 +63,16 @@ func (c *Controller) syncSecret(build *v1.Secret) error {
 			return err
 		}
 
+		if waiting, err := c.awaitApproval(build, project); waiting || err != nil {
+			return err
+		}
+
 		pod := NewWorkerPod(build, project, c.Config)
+		if env, err := c.previousBuildEnv(build); err != nil {
+			log.Printf("Warning: could not find the build before %s: %s", build.Name, err)
+		} else {
+			pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, env...)
+		}
 		if _, err := podClient.Create(context.TODO(), &pod, metav1.CreateOptions{}); err != nil {
 			return err
 		}
@@ -73,6 +85,10 @@ func (c *Controller) syncSecret(build *v1.Secret) error {
 func (c *Controller) updateBuildStatus(build *v1.Secret) error {
 	buildCopy := build.DeepCopy()
 	buildCopy.Labels["status"] = "accepted"
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.AcceptedTimeAnnotation] = time.Now().UTC().Format(time.RFC3339)
 	_, err := c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
 	return err
 }
@@ -127,11 +143,32 @@ func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 			EmptyDir: &v1.EmptyDirVolumeSource{},
 		},
 	}
+	// The kubelet evicts a worker whose checkout grows past the project's
+	// workspace size limit.
+	if limit := project.Data["workspaceSizeLimitMB"]; len(limit) > 0 {
+		if mb, err := strconv.Atoi(string(limit)); err == nil && mb >= 0 {
+			if mb > 0 {
+				sidecarVolume.EmptyDir.SizeLimit = apiresource.NewQuantity(int64(mb)*1024*1024, apiresource.BinarySI)
+			}
+		} else {
+			log.Printf("Warning: ignoring invalid 'workspaceSizeLimitMB' %q of project %s", limit, project.Name)
+		}
+	}
 	volumes = append(volumes, buildVolume, projectVolume)
 
 	initContainers := []v1.Container{}
 	// Only add the sidecar resources if sidecar pod image is supplied.
 	if image := project.Data["vcsSidecar"]; len(image) > 0 {
+		// Only git clones through the proxies: the worker talks to the
+		// Kubernetes API directly.
+		sidecarEnv := append([]v1.EnvVar{}, env...)
+		httpProxy, httpsProxy := vcsProxies(project, config)
+		if httpProxy != "" {
+			sidecarEnv = append(sidecarEnv, v1.EnvVar{Name: "http_proxy", Value: httpProxy})
+		}
+		if httpsProxy != "" {
+			sidecarEnv = append(sidecarEnv, v1.EnvVar{Name: "https_proxy", Value: httpsProxy})
+		}
 		volumeMounts = append(volumeMounts, sidecarVolumeMount)
 		volumes = append(volumes, sidecarVolume)
 		initContainers = append(initContainers,
@@ -140,11 +177,30 @@ func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 				Image:           string(image),
 				ImagePullPolicy: v1.PullPolicy(pullPolicy),
 				VolumeMounts:    []v1.VolumeMount{sidecarVolumeMount},
-				Env:             env,
+				Env:             sidecarEnv,
 				Resources:       vcsSidecarResources(project),
 			})
 	}
 
+	// The operator's prelude runs after the checkout and before the worker
+	// loads the script, so scripts cannot skip it.
+	if config.PreludeScript != "" {
+		cmd := []string{"/bin/sh", "-c", config.PreludeScript}
+		if !config.PreludeBlocking {
+			cmd = []string{"/bin/sh", "-c", `/bin/sh -c "$BRIGADE_PRELUDE_SCRIPT" || echo "Warning: the prelude script failed with exit code $?" >&2`}
+		}
+		initContainers = append(initContainers,
+			v1.Container{
+				Name:            "prelude",
+				Image:           image,
+				ImagePullPolicy: v1.PullPolicy(pullPolicy),
+				Command:         cmd,
+				VolumeMounts:    volumeMounts,
+				Env:             append(append([]v1.EnvVar{}, env...), v1.EnvVar{Name: "BRIGADE_PRELUDE_SCRIPT", Value: config.PreludeScript}),
+				Resources:       workerResources(config),
+			})
+	}
+
 	spec := v1.PodSpec{
 		ServiceAccountName: config.WorkerServiceAccount,
 		NodeSelector: map[string]string{
@@ -172,16 +228,19 @@ func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 		attachConfigMap(&spec, string(configName), "/etc/brigade-default-config")
 	}
 
-	if ips := project.Data["imagePullSecrets"]; len(ips) > 0 {
-		pullSecs := strings.Split(string(ips), ",")
-		refs := []v1.LocalObjectReference{}
-		for _, pullSec := range pullSecs {
-			ref := v1.LocalObjectReference{Name: strings.TrimSpace(pullSec)}
-			refs = append(refs, ref)
+	// A worker stuck in its script, for instance in an endless loop, is
+	// stopped by Kubernetes once the project's build timeout passes.
+	if timeout := project.Data["buildTimeout"]; len(timeout) > 0 {
+		if d, err := time.ParseDuration(string(timeout)); err == nil && d > 0 {
+			seconds := int64(math.Ceil(d.Seconds()))
+			spec.ActiveDeadlineSeconds = &seconds
+		} else {
+			log.Printf("Warning: ignoring invalid 'buildTimeout' %q of project %s", timeout, project.Name)
 		}
-		spec.ImagePullSecrets = refs
 	}
 
+	spec.ImagePullSecrets = imagePullSecrets(project)
+
 	return v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   build.Name,
@@ -191,6 +250,20 @@ func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 	}
 }
 
+// imagePullSecrets returns the project's image pull secrets, or nil if it
+// has none.
+func imagePullSecrets(project *v1.Secret) []v1.LocalObjectReference {
+	ips := project.Data["imagePullSecrets"]
+	if len(ips) == 0 {
+		return nil
+	}
+	refs := []v1.LocalObjectReference{}
+	for _, pullSec := range strings.Split(string(ips), ",") {
+		refs = append(refs, v1.LocalObjectReference{Name: strings.TrimSpace(pullSec)})
+	}
+	return refs
+}
+
 func workerImageConfig(project *v1.Secret, config *Config) (string, string) {
 	// There isn't a correct way of making a proper distinction between registry,
 	// registry+name or name, examples:
@@ -307,6 +380,11 @@ func workerEnv(project, build *v1.Secret, config *Config) []v1.EnvVar {
 		}
 	}
 
+	eventScriptPath := eventScriptPath(project, bsv.String("event_type"))
+	if eventScriptPath != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_EVENT_SCRIPT", Value: filepath.Join("/vcs", eventScriptPath)})
+	}
+
 	brigadeConfigPath := psv.String("brigadeConfigPath")
 	if brigadeConfigPath != "" {
 		if filepath.IsAbs(brigadeConfigPath) {
@@ -316,9 +394,103 @@ func workerEnv(project, build *v1.Secret, config *Config) []v1.EnvVar {
 		}
 	}
 
+	if sparse := psv.String("sparseCheckoutPaths"); sparse != "" {
+		paths := sparseCheckoutPaths(strings.Split(sparse, ","), brigadejsPath, eventScriptPath, brigadeConfigPath)
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_SPARSE_CHECKOUT_PATHS", Value: strings.Join(paths, ",")})
+	}
+
+	if bundleURI := psv.String("bundleURI"); bundleURI != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_BUNDLE_URI", Value: bundleURI})
+	}
+
+	if lfsConcurrency := psv.String("lfsConcurrency"); lfsConcurrency != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_LFS_CONCURRENCY", Value: lfsConcurrency})
+	}
+
+	// TRACEPARENT is how OpenTelemetry passes a trace context to a process.
+	if traceParent := bsv.String("trace_parent"); traceParent != "" {
+		envs = append(envs, v1.EnvVar{Name: "TRACEPARENT", Value: traceParent})
+	}
+
+	if knownEvents := psv.String("knownEvents"); knownEvents != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_KNOWN_EVENTS", Value: knownEvents})
+	}
+
+	// The worker passes the proxies on to the sidecars of its jobs.
+	httpProxy, httpsProxy := vcsProxies(project, config)
+	if httpProxy != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_VCS_HTTP_PROXY", Value: httpProxy})
+	}
+	if httpsProxy != "" {
+		envs = append(envs, v1.EnvVar{Name: "BRIGADE_VCS_HTTPS_PROXY", Value: httpsProxy})
+	}
+
 	return envs
 }
 
+// eventScriptPath returns the path in the repository of the script the
+// project runs for the event type, or an empty string if it runs its default
+// script.
+func eventScriptPath(project *v1.Secret, eventType string) string {
+	data := project.Data["brigadejsPaths"]
+	if len(data) == 0 {
+		return ""
+	}
+	var paths map[string]string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		log.Printf("Warning: ignoring invalid 'brigadejsPaths' of project %s: %s", project.Name, err)
+		return ""
+	}
+	path := paths[eventType]
+	if filepath.IsAbs(path) {
+		log.Printf("Warning: the 'brigadejsPaths' script of event %q will be ignored because provided path '%s' is an absolute path", eventType, path)
+		return ""
+	}
+	return path
+}
+
+// vcsProxies returns the proxies to clone the project's repository through.
+// The project's proxies override the controller's.
+func vcsProxies(project *v1.Secret, config *Config) (httpProxy, httpsProxy string) {
+	httpProxy, httpsProxy = config.HTTPProxy, config.HTTPSProxy
+	if p := project.Data["httpProxy"]; len(p) > 0 {
+		httpProxy = string(p)
+	}
+	if p := project.Data["httpsProxy"]; len(p) > 0 {
+		httpsProxy = string(p)
+	}
+	return httpProxy, httpsProxy
+}
+
+// sparseCheckoutPaths adds the directories holding the given files to a set of
+// sparse checkout paths, unless they are already covered by it.
+//
+// The sidecar checks out in cone mode, so files at the root of the repository
+// (like the default brigade.js and brigade.json) are always present.
+func sparseCheckoutPaths(paths []string, files ...string) []string {
+	for _, f := range files {
+		if f == "" || filepath.IsAbs(f) {
+			continue
+		}
+		dir := filepath.Dir(filepath.Clean(f))
+		if dir == "." || sparseCheckoutCovers(paths, dir) {
+			continue
+		}
+		paths = append(paths, dir)
+	}
+	return paths
+}
+
+func sparseCheckoutCovers(paths []string, dir string) bool {
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // workerResources generates the resources for the worker, given in the configuration
 // If the value is not given, or it's wrong, empty resources gill be returned
 func workerResources(config *Config) v1.ResourceRequirements {
@@ -1,10 +1,16 @@
 package controller
 
 import (
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
 )
 
 func TestNewWorkerPod_Defaults(t *testing.T) {
@@ -188,3 +194,272 @@ func TestNewWorkerPod_WorkerEnv_ServiceAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWorkerPod_SparseCheckoutPaths(t *testing.T) {
+	testcases := []struct {
+		name string
+		data map[string][]byte
+		want string
+	}{
+		{"not set", map[string][]byte{}, ""},
+		{"default script",
+			map[string][]byte{"sparseCheckoutPaths": []byte("services/api,charts")},
+			"services/api,charts",
+		},
+		{"script within sparse paths",
+			map[string][]byte{
+				"sparseCheckoutPaths": []byte("services/api,charts"),
+				"brigadejsPath":       []byte("services/api/ci/brigade.js"),
+			},
+			"services/api,charts",
+		},
+		{"event script outside sparse paths",
+			map[string][]byte{
+				"sparseCheckoutPaths": []byte("services/api"),
+				"brigadejsPaths":      []byte(`{"push": "ci/push.js"}`),
+			},
+			"services/api,ci",
+		},
+		{"script and config outside sparse paths",
+			map[string][]byte{
+				"sparseCheckoutPaths": []byte("services/api"),
+				"brigadejsPath":       []byte("ci/brigade.js"),
+				"brigadeConfigPath":   []byte("ci/config/brigade.json"),
+			},
+			"services/api,ci",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			build := &v1.Secret{Data: map[string][]byte{"event_type": []byte("push")}}
+			pod := NewWorkerPod(build, &v1.Secret{Data: tc.data}, &Config{})
+
+			got := ""
+			for _, env := range pod.Spec.Containers[0].Env {
+				if env.Name == "BRIGADE_SPARSE_CHECKOUT_PATHS" {
+					got = env.Value
+				}
+			}
+			if got != tc.want {
+				t.Errorf("expected BRIGADE_SPARSE_CHECKOUT_PATHS to be %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewWorkerPod_EventScript(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{
+		"brigadejsPaths": []byte(`{"push": "ci/push.js", "release": "/etc/release.js"}`),
+	}}
+	for event, want := range map[string]string{
+		"push":    "/vcs/ci/push.js",
+		"release": "",
+		"exec":    "",
+	} {
+		build := &v1.Secret{Data: map[string][]byte{"event_type": []byte(event)}}
+		pod := NewWorkerPod(build, project, &Config{})
+
+		got := ""
+		for _, env := range pod.Spec.Containers[0].Env {
+			if env.Name == "BRIGADE_EVENT_SCRIPT" {
+				got = env.Value
+			}
+		}
+		if got != want {
+			t.Errorf("expected BRIGADE_EVENT_SCRIPT of a %s event to be %q, got %q", event, want, got)
+		}
+	}
+}
+
+func TestNewWorkerPod_Prelude(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{"vcsSidecar": []byte("brigadecore/git-sidecar:latest")}}
+	build := &v1.Secret{}
+	config := &Config{WorkerImage: "brigadecore/brigade-worker:1.0"}
+
+	if pod := NewWorkerPod(build, project, config); len(pod.Spec.InitContainers) != 1 {
+		t.Fatalf("expected only the VCS sidecar without a prelude, got %d init containers", len(pod.Spec.InitContainers))
+	}
+
+	config.PreludeScript = "license-scan /vcs"
+	config.PreludeBlocking = true
+	pod := NewWorkerPod(build, project, config)
+	if len(pod.Spec.InitContainers) != 2 {
+		t.Fatalf("expected 2 init containers, got %d", len(pod.Spec.InitContainers))
+	}
+	// The prelude needs the checkout, so it must run after the sidecar.
+	prelude := pod.Spec.InitContainers[1]
+	if prelude.Name != "prelude" || prelude.Image != "brigadecore/brigade-worker:1.0" {
+		t.Errorf("unexpected prelude container %s with image %s", prelude.Name, prelude.Image)
+	}
+	if len(prelude.Command) != 3 || prelude.Command[2] != "license-scan /vcs" {
+		t.Errorf("unexpected command %v", prelude.Command)
+	}
+	mounted := false
+	for _, m := range prelude.VolumeMounts {
+		mounted = mounted || m.MountPath == "/vcs"
+	}
+	if !mounted {
+		t.Error("expected the prelude to mount the checkout")
+	}
+
+	config.PreludeBlocking = false
+	prelude = NewWorkerPod(build, project, config).Spec.InitContainers[1]
+	if !strings.Contains(prelude.Command[2], `"$BRIGADE_PRELUDE_SCRIPT" ||`) {
+		t.Errorf("expected a non-blocking prelude to ignore its exit code, got %v", prelude.Command)
+	}
+}
+
+func TestNewWorkerPod_TraceParent(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	build := &v1.Secret{Data: map[string][]byte{"trace_parent": []byte(traceParent)}}
+	pod := NewWorkerPod(build, &v1.Secret{}, &Config{})
+
+	got := ""
+	for _, env := range pod.Spec.Containers[0].Env {
+		if env.Name == "TRACEPARENT" {
+			got = env.Value
+		}
+	}
+	if got != traceParent {
+		t.Errorf("expected TRACEPARENT to be %q, got %q", traceParent, got)
+	}
+}
+
+func TestNewWorkerPod_BuildTimeout(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{"buildTimeout": []byte("90s")}}
+	pod := NewWorkerPod(&v1.Secret{}, project, &Config{})
+	if d := pod.Spec.ActiveDeadlineSeconds; d == nil || *d != 90 {
+		t.Errorf("expected an active deadline of 90 seconds, got %v", d)
+	}
+
+	pod = NewWorkerPod(&v1.Secret{}, &v1.Secret{}, &Config{})
+	if pod.Spec.ActiveDeadlineSeconds != nil {
+		t.Errorf("expected no active deadline, got %d", *pod.Spec.ActiveDeadlineSeconds)
+	}
+}
+
+func TestNewWorkerPod_WorkspaceSizeLimit(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{
+		"vcsSidecar":           []byte("brigadecore/git-sidecar:latest"),
+		"workspaceSizeLimitMB": []byte("100"),
+	}}
+	pod := NewWorkerPod(&v1.Secret{}, project, &Config{})
+	var workspace *v1.Volume
+	for i, v := range pod.Spec.Volumes {
+		if v.Name == "vcs-sidecar" {
+			workspace = &pod.Spec.Volumes[i]
+		}
+	}
+	if workspace == nil {
+		t.Fatal("expected a workspace volume")
+	}
+	if limit := workspace.EmptyDir.SizeLimit; limit == nil || limit.Value() != 100*1024*1024 {
+		t.Errorf("expected a size limit of 100Mi, got %v", limit)
+	}
+
+	project.Data["workspaceSizeLimitMB"] = []byte("0")
+	pod = NewWorkerPod(&v1.Secret{}, project, &Config{})
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == "vcs-sidecar" && v.EmptyDir.SizeLimit != nil {
+			t.Errorf("expected no size limit, got %v", v.EmptyDir.SizeLimit)
+		}
+	}
+}
+
+func TestNewWorkerPod_SidecarSettings(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{
+		"bundleURI":      []byte("s3://mirrors/clown.bundle"),
+		"lfsConcurrency": []byte("8"),
+		"vcsSidecar":     []byte("brigadecore/git-sidecar:latest"),
+	}}
+	pod := NewWorkerPod(&v1.Secret{}, project, &Config{})
+	if len(pod.Spec.InitContainers) != 1 {
+		t.Fatalf("expected a VCS sidecar, got %d init containers", len(pod.Spec.InitContainers))
+	}
+	env := map[string]string{}
+	for _, e := range pod.Spec.InitContainers[0].Env {
+		env[e.Name] = e.Value
+	}
+	for name, want := range map[string]string{
+		"BRIGADE_BUNDLE_URI":      "s3://mirrors/clown.bundle",
+		"BRIGADE_LFS_CONCURRENCY": "8",
+	} {
+		if env[name] != want {
+			t.Errorf("expected %s to be %q, got %q", name, want, env[name])
+		}
+	}
+}
+
+func TestNewWorkerPod_KnownEvents(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{"knownEvents": []byte("deploy,rollback")}}
+	pod := NewWorkerPod(&v1.Secret{}, project, &Config{})
+	for _, e := range pod.Spec.Containers[0].Env {
+		if e.Name == "BRIGADE_KNOWN_EVENTS" {
+			if e.Value != "deploy,rollback" {
+				t.Errorf("expected BRIGADE_KNOWN_EVENTS to be %q, got %q", "deploy,rollback", e.Value)
+			}
+			return
+		}
+	}
+	t.Error("expected BRIGADE_KNOWN_EVENTS to be set")
+}
+
+func TestNewWorkerPod_Proxies(t *testing.T) {
+	project := &v1.Secret{Data: map[string][]byte{
+		"vcsSidecar": []byte("brigadecore/git-sidecar:latest"),
+		"httpsProxy": []byte("http://squid.example.com:3128"),
+	}}
+	config := &Config{HTTPProxy: "http://proxy.example.com:8080", HTTPSProxy: "http://proxy.example.com:8443"}
+	pod := NewWorkerPod(&v1.Secret{}, project, config)
+
+	envMap := func(vars []v1.EnvVar) map[string]string {
+		env := map[string]string{}
+		for _, e := range vars {
+			env[e.Name] = e.Value
+		}
+		return env
+	}
+	sidecar := envMap(pod.Spec.InitContainers[0].Env)
+	worker := envMap(pod.Spec.Containers[0].Env)
+	for name, want := range map[string]string{
+		"http_proxy":  "http://proxy.example.com:8080",
+		"https_proxy": "http://squid.example.com:3128",
+	} {
+		if sidecar[name] != want {
+			t.Errorf("expected the sidecar's %s to be %q, got %q", name, want, sidecar[name])
+		}
+		if _, ok := worker[name]; ok {
+			t.Errorf("expected the worker not to use %s", name)
+		}
+	}
+	if worker["BRIGADE_VCS_HTTPS_PROXY"] != "http://squid.example.com:3128" {
+		t.Errorf("expected the worker to pass the project's HTTPS proxy to jobs, got %q", worker["BRIGADE_VCS_HTTPS_PROXY"])
+	}
+}
+
+func TestUpdateBuildStatus(t *testing.T) {
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"build": "queequeg"},
+		},
+	}
+	client := fake.NewSimpleClientset(build)
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+
+	if err := controller.updateBuildStatus(build); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Labels["status"] != "accepted" {
+		t.Errorf("expected status accepted, got %q", updated.Labels["status"])
+	}
+	if _, err := time.Parse(time.RFC3339, updated.Annotations[kube.AcceptedTimeAnnotation]); err != nil {
+		t.Errorf("expected an accepted time: %s", err)
+	}
+}
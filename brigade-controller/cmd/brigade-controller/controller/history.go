@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// previousBuildEnv returns the environment describing the last completed
+// build of the same project and branch before the given build, so that
+// scripts can gate on its result. It is empty for the first build of a ref,
+// and for builds without a ref.
+//
+// The history is read from the informers' caches. Build IDs are ULIDs, so
+// they sort in the order the builds were created.
+func (c *Controller) previousBuildEnv(build *v1.Secret) ([]v1.EnvVar, error) {
+	ref := branchOf(string(build.Data["commit_ref"]))
+	if ref == "" {
+		return nil, nil
+	}
+
+	builds, err := c.indexer.ByIndex(projectIndex, build.Labels["project"])
+	if err != nil {
+		return nil, err
+	}
+	var previous *v1.Secret
+	var previousPhase v1.PodPhase
+	for _, obj := range builds {
+		b := obj.(*v1.Secret)
+		id := b.Labels["build"]
+		if id >= build.Labels["build"] || branchOf(string(b.Data["commit_ref"])) != ref {
+			continue
+		}
+		if previous != nil && id <= previous.Labels["build"] {
+			continue
+		}
+		// Worker pods are named after their build secrets.
+		obj, exists, err := c.workerIndexer.GetByKey(b.Namespace + "/" + b.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists || !podCompleted(obj.(*v1.Pod)) {
+			continue
+		}
+		previous, previousPhase = b, obj.(*v1.Pod).Status.Phase
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	return []v1.EnvVar{
+		{Name: "BRIGADE_PREVIOUS_BUILD_ID", Value: previous.Labels["build"]},
+		{Name: "BRIGADE_PREVIOUS_BUILD_STATUS", Value: string(previousPhase)},
+		{Name: "BRIGADE_PREVIOUS_COMMIT_ID", Value: string(previous.Data["commit_id"])},
+	}, nil
+}
+
+// branchOf returns the branch name of a ref, e.g. "master" for
+// "refs/heads/master", so that builds of a branch match whichever form their
+// gateway used. Refs that are not branches are returned unchanged.
+func branchOf(ref string) string {
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
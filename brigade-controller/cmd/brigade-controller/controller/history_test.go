@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func historyBuild(id, ref, commit string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "brigade-worker-" + id,
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "brigade-1234", "build": id},
+		},
+		Data: map[string][]byte{"commit_ref": []byte(ref), "commit_id": []byte(commit)},
+	}
+}
+
+func historyWorker(id string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "brigade-worker-" + id,
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "brigade-1234", "build": id},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+// historyController returns a controller whose informer caches hold the
+// given build secrets and worker pods.
+func historyController(objects ...runtime.Object) *Controller {
+	c := &Controller{
+		Config:        &Config{Namespace: v1.NamespaceDefault},
+		indexer:       cache.NewIndexer(cache.MetaNamespaceKeyFunc, projectIndexers),
+		workerIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, projectIndexers),
+	}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *v1.Secret:
+			c.indexer.Add(o)
+		case *v1.Pod:
+			c.workerIndexer.Add(o)
+		}
+	}
+	return c
+}
+
+func TestPreviousBuildEnv(t *testing.T) {
+	current := historyBuild("01e0000004", "refs/heads/master", "ddd")
+	objects := []runtime.Object{
+		historyBuild("01e0000001", "refs/heads/master", "aaa"), historyWorker("01e0000001", v1.PodSucceeded),
+		historyBuild("01e0000002", "refs/heads/master", "bbb"), historyWorker("01e0000002", v1.PodFailed),
+		// Still running, so it has no result yet.
+		historyBuild("01e0000003", "refs/heads/master", "ccc"), historyWorker("01e0000003", v1.PodRunning),
+		historyBuild("01e0000005", "refs/heads/master", "eee"), historyWorker("01e0000005", v1.PodSucceeded),
+		historyBuild("01e0000000", "refs/heads/feature", "fff"), historyWorker("01e0000000", v1.PodSucceeded),
+		current,
+	}
+	controller := historyController(objects...)
+
+	env, err := controller.previousBuildEnv(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range env {
+		got[e.Name] = e.Value
+	}
+	if got["BRIGADE_PREVIOUS_BUILD_ID"] != "01e0000002" || got["BRIGADE_PREVIOUS_BUILD_STATUS"] != "Failed" || got["BRIGADE_PREVIOUS_COMMIT_ID"] != "bbb" {
+		t.Errorf("unexpected previous build %v", got)
+	}
+
+	first := historyBuild("01e0000006", "refs/heads/release", "ggg")
+	if env, err := controller.previousBuildEnv(first); err != nil || len(env) != 0 {
+		t.Errorf("expected no previous build of a new ref, got %v, %v", env, err)
+	}
+}
+
+func TestPreviousBuildEnvMixedRefs(t *testing.T) {
+	// brig and the generic gateway send branch names, other gateways full refs.
+	current := historyBuild("01e0000003", "master", "ccc")
+	controller := historyController(
+		historyBuild("01e0000001", "master", "aaa"), historyWorker("01e0000001", v1.PodFailed),
+		historyBuild("01e0000002", "refs/heads/master", "bbb"), historyWorker("01e0000002", v1.PodSucceeded),
+		current,
+	)
+
+	env, err := controller.previousBuildEnv(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range env {
+		got[e.Name] = e.Value
+	}
+	if got["BRIGADE_PREVIOUS_BUILD_ID"] != "01e0000002" || got["BRIGADE_PREVIOUS_BUILD_STATUS"] != "Succeeded" {
+		t.Errorf("expected build 01e0000002 of refs/heads/master, got %v", got)
+	}
+
+	next := historyBuild("01e0000004", "refs/heads/master", "ddd")
+	controller.indexer.Add(next)
+	controller.workerIndexer.Add(historyWorker("01e0000003", v1.PodFailed))
+	env, err = controller.previousBuildEnv(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) == 0 || env[0].Value != "01e0000003" {
+		t.Errorf("expected build 01e0000003 of master, got %v", env)
+	}
+}
@@ -5,12 +5,27 @@ import (
 	"log"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 )
 
+// projectIndex indexes build secrets and worker pods by their project, so
+// that a project's history can be read from the informers' caches.
+const projectIndex = "project"
+
+var projectIndexers = cache.Indexers{
+	projectIndex: func(obj interface{}) ([]string, error) {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		return []string{m.GetLabels()["project"]}, nil
+	},
+}
+
 func (c *Controller) createIndexerInformer() {
 	selector := "type=brigade.sh/build"
 	c.indexer, c.informer = cache.NewIndexerInformer(
@@ -29,11 +44,12 @@ func (c *Controller) createIndexerInformer() {
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
-					log.Println("Adding to workqueue: ", key)
-					c.queue.Add(key)
+					priority := buildPriority(obj.(*v1.Secret))
+					log.Printf("Adding to %s priority workqueue: %s", priority, key)
+					c.queue.Add(key, priority)
 				}
 			},
 		},
-		cache.Indexers{},
+		projectIndexers,
 	)
 }
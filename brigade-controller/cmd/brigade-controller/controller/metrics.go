@@ -0,0 +1,20 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Metrics reports the deliveries of outbound webhooks in the Prometheus text
+// format.
+func (c *Controller) Metrics(w http.ResponseWriter, r *http.Request) {
+	delivered, failed := c.webhooks.Stats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "# HELP brigade_controller_webhook_deliveries_total Build documents delivered to outbound webhooks.\n")
+	fmt.Fprintf(w, "# TYPE brigade_controller_webhook_deliveries_total counter\n")
+	fmt.Fprintf(w, "brigade_controller_webhook_deliveries_total %d\n", delivered)
+	fmt.Fprintf(w, "# HELP brigade_controller_webhook_failures_total Build documents dropped after every delivery attempt failed.\n")
+	fmt.Fprintf(w, "# TYPE brigade_controller_webhook_failures_total counter\n")
+	fmt.Fprintf(w, "brigade_controller_webhook_failures_total %d\n", failed)
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMetrics(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), &Config{Namespace: v1.NamespaceDefault})
+
+	rec := httptest.NewRecorder()
+	controller.Metrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"brigade_controller_webhook_deliveries_total 0\n", "brigade_controller_webhook_failures_total 0\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in %q", want, body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/notify"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// createWorkerInformer watches worker pods and reports each build when its
+// worker starts running, and completes it when its worker completes. Workers
+// that completed while the controller was not running are completed when the
+// informer first lists them.
+func (c *Controller) createWorkerInformer() {
+	selector := "heritage=brigade,component=build"
+	c.workerIndexer, c.workerInformer = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return c.clientset.CoreV1().Pods(c.Namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return c.clientset.CoreV1().Pods(c.Namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1.Pod{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if pod := obj.(*v1.Pod); podCompleted(pod) {
+					go c.completeBuild(pod)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldPod, newPod := oldObj.(*v1.Pod), newObj.(*v1.Pod)
+				// Notifications must never hold up the controller.
+				if oldPod.Status.Phase == v1.PodPending && newPod.Status.Phase == v1.PodRunning {
+					go c.notifyBuild(newPod)
+				}
+				if !podCompleted(oldPod) && podCompleted(newPod) {
+					go c.completeBuild(newPod)
+				}
+			},
+		},
+		projectIndexers,
+	)
+}
+
+func podCompleted(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// notifyBuild sends the notifications configured on the project of the build
+// run by the given worker pod.
+func (c *Controller) notifyBuild(pod *v1.Pod) {
+	build, err := c.loadWorkerBuild(pod)
+	if err != nil {
+		log.Printf("notify: %s", err)
+		return
+	}
+	c.dispatchNotifications(build.secret, build.project, pod)
+}
+
+// dispatchNotifications sends the notifications configured on the project of
+// the build. Errors are logged and recorded on the build, but never change
+// its result.
+func (c *Controller) dispatchNotifications(buildSecret *v1.Secret, project *brigade.Project, pod *v1.Pod) {
+	build := kube.NewBuildFromSecret(*buildSecret)
+	build.Worker = kube.NewWorkerFromPod(*pod)
+	errs := c.notifiers.Dispatch(notify.BuildEvent{Project: project, Build: build})
+	if len(errs) == 0 {
+		return
+	}
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		log.Printf("notify: build %s: %s", build.ID, err)
+		lines[i] = err.Error()
+	}
+	if err := c.recordNotificationErrors(buildSecret, lines); err != nil {
+		log.Printf("notify: could not record notification errors of build %s: %s", build.ID, err)
+	}
+}
+
+// recordNotificationErrors appends the errors to the build secret's
+// notification errors annotation.
+func (c *Controller) recordNotificationErrors(build *v1.Secret, errs []string) error {
+	buildCopy := build.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	if previous := buildCopy.Annotations[kube.NotificationErrorsAnnotation]; previous != "" {
+		errs = append([]string{previous}, errs...)
+	}
+	buildCopy.Annotations[kube.NotificationErrorsAnnotation] = strings.Join(errs, "\n")
+	_, err := c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// logTailLines is the number of worker log lines included in notifications.
+const logTailLines = 50
+
+// workerLogTail returns the last lines of the logs of a build's worker.
+func (c *Controller) workerLogTail(build *brigade.Build) (string, error) {
+	tail := int64(logTailLines)
+	req := c.clientset.CoreV1().Pods(c.Namespace).GetLogs(build.Worker.ID, &v1.PodLogOptions{TailLines: &tail})
+	stream, err := req.Stream(context.TODO())
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	logs, err := ioutil.ReadAll(stream)
+	return string(logs), err
+}
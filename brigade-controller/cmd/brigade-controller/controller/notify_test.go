@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestNotifyBuildResult(t *testing.T) {
+	notified := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified++
+	}))
+	defer srv.Close()
+
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"build": "queequeg", "project": "ahab"},
+		},
+		Data: map[string][]byte{"commit_ref": []byte("refs/heads/master")},
+	}
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data: map[string][]byte{
+			"notifications.slack.webhookURL":    []byte(srv.URL),
+			"notifications.slack.onlyOnFailure": []byte("true"),
+		},
+	}
+	client := fake.NewSimpleClientset(build, project)
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+
+	pod := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "moby", Labels: build.Labels},
+		Status:     v1.PodStatus{Phase: v1.PodSucceeded, StartTime: &meta.Time{Time: time.Now()}},
+	}
+	controller.notifyBuild(pod)
+	if notified != 0 {
+		t.Errorf("expected a successful build not to be reported, got %d notifications", notified)
+	}
+
+	pod.Status.Phase = v1.PodFailed
+	controller.notifyBuild(pod)
+	if notified != 1 {
+		t.Errorf("expected a failed build to be reported, got %d notifications", notified)
+	}
+}
+
+func TestNotifyBuildRecordsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"build": "queequeg", "project": "ahab"},
+		},
+	}
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"notifications.slack.webhookURL": []byte(srv.URL)},
+	}
+	client := fake.NewSimpleClientset(build, project)
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+
+	pod := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "moby", Labels: build.Labels},
+		Status:     v1.PodStatus{Phase: v1.PodFailed, StartTime: &meta.Time{Time: time.Now()}},
+	}
+	controller.notifyBuild(pod)
+
+	got, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := got.Annotations[kube.NotificationErrorsAnnotation]; !strings.HasPrefix(errs, "chat: ") {
+		t.Errorf("expected a chat notification error, got %q", errs)
+	}
+}
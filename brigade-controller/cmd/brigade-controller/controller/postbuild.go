@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// postBuildTimeout limits how long a post-build or postlude script may run.
+const postBuildTimeout = 10 * time.Minute
+
+// runPostBuildScript runs the project's post-build script, if it has one, in
+// a pod of its own once the build's worker completes. The script's result is
+// only logged: it never changes the result of the build.
+func (c *Controller) runPostBuildScript(build, project *v1.Secret, worker *v1.Pod) {
+	if pod := NewPostBuildPod(build, project, worker, c.Config); pod != nil {
+		c.runAfterBuild(build, pod, "post-build script")
+	}
+}
+
+// runPostlude runs the operator's postlude script, like runPostBuildScript.
+func (c *Controller) runPostlude(build, project *v1.Secret, worker *v1.Pod) {
+	if pod := NewPostludePod(build, project, worker, c.Config); pod != nil {
+		c.runAfterBuild(build, pod, "postlude script")
+	}
+}
+
+// runAfterBuild creates the pod of a script run after the build and logs its
+// result.
+func (c *Controller) runAfterBuild(build *v1.Secret, pod *v1.Pod, what string) {
+	pods := c.clientset.CoreV1().Pods(c.Namespace)
+	w, err := pods.Watch(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("heritage=brigade,component=%s,build=%s", pod.Labels["component"], build.Labels["build"]),
+	})
+	if err != nil {
+		log.Printf("Warning: could not watch the %s of build %s: %s", what, build.Labels["build"], err)
+		return
+	}
+	defer w.Stop()
+	if _, err := pods.Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+		log.Printf("Warning: could not start the %s of build %s: %s", what, build.Labels["build"], err)
+		return
+	}
+
+	// The pod's own deadline stops the script; this one only guards against
+	// a pod that never gets scheduled.
+	deadline := time.After(postBuildTimeout + time.Minute)
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			p, isPod := e.Object.(*v1.Pod)
+			if !isPod || e.Type == watch.Deleted || p.Name != pod.Name {
+				continue
+			}
+			switch p.Status.Phase {
+			case v1.PodSucceeded:
+				log.Printf("%s of build %s succeeded", what, build.Labels["build"])
+				return
+			case v1.PodFailed:
+				log.Printf("Warning: %s of build %s failed: %s", what, build.Labels["build"], podFailure(p))
+				return
+			}
+		case <-deadline:
+			log.Printf("Warning: %s of build %s did not complete within %s", what, build.Labels["build"], postBuildTimeout)
+			return
+		}
+	}
+}
+
+// NewPostBuildPod returns the pod that runs the project's post-build script
+// after the given worker completed, or nil if the project has none.
+//
+// The script runs with sh in the worker image, under the worker's service
+// account, and gets the worker's exit code as BRIGADE_BUILD_EXIT_CODE.
+func NewPostBuildPod(build, project *v1.Secret, worker *v1.Pod, config *Config) *v1.Pod {
+	return newAfterBuildPod("postbuild", "post-build", string(project.Data["postBuildScript"]), build, project, worker, config)
+}
+
+// NewPostludePod returns the pod that runs the operator's postlude script
+// after the given worker completed, or nil if there is none. It runs like a
+// post-build script, so scripts cannot skip it.
+func NewPostludePod(build, project *v1.Secret, worker *v1.Pod, config *Config) *v1.Pod {
+	return newAfterBuildPod("postlude", "postlude", config.PostludeScript, build, project, worker, config)
+}
+
+func newAfterBuildPod(component, container, script string, build, project *v1.Secret, worker *v1.Pod, config *Config) *v1.Pod {
+	if script == "" {
+		return nil
+	}
+	image, pullPolicy := workerImageConfig(project, config)
+	deadline := int64(postBuildTimeout.Seconds())
+
+	labels := map[string]string{
+		"heritage":  "brigade",
+		"component": component,
+		"build":     build.Labels["build"],
+		"project":   build.Labels["project"],
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "brigade-" + component + "-" + build.Labels["build"],
+			Labels: labels,
+		},
+		Spec: v1.PodSpec{
+			ServiceAccountName: config.WorkerServiceAccount,
+			NodeSelector: map[string]string{
+				"beta.kubernetes.io/os": "linux",
+			},
+			Containers: []v1.Container{{
+				Name:            container,
+				Image:           image,
+				ImagePullPolicy: v1.PullPolicy(pullPolicy),
+				Command:         []string{"/bin/sh", "-c", script},
+				Env: []v1.EnvVar{
+					{Name: "BRIGADE_BUILD_ID", Value: build.Labels["build"]},
+					{Name: "BRIGADE_PROJECT_ID", Value: build.Labels["project"]},
+					{Name: "BRIGADE_BUILD_EXIT_CODE", Value: strconv.Itoa(int(workerExitCode(worker)))},
+				},
+			}},
+			ActiveDeadlineSeconds: &deadline,
+			RestartPolicy:         v1.RestartPolicyNever,
+			ImagePullSecrets:      imagePullSecrets(project),
+		},
+	}
+}
+
+// workerExitCode returns the exit code of a completed worker. Workers that
+// failed without exiting, for instance because their build timed out, count
+// as exiting with 1.
+func workerExitCode(worker *v1.Pod) int32 {
+	for _, cs := range worker.Status.ContainerStatuses {
+		if cs.Name == "brigade-runner" && cs.State.Terminated != nil {
+			return cs.State.Terminated.ExitCode
+		}
+	}
+	if worker.Status.Phase == v1.PodSucceeded {
+		return 0
+	}
+	return 1
+}
+
+// podFailure describes why a pod failed.
+func podFailure(pod *v1.Pod) string {
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil {
+			return fmt.Sprintf("exit code %d", t.ExitCode)
+		}
+	}
+	return "unknown reason"
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func exitedWorker(phase v1.PodPhase, code int32) *v1.Pod {
+	return &v1.Pod{
+		Status: v1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name: "brigade-runner",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: code,
+				}},
+			}},
+		},
+	}
+}
+
+func TestNewPostBuildPod(t *testing.T) {
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:   "brigade-worker-queequeg",
+		Labels: map[string]string{"build": "queequeg", "project": "pequod"},
+	}}
+	project := &v1.Secret{Data: map[string][]byte{}}
+	config := &Config{WorkerImage: "brigadecore/brigade-worker:1.0", WorkerServiceAccount: "brigade-worker"}
+
+	if pod := NewPostBuildPod(build, project, exitedWorker(v1.PodSucceeded, 0), config); pod != nil {
+		t.Fatalf("expected no pod without a post-build script, got %v", pod)
+	}
+
+	project.Data["postBuildScript"] = []byte("kubectl delete namespace preview")
+	project.Data["imagePullSecrets"] = []byte("registry")
+	pod := NewPostBuildPod(build, project, exitedWorker(v1.PodFailed, 3), config)
+	if pod == nil {
+		t.Fatal("expected a pod")
+	}
+	if pod.Name != "brigade-postbuild-queequeg" || pod.Labels["component"] != "postbuild" || pod.Labels["build"] != "queequeg" {
+		t.Errorf("unexpected pod metadata %v", pod.ObjectMeta)
+	}
+	if d := pod.Spec.ActiveDeadlineSeconds; d == nil || *d != 600 {
+		t.Errorf("expected a deadline of 600 seconds, got %v", d)
+	}
+	if pod.Spec.ServiceAccountName != "brigade-worker" {
+		t.Errorf("expected the worker service account, got %q", pod.Spec.ServiceAccountName)
+	}
+	if len(pod.Spec.ImagePullSecrets) != 1 || pod.Spec.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("unexpected image pull secrets %v", pod.Spec.ImagePullSecrets)
+	}
+	c := pod.Spec.Containers[0]
+	if c.Image != "brigadecore/brigade-worker:1.0" {
+		t.Errorf("expected the worker image, got %q", c.Image)
+	}
+	if len(c.Command) != 3 || c.Command[2] != "kubectl delete namespace preview" {
+		t.Errorf("unexpected command %v", c.Command)
+	}
+	env := map[string]string{}
+	for _, e := range c.Env {
+		env[e.Name] = e.Value
+	}
+	if env["BRIGADE_BUILD_EXIT_CODE"] != "3" {
+		t.Errorf("expected exit code 3, got %q", env["BRIGADE_BUILD_EXIT_CODE"])
+	}
+	if env["BRIGADE_BUILD_ID"] != "queequeg" {
+		t.Errorf("expected build ID queequeg, got %q", env["BRIGADE_BUILD_ID"])
+	}
+}
+
+func TestNewPostludePod(t *testing.T) {
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:   "brigade-worker-queequeg",
+		Labels: map[string]string{"build": "queequeg", "project": "pequod"},
+	}}
+	project := &v1.Secret{Data: map[string][]byte{"postBuildScript": []byte("kubectl delete namespace preview")}}
+	config := &Config{WorkerImage: "brigadecore/brigade-worker:1.0"}
+
+	if pod := NewPostludePod(build, project, exitedWorker(v1.PodSucceeded, 0), config); pod != nil {
+		t.Fatalf("expected no pod without a postlude script, got %v", pod)
+	}
+
+	config.PostludeScript = "emit-metrics"
+	pod := NewPostludePod(build, project, exitedWorker(v1.PodFailed, 1), config)
+	if pod == nil {
+		t.Fatal("expected a pod")
+	}
+	if pod.Name != "brigade-postlude-queequeg" || pod.Labels["component"] != "postlude" {
+		t.Errorf("unexpected pod metadata %v", pod.ObjectMeta)
+	}
+	if c := pod.Spec.Containers[0]; len(c.Command) != 3 || c.Command[2] != "emit-metrics" {
+		t.Errorf("unexpected command %v", c.Command)
+	}
+}
+
+func TestWorkerExitCode(t *testing.T) {
+	timedOut := &v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "DeadlineExceeded"}}
+	for _, tt := range []struct {
+		worker *v1.Pod
+		want   int32
+	}{
+		{exitedWorker(v1.PodSucceeded, 0), 0},
+		{exitedWorker(v1.PodFailed, 2), 2},
+		{timedOut, 1},
+	} {
+		if got := workerExitCode(tt.worker); got != tt.want {
+			t.Errorf("expected exit code %d, got %d", tt.want, got)
+		}
+	}
+}
+
+func TestRunPostBuildScript(t *testing.T) {
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:   "brigade-worker-queequeg",
+		Labels: map[string]string{"build": "queequeg", "project": "pequod"},
+	}}
+	project := &v1.Secret{Data: map[string][]byte{"postBuildScript": []byte("exit 1")}}
+	client := fake.NewSimpleClientset()
+	controller := &Controller{Config: &Config{Namespace: v1.NamespaceDefault}, clientset: client}
+
+	done := make(chan struct{})
+	go func() {
+		controller.runPostBuildScript(build, project, exitedWorker(v1.PodSucceeded, 0))
+		close(done)
+	}()
+
+	pods := client.CoreV1().Pods(v1.NamespaceDefault)
+	var pod *v1.Pod
+	for i := 0; pod == nil && i < 100; i++ {
+		pod, _ = pods.Get(context.TODO(), "brigade-postbuild-queequeg", metav1.GetOptions{})
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pod == nil {
+		t.Fatal("expected the post-build pod to be created")
+	}
+
+	// A failed script is only logged.
+	pod.Status.Phase = v1.PodFailed
+	if _, err := pods.Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to complete once the pod failed")
+	}
+}
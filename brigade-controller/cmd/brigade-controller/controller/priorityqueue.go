@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// priorities lists the build priorities from the highest to the lowest.
+var priorities = []brigade.BuildPriority{brigade.PriorityHigh, brigade.PriorityNormal, brigade.PriorityLow}
+
+// priorityQueue holds one rate limited work queue per build priority.
+//
+// Get hands out the keys of high priority builds before normal ones, and
+// normal ones before low. Keys are processed and retried in the queue they
+// came from. Every way a key can become ready, including delayed and rate
+// limited adds, wakes the workers blocked in Get.
+type priorityQueue struct {
+	mu       sync.Mutex
+	ready    *sync.Cond
+	shutdown bool
+	queues   map[brigade.BuildPriority]*priorityLane
+}
+
+func newPriorityQueue() *priorityQueue {
+	q := &priorityQueue{queues: map[brigade.BuildPriority]*priorityLane{}}
+	q.ready = sync.NewCond(&q.mu)
+	for _, p := range priorities {
+		q.queues[p] = &priorityLane{
+			Interface: workqueue.New(),
+			limiter:   workqueue.DefaultControllerRateLimiter(),
+			wake:      q.wake,
+		}
+	}
+	return q
+}
+
+// buildPriority returns the priority of a build secret. Builds without a
+// valid priority label have the normal priority.
+func buildPriority(build *v1.Secret) brigade.BuildPriority {
+	p, err := brigade.ParseBuildPriority(build.Labels["priority"])
+	if err != nil {
+		return brigade.PriorityNormal
+	}
+	return p
+}
+
+// Add adds a key to the queue of the given priority.
+func (q *priorityQueue) Add(key string, p brigade.BuildPriority) {
+	q.queues[p].Add(key)
+}
+
+// AddAfter adds a key to the queue of the given priority once the duration
+// has passed.
+func (q *priorityQueue) AddAfter(key string, p brigade.BuildPriority, d time.Duration) {
+	q.queues[p].AddAfter(key, d)
+}
+
+// Get blocks until a key is ready and returns it along with the queue it
+// came from. The queue is nil once the priorityQueue has been shut down.
+// Get is safe to call from several workers.
+func (q *priorityQueue) Get() (interface{}, workqueue.RateLimitingInterface) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.shutdown {
+			return nil, nil
+		}
+		for _, p := range priorities {
+			queue := q.queues[p]
+			// Only Get takes keys out of the lanes, and it holds the lock, so
+			// a lane with keys cannot be emptied before queue.Get returns.
+			if queue.Len() > 0 {
+				key, shutdown := queue.Get()
+				if shutdown {
+					return nil, nil
+				}
+				return key, queue
+			}
+		}
+		q.ready.Wait()
+	}
+}
+
+// wake wakes the workers waiting in Get.
+func (q *priorityQueue) wake() {
+	q.mu.Lock()
+	q.ready.Broadcast()
+	q.mu.Unlock()
+}
+
+// Len returns the number of keys waiting in all queues.
+func (q *priorityQueue) Len() int {
+	n := 0
+	for _, queue := range q.queues {
+		n += queue.Len()
+	}
+	return n
+}
+
+// ShutDown shuts down all queues and releases the workers waiting in Get.
+func (q *priorityQueue) ShutDown() {
+	q.mu.Lock()
+	q.shutdown = true
+	for _, queue := range q.queues {
+		queue.ShutDown()
+	}
+	q.ready.Broadcast()
+	q.mu.Unlock()
+}
+
+// priorityLane is the rate limited queue of one build priority. Keys become
+// ready only through Add and Done, so it wakes the priorityQueue's workers
+// from those.
+type priorityLane struct {
+	workqueue.Interface
+	limiter workqueue.RateLimiter
+	wake    func()
+}
+
+// Add adds a key to the lane.
+func (l *priorityLane) Add(item interface{}) {
+	l.Interface.Add(item)
+	l.wake()
+}
+
+// Done marks a key as processed. A key added again while it was being
+// processed becomes ready now.
+func (l *priorityLane) Done(item interface{}) {
+	l.Interface.Done(item)
+	l.wake()
+}
+
+// AddAfter adds a key to the lane once the duration has passed.
+func (l *priorityLane) AddAfter(item interface{}, d time.Duration) {
+	if l.ShuttingDown() {
+		return
+	}
+	if d <= 0 {
+		l.Add(item)
+		return
+	}
+	time.AfterFunc(d, func() { l.Add(item) })
+}
+
+// AddRateLimited adds a key to the lane once its rate limiter allows it.
+func (l *priorityLane) AddRateLimited(item interface{}) {
+	l.AddAfter(item, l.limiter.When(item))
+}
+
+// Forget stops tracking the retries of a key.
+func (l *priorityLane) Forget(item interface{}) {
+	l.limiter.Forget(item)
+}
+
+// NumRequeues returns how many times a key has been rate limited.
+func (l *priorityLane) NumRequeues(item interface{}) int {
+	return l.limiter.NumRequeues(item)
+}
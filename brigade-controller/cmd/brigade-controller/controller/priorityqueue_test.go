@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := newPriorityQueue()
+	defer q.ShutDown()
+
+	q.Add("default/low", brigade.PriorityLow)
+	q.Add("default/normal", brigade.PriorityNormal)
+	q.Add("default/high", brigade.PriorityHigh)
+	if q.Len() != 3 {
+		t.Fatalf("expected 3 keys, got %d", q.Len())
+	}
+
+	for _, expected := range []string{"default/high", "default/normal", "default/low"} {
+		key, queue := q.Get()
+		if key != expected {
+			t.Errorf("expected %s, got %v", expected, key)
+		}
+		queue.Done(key)
+	}
+}
+
+func TestPriorityQueueBlocks(t *testing.T) {
+	q := newPriorityQueue()
+	defer q.ShutDown()
+
+	keys := make(chan interface{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			for {
+				key, queue := q.Get()
+				if queue == nil {
+					return
+				}
+				keys <- key
+				queue.Done(key)
+			}
+		}()
+	}
+
+	select {
+	case key := <-keys:
+		t.Fatalf("expected Get to block on an empty queue, got %v", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.Add("default/first", brigade.PriorityNormal)
+	q.AddAfter("default/later", brigade.PriorityLow, 10*time.Millisecond)
+	seen := map[interface{}]bool{}
+	for len(seen) < 2 {
+		select {
+		case key := <-keys:
+			seen[key] = true
+		case <-time.After(time.Second):
+			t.Fatalf("expected both keys to reach a worker, got %v", seen)
+		}
+	}
+}
+
+func TestPriorityQueueRateLimited(t *testing.T) {
+	q := newPriorityQueue()
+	defer q.ShutDown()
+
+	q.Add("default/retry", brigade.PriorityHigh)
+	key, queue := q.Get()
+	queue.AddRateLimited(key)
+	queue.Done(key)
+	if n := queue.NumRequeues(key); n != 1 {
+		t.Errorf("expected 1 requeue, got %d", n)
+	}
+
+	done := make(chan interface{})
+	go func() {
+		key, _ := q.Get()
+		done <- key
+	}()
+	select {
+	case key := <-done:
+		if key != "default/retry" {
+			t.Errorf("expected default/retry, got %v", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the rate limited key to wake Get")
+	}
+}
+
+func TestPriorityQueueShutDownWakes(t *testing.T) {
+	q := newPriorityQueue()
+	done := make(chan struct{})
+	go func() {
+		if _, queue := q.Get(); queue != nil {
+			t.Error("expected no queue after shutting down")
+		}
+		close(done)
+	}()
+	q.ShutDown()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected ShutDown to release Get")
+	}
+}
+
+func TestPriorityQueueShutDown(t *testing.T) {
+	q := newPriorityQueue()
+	q.ShutDown()
+	if _, queue := q.Get(); queue != nil {
+		t.Error("expected no queue after shutting down")
+	}
+}
+
+func TestBuildPriority(t *testing.T) {
+	for label, expected := range map[string]brigade.BuildPriority{
+		"high":   brigade.PriorityHigh,
+		"low":    brigade.PriorityLow,
+		"":       brigade.PriorityNormal,
+		"urgent": brigade.PriorityNormal,
+	} {
+		build := &v1.Secret{ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"priority": label}}}
+		if p := buildPriority(build); p != expected {
+			t.Errorf("expected label %q to have priority %s, got %s", label, expected, p)
+		}
+	}
+}
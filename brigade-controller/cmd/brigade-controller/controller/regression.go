@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+const (
+	// durationRegressionWindow is how far back builds are averaged.
+	durationRegressionWindow = 30 * 24 * time.Hour
+	// durationRegressionMinBuilds is the number of builds needed for a
+	// meaningful average.
+	durationRegressionMinBuilds = 5
+)
+
+// recordDurationRegression compares the duration of a successful build with
+// the average of its project's successful builds of the last 30 days, and
+// records a regression on the build secret if it exceeds the project's
+// MaxDurationRegressionFactor.
+//
+// The history comes from the project's worker pods in the worker informer's
+// cache, so it only goes back as far as they are kept.
+func (c *Controller) recordDurationRegression(build *v1.Secret, worker *v1.Pod, project *brigade.Project) error {
+	if project.MaxDurationRegressionFactor <= 0 || worker.Status.Phase != v1.PodSucceeded {
+		return nil
+	}
+	duration, ok := workerDuration(worker)
+	if !ok {
+		return nil
+	}
+
+	workers, err := c.workerIndexer.ByIndex(projectIndex, build.Labels["project"])
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-durationRegressionWindow)
+	var history []time.Duration
+	for _, obj := range workers {
+		w := obj.(*v1.Pod)
+		if w.Name == worker.Name || w.Status.Phase != v1.PodSucceeded || w.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		if d, ok := workerDuration(w); ok {
+			history = append(history, d)
+		}
+	}
+	average, ok := averageDuration(history)
+	if !ok {
+		return nil
+	}
+	factor := float64(duration) / float64(average)
+	if factor <= project.MaxDurationRegressionFactor {
+		return nil
+	}
+
+	regression, err := json.Marshal(brigade.DurationRegression{
+		AverageSeconds: math.Round(average.Seconds()),
+		Factor:         math.Round(factor*10) / 10,
+	})
+	if err != nil {
+		return err
+	}
+	buildCopy := build.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.DurationRegressionAnnotation] = string(regression)
+	_, err = c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// workerDuration returns how long a completed worker ran.
+func workerDuration(pod *v1.Pod) (time.Duration, bool) {
+	if pod.Status.StartTime == nil {
+		return 0, false
+	}
+	w := kube.NewWorkerFromPod(*pod)
+	if w.StartTime.IsZero() || w.EndTime.IsZero() {
+		return 0, false
+	}
+	return w.EndTime.Sub(w.StartTime), true
+}
+
+// averageDuration returns the mean of the durations, leaving out those more
+// than three standard deviations away from it. It returns false if there are
+// too few durations for a meaningful average.
+func averageDuration(durations []time.Duration) (time.Duration, bool) {
+	if len(durations) < durationRegressionMinBuilds {
+		return 0, false
+	}
+	var sum float64
+	for _, d := range durations {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(durations))
+	var squares float64
+	for _, d := range durations {
+		squares += (float64(d) - mean) * (float64(d) - mean)
+	}
+	sigma := math.Sqrt(squares / float64(len(durations)))
+
+	var kept, n float64
+	for _, d := range durations {
+		if math.Abs(float64(d)-mean) <= 3*sigma {
+			kept += float64(d)
+			n++
+		}
+	}
+	if kept == 0 {
+		return 0, false
+	}
+	return time.Duration(kept / n), true
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestAverageDuration(t *testing.T) {
+	durations := []time.Duration{}
+	for i := 0; i < 20; i++ {
+		durations = append(durations, time.Minute)
+	}
+	if _, ok := averageDuration(durations[:4]); ok {
+		t.Error("expected no average of 4 durations")
+	}
+	// The outlier is more than three standard deviations from the mean.
+	durations = append(durations, time.Hour)
+	average, ok := averageDuration(durations)
+	if !ok || average != time.Minute {
+		t.Errorf("expected an average of 1m0s, got %s", average)
+	}
+}
+
+func TestRecordDurationRegression(t *testing.T) {
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"project": "brigade-1234"},
+		},
+	}
+	client := fake.NewSimpleClientset(build)
+	controller := &Controller{
+		Config:        &Config{Namespace: v1.NamespaceDefault},
+		clientset:     client,
+		workerIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, projectIndexers),
+	}
+	for i := 0; i < 5; i++ {
+		pod := completedPod(fmt.Sprintf("ahab-%d", i), v1.NamespaceDefault, "build", "500m", "1G", time.Minute)
+		pod.Labels["project"] = "brigade-1234"
+		pod.CreationTimestamp = metav1.Now()
+		controller.workerIndexer.Add(pod)
+	}
+	worker := completedPod("moby", v1.NamespaceDefault, "build", "500m", "1G", 4*time.Minute)
+
+	project := &brigade.Project{MaxDurationRegressionFactor: 5}
+	if err := controller.recordDurationRegression(build, worker, project); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := kube.NewBuildFromSecret(*secret).DurationRegression; r != nil {
+		t.Errorf("expected no regression below the factor, got %+v", r)
+	}
+
+	project.MaxDurationRegressionFactor = 3
+	if err := controller.recordDurationRegression(build, worker, project); err != nil {
+		t.Fatal(err)
+	}
+	secret, err = client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := kube.NewBuildFromSecret(*secret).DurationRegression
+	if r == nil || r.AverageSeconds != 60 || r.Factor != 4 {
+		t.Errorf("expected a regression of 4x an average of 60s, got %+v", r)
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// recordEnvironmentSnapshot records the environment a completed worker ran
+// in on its build secret.
+func (c *Controller) recordEnvironmentSnapshot(build, project *v1.Secret, worker *v1.Pod) error {
+	snapshot := newEnvironmentSnapshot(build, project, worker)
+	if worker.Spec.NodeName != "" {
+		// Reading nodes needs a cluster role the controller may not have.
+		if node, err := c.clientset.CoreV1().Nodes().Get(context.TODO(), worker.Spec.NodeName, metav1.GetOptions{}); err == nil {
+			info := node.Status.NodeInfo
+			snapshot.KernelVersion = info.KernelVersion
+			snapshot.OSImage = info.OSImage
+			snapshot.KubeletVersion = info.KubeletVersion
+			snapshot.ContainerRuntimeVersion = info.ContainerRuntimeVersion
+		} else {
+			log.Printf("snapshot: could not read node %s of worker %s: %s", worker.Spec.NodeName, worker.Name, err)
+		}
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	buildCopy := build.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.EnvironmentSnapshotAnnotation] = string(data)
+	_, err = c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// newEnvironmentSnapshot returns the snapshot of a worker's images, commit
+// and script.
+func newEnvironmentSnapshot(build, project *v1.Secret, worker *v1.Pod) *brigade.EnvironmentSnapshot {
+	snapshot := &brigade.EnvironmentSnapshot{Commit: string(build.Data["commit_id"])}
+	for _, cs := range worker.Status.ContainerStatuses {
+		if cs.Name == "brigade-runner" {
+			snapshot.WorkerImage, snapshot.WorkerImageID = cs.Image, cs.ImageID
+		}
+	}
+	for _, cs := range worker.Status.InitContainerStatuses {
+		if cs.Name == "vcs-sidecar" {
+			snapshot.SidecarImage, snapshot.SidecarImageID = cs.Image, cs.ImageID
+		}
+	}
+
+	script := build.Data["script"]
+	if len(script) == 0 {
+		script = project.Data["defaultScript"]
+	}
+	if len(script) > 0 {
+		snapshot.ScriptSHA256 = fmt.Sprintf("%x", sha256.Sum256(script))
+	}
+	return snapshot
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestRecordEnvironmentSnapshot(t *testing.T) {
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "moby", Namespace: v1.NamespaceDefault},
+		Data: map[string][]byte{
+			"commit_id": []byte("abc"),
+			"script":    []byte(`console.log("hello")`),
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "pequod"},
+		Status: v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{
+			KernelVersion:  "5.4.0",
+			KubeletVersion: "v1.18.2",
+		}},
+	}
+	worker := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "moby"},
+		Spec:       v1.PodSpec{NodeName: "pequod"},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:    "brigade-runner",
+				Image:   "brigadecore/brigade-worker:latest",
+				ImageID: "docker-pullable://brigadecore/brigade-worker@sha256:aaa",
+			}},
+			InitContainerStatuses: []v1.ContainerStatus{{
+				Name:    "vcs-sidecar",
+				Image:   "brigadecore/git-sidecar:latest",
+				ImageID: "docker-pullable://brigadecore/git-sidecar@sha256:bbb",
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(build, node)
+	controller := &Controller{Config: &Config{Namespace: v1.NamespaceDefault}, clientset: client}
+
+	if err := controller.recordEnvironmentSnapshot(build, &v1.Secret{}, worker); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := kube.NewBuildFromSecret(*secret).EnvironmentSnapshot
+	if s == nil {
+		t.Fatal("expected an environment snapshot")
+	}
+	if s.WorkerImageID != "docker-pullable://brigadecore/brigade-worker@sha256:aaa" || s.SidecarImage != "brigadecore/git-sidecar:latest" {
+		t.Errorf("unexpected images in %+v", s)
+	}
+	if s.Commit != "abc" || len(s.ScriptSHA256) != 64 {
+		t.Errorf("unexpected commit or script digest in %+v", s)
+	}
+	if s.KernelVersion != "5.4.0" || s.KubeletVersion != "v1.18.2" {
+		t.Errorf("unexpected node versions in %+v", s)
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// recordResourceUsage records the CPU and memory seconds of a completed
+// build on its secret. They are computed from the requests of the worker
+// pod and of the job pods in the project's job namespace, multiplied by how
+// long each pod ran.
+func (c *Controller) recordResourceUsage(build *v1.Secret, worker *v1.Pod, project *brigade.Project) error {
+	pods := []v1.Pod{*worker}
+	selector := "heritage=brigade,component=job,build=" + build.Labels["build"]
+	jobs, err := c.clientset.CoreV1().Pods(project.Kubernetes.JobPodNamespace()).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	pods = append(pods, jobs.Items...)
+
+	var cpuSeconds, memoryGBSeconds float64
+	for i := range pods {
+		cpu, memory := podUsage(&pods[i])
+		cpuSeconds += cpu
+		memoryGBSeconds += memory
+	}
+
+	buildCopy := build.DeepCopy()
+	if buildCopy.Annotations == nil {
+		buildCopy.Annotations = map[string]string{}
+	}
+	buildCopy.Annotations[kube.CPUSecondsAnnotation] = strconv.FormatFloat(cpuSeconds, 'f', 2, 64)
+	buildCopy.Annotations[kube.MemoryGBSecondsAnnotation] = strconv.FormatFloat(memoryGBSeconds, 'f', 2, 64)
+	_, err = c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// podUsage returns the CPU seconds and memory gigabyte seconds requested by
+// a completed pod. Pods that have not completed count for nothing.
+func podUsage(pod *v1.Pod) (cpuSeconds, memoryGBSeconds float64) {
+	if pod.Status.StartTime == nil {
+		return 0, 0
+	}
+	var end time.Time
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil && t.FinishedAt.After(end) {
+			end = t.FinishedAt.Time
+		}
+	}
+	if end.IsZero() {
+		return 0, 0
+	}
+	seconds := end.Sub(pod.Status.StartTime.Time).Seconds()
+
+	var cpu, memory float64
+	for _, container := range pod.Spec.Containers {
+		if q, ok := container.Resources.Requests[v1.ResourceCPU]; ok {
+			cpu += float64(q.MilliValue()) / 1000
+		}
+		if q, ok := container.Resources.Requests[v1.ResourceMemory]; ok {
+			memory += float64(q.Value()) / 1e9
+		}
+	}
+	return cpu * seconds, memory * seconds
+}
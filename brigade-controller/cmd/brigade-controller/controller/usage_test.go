@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func completedPod(name, namespace, component, cpu, memory string, ran time.Duration) *v1.Pod {
+	start := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"heritage": "brigade", "component": component, "build": "queequeg"},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			}},
+		}}},
+		Status: v1.PodStatus{
+			Phase:     v1.PodSucceeded,
+			StartTime: &start,
+			ContainerStatuses: []v1.ContainerStatus{{
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					FinishedAt: metav1.NewTime(start.Add(ran)),
+				}},
+			}},
+		},
+	}
+}
+
+func TestRecordResourceUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		project brigade.Kubernetes
+		jobsIn  string
+	}{
+		{"jobs in the project namespace", brigade.Kubernetes{Namespace: "builds"}, "builds"},
+		{"jobs in a job namespace", brigade.Kubernetes{Namespace: "builds", JobNamespace: "jobs"}, "jobs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "moby",
+					Namespace: v1.NamespaceDefault,
+					Labels:    map[string]string{"build": "queequeg"},
+				},
+			}
+			worker := completedPod("moby", v1.NamespaceDefault, "build", "500m", "1G", time.Minute)
+			job := completedPod("moby-test", tt.jobsIn, "job", "2", "4G", 30*time.Second)
+			client := fake.NewSimpleClientset(build, job)
+			controller := &Controller{Config: &Config{Namespace: v1.NamespaceDefault}, clientset: client}
+
+			project := &brigade.Project{Kubernetes: tt.project}
+			if err := controller.recordResourceUsage(build, worker, project); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), "moby", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 0.5 CPU for 60s plus 2 CPUs for 30s, 1GB for 60s plus 4GB for 30s.
+			if cpu := got.Annotations[kube.CPUSecondsAnnotation]; cpu != "90.00" {
+				t.Errorf("expected 90.00 CPU seconds, got %q", cpu)
+			}
+			if memory := got.Annotations[kube.MemoryGBSecondsAnnotation]; memory != "180.00" {
+				t.Errorf("expected 180.00 memory GB seconds, got %q", memory)
+			}
+		})
+	}
+}
+
+func TestPodUsage_Running(t *testing.T) {
+	pod := completedPod("moby", v1.NamespaceDefault, "build", "1", "1G", time.Minute)
+	pod.Status.ContainerStatuses = nil
+	if cpu, memory := podUsage(pod); cpu != 0 || memory != 0 {
+		t.Errorf("expected a running pod to count for nothing, got %v and %v", cpu, memory)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"flag"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/brigadecore/brigade/brigade-controller/cmd/brigade-controller/controller"
 
@@ -18,9 +20,10 @@ func init() {
 
 func main() {
 	var (
-		kubeconfig string
-		master     string
-		ctrConfig  controller.Config
+		kubeconfig     string
+		master         string
+		metricsAddress string
+		ctrConfig      controller.Config
 	)
 
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
@@ -38,8 +41,22 @@ func main() {
 	flag.StringVar(&ctrConfig.WorkerLimitsMemory, "worker-limits-memory", "", "kubernetes worker memory limits")
 	flag.StringVar(&ctrConfig.DefaultBuildStorageClass, "default-build-storage-class", defaultBuildStorageClass(), "default storage class to use for shared build storage")
 	flag.StringVar(&ctrConfig.DefaultCacheStorageClass, "default-cache-storage-class", defaultCacheStorageClass(), "default storage class to use for caching jobs")
+	flag.StringVar(&ctrConfig.HTTPProxy, "http-proxy", os.Getenv("BRIGADE_HTTP_PROXY"), "default proxy for cloning repositories over HTTP")
+	flag.StringVar(&ctrConfig.HTTPSProxy, "https-proxy", os.Getenv("BRIGADE_HTTPS_PROXY"), "default proxy for cloning repositories over HTTPS")
+	flag.StringVar(&ctrConfig.SMTP.Host, "smtp-host", os.Getenv("BRIGADE_SMTP_HOST"), "SMTP server for email notifications; email notifications are disabled if empty")
+	flag.IntVar(&ctrConfig.SMTP.Port, "smtp-port", defaultSMTPPort(), "SMTP server port")
+	flag.StringVar(&ctrConfig.SMTP.Username, "smtp-username", os.Getenv("BRIGADE_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&ctrConfig.SMTP.From, "smtp-from", os.Getenv("BRIGADE_SMTP_FROM"), "sender address of email notifications")
+	flag.StringVar(&ctrConfig.PreludeScript, "prelude-script", os.Getenv("BRIGADE_PRELUDE_SCRIPT"), "shell script run in the worker image before the script of every build")
+	flag.BoolVar(&ctrConfig.PreludeBlocking, "prelude-blocking", os.Getenv("BRIGADE_PRELUDE_BLOCKING") != "false", "fail builds whose prelude script fails")
+	flag.StringVar(&ctrConfig.PostludeScript, "postlude-script", os.Getenv("BRIGADE_POSTLUDE_SCRIPT"), "shell script run in the worker image after every build completes")
+	flag.StringVar(&metricsAddress, "metrics-address", defaultMetricsAddress(), "address to serve /metrics on; metrics are not served if empty")
 	flag.Parse()
 
+	// The password is only read from the environment to keep it out of the
+	// process list.
+	ctrConfig.SMTP.Password = os.Getenv("BRIGADE_SMTP_PASSWORD")
+
 	if ctrConfig.ProjectServiceAccountRegex == "" {
 		// No regex was given so only allow the default project service account
 		ctrConfig.ProjectServiceAccountRegex = ctrConfig.ProjectServiceAccount
@@ -60,6 +77,14 @@ func main() {
 	controller := controller.NewController(clientset, &ctrConfig)
 	log.Printf("Listening in namespace %q for new events", ctrConfig.Namespace)
 
+	if metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", controller.Metrics)
+		go func() {
+			log.Fatal(http.ListenAndServe(metricsAddress, mux))
+		}()
+	}
+
 	// Now let's start the controller
 	stop := make(chan struct{})
 	defer close(stop)
@@ -115,3 +140,17 @@ func defaultBuildStorageClass() string {
 func defaultCacheStorageClass() string {
 	return os.Getenv("BRIGADE_DEFAULT_CACHE_STORAGE_CLASS")
 }
+
+func defaultMetricsAddress() string {
+	if addr, ok := os.LookupEnv("BRIGADE_METRICS_ADDRESS"); ok {
+		return addr
+	}
+	return ":8080"
+}
+
+func defaultSMTPPort() int {
+	if port, err := strconv.Atoi(os.Getenv("BRIGADE_SMTP_PORT")); err == nil {
+		return port
+	}
+	return 587
+}
@@ -72,10 +72,20 @@ func newRouter(store storage.Store) *gin.Engine {
 	}
 
 	router.GET("/healthz", healthz)
+	router.GET("/metrics", metrics(store))
 
 	return router
 }
 
+// metrics reports the project cache in the Prometheus text format.
+func metrics(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		c.Status(http.StatusOK)
+		storage.WriteProjectCacheMetrics(c.Writer, store.ProjectCacheStats())
+	}
+}
+
 func healthz(c *gin.Context) {
 	c.String(http.StatusOK, http.StatusText(http.StatusOK))
 }
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	gin "gopkg.in/gin-gonic/gin.v1"
 
@@ -19,8 +27,15 @@ var (
 	kubeconfig string
 	master     string
 	namespace  string
+
+	// draining is set to 1 once the gateway stops accepting events.
+	draining int32
 )
 
+// defaultDrainTimeout leaves time to exit within the default termination
+// grace period of Kubernetes pods, 30 seconds.
+const defaultDrainTimeout = 25 * time.Second
+
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	flag.StringVar(&master, "master", "", "master url")
@@ -40,34 +55,133 @@ func main() {
 	}
 
 	store := kube.New(clientset, namespace)
+	webhook.ConfigureQueue(
+		envInt("BRIGADE_QUEUE_WORKERS", webhook.DefaultQueueWorkers),
+		envInt("BRIGADE_QUEUE_LENGTH", webhook.DefaultQueueLength),
+	)
+
+	listener, err := net.Listen("tcp", ":8000")
+	if err != nil {
+		log.Fatal(err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	server := &http.Server{Handler: newRouter(store)}
+	if err := serve(server, listener, signals, drainTimeout()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve serves events until a signal arrives. It then stops accepting events
+// and waits up to the drain timeout for the builds of the events it accepted
+// to be created.
+func serve(server *http.Server, listener net.Listener, signals <-chan os.Signal, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() { errs <- server.Serve(listener) }()
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Printf("Received %s, draining for up to %s", sig, timeout)
+	}
 
-	router := newRouter(store)
-	router.Run(":8000")
+	atomic.StoreInt32(&draining, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := webhook.Drain(ctx); err != nil {
+		return err
+	}
+	log.Print("Drained")
+	return nil
 }
 
 func newRouter(store storage.Store) *gin.Engine {
 	router := gin.New()
-	router.Use(gin.Recovery())
-
-	handlers := map[string]gin.HandlerFunc{
-		"/simpleevents/v1": webhook.NewGenericWebhookSimpleEvent(store),
-		"/cloudevents/v02": webhook.NewGenericWebhookCloudEvent(store),
-	}
+	router.Use(gin.Recovery(), rejectWhileDraining)
 
-	for endpoint, handler := range handlers {
-		events := router.Group(endpoint)
+	for _, eventType := range webhook.EventTypes() {
+		handler, _ := webhook.EventHandler(eventType, store)
+		events := router.Group("/" + eventType)
 		events.Use(gin.Logger())
 		events.POST("/:projectID/:secret", handler)
 	}
 
+	// Events named by their X-Brigade-Event header authenticate with a
+	// header rather than with a secret in the URL.
+	brigadeEvents := router.Group("/brigadeevents/v1")
+	brigadeEvents.Use(gin.Logger())
+	brigadeEvents.POST("/:projectID", webhook.NewGenericWebhookBrigadeEvent(store))
+
 	router.GET("/healthz", healthz)
+	router.GET("/metrics", metrics(store))
 	return router
 }
 
+// rejectWhileDraining answers requests still arriving on open connections
+// once the gateway stops accepting events, so that clients retry elsewhere.
+func rejectWhileDraining(c *gin.Context) {
+	if atomic.LoadInt32(&draining) == 1 {
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+	}
+}
+
 func healthz(c *gin.Context) {
 	c.String(http.StatusOK, http.StatusText(http.StatusOK))
 }
 
+// metrics reports the build queue and the project cache in the Prometheus
+// text format.
+func metrics(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := webhook.QueueStatistics()
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		c.Status(http.StatusOK)
+		w := c.Writer
+		fmt.Fprintf(w, "# HELP brigade_gateway_queue_depth Accepted builds waiting to be created.\n")
+		fmt.Fprintf(w, "# TYPE brigade_gateway_queue_depth gauge\n")
+		fmt.Fprintf(w, "brigade_gateway_queue_depth %d\n", stats.Depth)
+		fmt.Fprintf(w, "# HELP brigade_gateway_queue_busy_workers Workers creating builds.\n")
+		fmt.Fprintf(w, "# TYPE brigade_gateway_queue_busy_workers gauge\n")
+		fmt.Fprintf(w, "brigade_gateway_queue_busy_workers %d\n", stats.Busy)
+		fmt.Fprintf(w, "# HELP brigade_gateway_queue_rejected_total Events refused because the build queue was full.\n")
+		fmt.Fprintf(w, "# TYPE brigade_gateway_queue_rejected_total counter\n")
+		fmt.Fprintf(w, "brigade_gateway_queue_rejected_total %d\n", stats.Rejected)
+		storage.WriteProjectCacheMetrics(w, store.ProjectCacheStats())
+	}
+}
+
+// drainTimeout reads how long to wait for accepted events on shutdown from
+// BRIGADE_DRAIN_TIMEOUT, a duration such as "25s".
+func drainTimeout() time.Duration {
+	v, ok := os.LookupEnv("BRIGADE_DRAIN_TIMEOUT")
+	if !ok {
+		return defaultDrainTimeout
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout < 0 {
+		log.Fatalf("invalid BRIGADE_DRAIN_TIMEOUT %q", v)
+	}
+	return timeout
+}
+
+// envInt reads a positive number from the environment variable, or returns
+// the default if it is not set.
+func envInt(name string, def int) int {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Fatalf("invalid %s %q", name, v)
+	}
+	return n
+}
+
 func defaultNamespace() string {
 	if ns, ok := os.LookupEnv("BRIGADE_NAMESPACE"); ok {
 		return ns
@@ -3,10 +3,17 @@ package main
 import (
 	"bytes"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage/mock"
 )
 
@@ -33,6 +40,21 @@ func TestNewRouter(t *testing.T) {
 		t.Fatalf("Unexpected status on healthz: %s", res.Status)
 	}
 
+	res, err = http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"brigade_gateway_queue_rejected_total ", "brigade_project_cache_hits_total "} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("Expected %q in the metrics, got %s", want, metrics)
+		}
+	}
+
 	tests := []struct {
 		testfile string
 		route400 string
@@ -75,4 +97,94 @@ func TestNewRouter(t *testing.T) {
 		}
 	}
 
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/brigadeevents/v1/brigade-4625a05cf6914e556aa254cb2af234203744de2f", bytes.NewBufferString("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Brigade-Event", "deploy")
+	req.Header.Set("X-Brigade-Token", "Bearer mysecret2")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 401 {
+		t.Fatalf("Expected 401 status, got: %s", res.Status)
+	}
+
+}
+
+// slowStore creates builds once it is released.
+type slowStore struct {
+	*mock.Store
+	release chan struct{}
+	created int32
+}
+
+func (s *slowStore) CreateBuild(b *brigade.Build) error {
+	<-s.release
+	atomic.AddInt32(&s.created, 1)
+	return nil
+}
+
+func TestServeDrains(t *testing.T) {
+	defer atomic.StoreInt32(&draining, 0)
+
+	s := &slowStore{Store: mock.New(), release: make(chan struct{})}
+	s.ProjectList[0].ID = "brigade-4625a05cf6914e556aa254cb2af234203744de2f"
+	s.ProjectList[0].GenericGatewaySecret = "mysecret"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(&http.Server{Handler: newRouter(s)}, listener, signals, 5*time.Second)
+	}()
+
+	body, err := ioutil.ReadFile("./testdata/simpleevent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String() + "/simpleevents/v1/brigade-4625a05cf6914e556aa254cb2af234203744de2f/mysecret"
+	res, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 status, got: %s", res.Status)
+	}
+
+	// The build is still being created when the gateway is told to stop.
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned before the build was created: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(s.release)
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&s.created); n != 1 {
+		t.Errorf("expected the build to be created before serve returned, got %d builds", n)
+	}
+}
+
+func TestRejectWhileDraining(t *testing.T) {
+	defer atomic.StoreInt32(&draining, 0)
+
+	ts := httptest.NewServer(newRouter(mock.New()))
+	defer ts.Close()
+
+	atomic.StoreInt32(&draining, 1)
+	res, err := http.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 status while draining, got: %s", res.Status)
+	}
 }
@@ -5,7 +5,11 @@ import * as events from "@brigadecore/brigadier/out/events";
 import * as process from "process";
 import * as k8s from "./k8s";
 import * as brigadier from "./brigadier";
-import { Logger, ContextLogger } from "@brigadecore/brigadier/out/logger";
+import {
+  Logger,
+  ContextLogger,
+  LogLevel
+} from "@brigadecore/brigadier/out/logger";
 
 interface BuildStorage {
   create(
@@ -24,6 +28,66 @@ type ProjectLoader = (
   projectNS: string
 ) => Promise<events.Project>;
 
+/**
+ * SlowScriptHook is called with the wall-clock duration of a script, in
+ * milliseconds, when it exceeds the slow script threshold.
+ */
+export type SlowScriptHook = (duration: number, script: string) => void;
+
+/**
+ * DefaultSlowScriptThreshold is the default slow script threshold: 5 minutes.
+ */
+export const DefaultSlowScriptThreshold = 5 * 60 * 1000;
+
+/**
+ * DefaultKnownEvents are the events sent by Brigade itself, by its gateways
+ * and by brig. Events with a qualifier, such as "check_suite:requested", are
+ * known when the part before the ":" is.
+ */
+export const DefaultKnownEvents = [
+  "after",
+  "error",
+  "exec",
+  "ping",
+  "push",
+  "pull_request",
+  "tag",
+  "release",
+  "create",
+  "delete",
+  "deployment",
+  "deployment_status",
+  "issue_comment",
+  "check_suite",
+  "check_run",
+  "image_push",
+  "simpleevent",
+  "cloudevent"
+];
+
+/**
+ * parseLogLevel converts a BRIGADE_LOG_LEVEL value to a LogLevel.
+ *
+ * It accepts debug, info, warn and error in any case. "log", which brig has
+ * always sent, is the same as debug. Empty and unknown values fall back to
+ * info, so that scripts and build arguments are only logged when a build asks
+ * for debug.
+ */
+export function parseLogLevel(level: string | undefined): LogLevel {
+  switch ((level || "").toLowerCase()) {
+    case "info":
+      return LogLevel.INFO;
+    case "warn":
+      return LogLevel.WARN;
+    case "error":
+      return LogLevel.ERROR;
+    case "debug":
+    case "log":
+      return LogLevel.LOG;
+  }
+  return LogLevel.INFO;
+}
+
 /**
  * App is the main application.
  *
@@ -63,6 +127,26 @@ export class App {
 
   protected exitCode: number = 0;
 
+  /**
+   * script is the name of the script being run, as reported to slowScriptHook.
+   */
+  public script: string = "brigade.js";
+  /**
+   * slowScriptHook is called when a script runs for longer than the slow
+   * script threshold. By default it logs a warning.
+   */
+  public slowScriptHook: SlowScriptHook = (duration, script) => {
+    this.logger.warn(
+      `slow script: ${script} took ${Math.round(duration / 1000)}s (threshold ${Math.round(this.slowScriptThreshold / 1000)}s)`
+    );
+  };
+  protected slowScriptThreshold: number = DefaultSlowScriptThreshold;
+  /**
+   * knownEvents are the event names validateEventHandlers accepts.
+   */
+  public knownEvents: string[] = DefaultKnownEvents.slice();
+  protected startTime: number;
+
   /**
    * Create a new App.
    *
@@ -73,10 +157,51 @@ export class App {
     this.projectNS = projectNS;
   }
 
+  /**
+   * setSlowScriptThreshold sets the duration, in milliseconds, after which a
+   * script is reported to slowScriptHook.
+   */
+  public setSlowScriptThreshold(threshold: number): void {
+    this.slowScriptThreshold = threshold;
+  }
+
+  /**
+   * checkSlowScript calls slowScriptHook if the script has been running for
+   * longer than the slow script threshold. It returns true if it did.
+   */
+  public checkSlowScript(): boolean {
+    const duration = Date.now() - this.startTime;
+    if (duration <= this.slowScriptThreshold) {
+      return false;
+    }
+    this.slowScriptHook(duration, this.script);
+    return true;
+  }
+
+  /**
+   * validateEventHandlers returns a warning for each event the script has a
+   * handler for that is not one of knownEvents, which is most likely a typo
+   * such as "psuh". Custom events are legitimate, so these are never errors.
+   */
+  public validateEventHandlers(): string[] {
+    const known = new Set(this.knownEvents);
+    const warnings: string[] = [];
+    for (let name of brigadier.events.eventNames()) {
+      if (typeof name !== "string" || known.has(name.split(":")[0])) {
+        continue;
+      }
+      warnings.push(
+        `handler registered for unknown event "${name}"; check the event name for typos`
+      );
+    }
+    return warnings;
+  }
+
   /**
    * run runs a particular event for this app.
    */
   public run(e: events.BrigadeEvent): Promise<boolean> {
+    this.startTime = Date.now();
     this.lastEvent = e;
     this.logger.logLevel = e.logLevel;
 
@@ -129,7 +254,7 @@ export class App {
 
     // Run if an uncaught rejection happens.
     process.on("unhandledRejection", (reason: any, p: Promise<any>) => {
-      this.logger.error(reason);
+      this.logger.error(`${this.script}:`, reason);
       this.fireError(reason, "unhandledRejection");
     });
 
@@ -149,6 +274,7 @@ export class App {
       if (this.afterHasFired) {
         // So at this point, the after event has fired and we can cleanup.
         if (!this.storageIsDestroyed) {
+          this.checkSlowScript();
           this.logger.log("beforeExit(2): destroying storage");
           this.storageIsDestroyed = true;
           destroyStorage();
@@ -189,6 +315,14 @@ export class App {
         return this.buildStorage.create(e, p, p.kubernetes.buildStorageSize);
       })
       .then(() => {
+        for (let warning of this.validateEventHandlers()) {
+          this.logger.warn(warning);
+        }
+        // A script without a handler for the event is not an error; the
+        // build simply has nothing to do.
+        if (!brigadier.events.has(e.type)) {
+          this.logger.log(`no handler for event "${e.type}", skipping`);
+        }
         brigadier.fire(e, this.proj);
         return true;
       }); // We want to trigger the main rejection handler, so we do not catch().
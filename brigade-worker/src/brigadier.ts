@@ -50,9 +50,11 @@ export function fire(e: eventsImpl.BrigadeEvent, p: eventsImpl.Project) {
  */
 export class Job extends jobImpl.Job {
   jr: JobRunner;
+  lineHandlers: ((line: string) => void)[] = [];
 
   run(): Promise<jobImpl.Result> {
     this.jr = new JobRunner().init(this, currentEvent, currentProject, process.env.BRIGADE_SECRET_KEY_REF == 'true');
+    this.jr.lineHandlers = this.lineHandlers;
     this._podName = this.jr.name;
     return this.jr.run().catch(err => {
       // Wrap the message to give clear context.
@@ -65,6 +67,18 @@ export class Job extends jobImpl.Job {
   logs(): Promise<string> {
     return this.jr.logs();
   }
+
+  /**
+   * stream calls the handler with each line the job prints while it runs.
+   *
+   * Lines are followed from when the job's pod is running until it
+   * completes, so the last lines may be missed; the job's result holds its
+   * output once it completes. It must be called before run().
+   */
+  stream(handler: (line: string) => void): Job {
+    this.lineHandlers.push(handler);
+    return this;
+  }
 }
 
 
@@ -79,6 +93,70 @@ export class Group extends groupImpl.Group {
   // This seems to be how you expose an existing class as an export.
 }
 
+/**
+ * matrix runs one job per combination of the given values in parallel.
+ *
+ * The factory is called with each combination, such as `{go: "1.14", os:
+ * "linux"}`, and returns the job to run for it. Combinations are ordered by
+ * the sorted keys, then by the order of each key's values, and so are the
+ * results. Each job's pod is annotated with its combination, in the form
+ * `go=1.14,os=linux`.
+ *
+ * Unlike Group.runAll(), matrix waits for every job to finish, then rejects
+ * with an error naming each combination that failed.
+ */
+export function matrix(
+  values: { [key: string]: string[] },
+  factory: (combination: { [key: string]: string }) => jobImpl.Job
+): Promise<jobImpl.Result[]> {
+  let keys = Object.keys(values)
+    .filter(k => values[k].length > 0)
+    .sort();
+  let combinations: { [key: string]: string }[] = [{}];
+  for (let k of keys) {
+    let next = [];
+    for (let c of combinations) {
+      for (let v of values[k]) {
+        next.push(Object.assign({}, c, { [k]: v }));
+      }
+    }
+    combinations = next;
+  }
+
+  let names = combinations.map(c => keys.map(k => `${k}=${c[k]}`).join(","));
+  let errors: any[] = [];
+  let runs = combinations.map((c, i) => {
+    let job = factory(c);
+    job.annotations = Object.assign({}, job.annotations, {
+      [matrixAnnotation]: names[i]
+    });
+    return job.run().catch(err => {
+      console.error(`matrix ${names[i]}: ${err}`);
+      errors[i] = err;
+      return null;
+    });
+  });
+  return Promise.all(runs).then(results => {
+    let failures: string[] = [];
+    names.forEach((name, i) => {
+      if (i in errors) {
+        failures.push(`${name}: ${errors[i]}`);
+      }
+    });
+    if (failures.length > 0) {
+      throw new Error(
+        `matrix: ${failures.length} of ${runs.length} combinations failed:\n${failures.join("\n")}`
+      );
+    }
+    return results;
+  });
+}
+
+/**
+ * matrixAnnotation is the pod annotation holding a matrix job's combination.
+ */
+export const matrixAnnotation = "brigade.sh/matrix";
+
 /**
  * ErrorReport describes an error in the runtime handling of a Brigade script.
  */
@@ -0,0 +1,182 @@
+/**
+ * Module history describes what a build changes relative to earlier builds,
+ * so that scripts can gate work on it, e.g. notifying only on recovery or
+ * running slow tests only when sources changed.
+ */
+
+/**
+ * PreviousBuild is the last completed build of the same project and ref.
+ */
+export interface PreviousBuild {
+  id: string;
+  // status is "Succeeded" or "Failed".
+  status: string;
+  commit: string;
+}
+
+/**
+ * CommitRange is the range of commits a push event covers.
+ */
+export interface CommitRange {
+  before: string;
+  after: string;
+  // changedFiles are the files added, modified or removed by any of the
+  // commits, each listed once.
+  changedFiles: string[];
+  // forced is true for force pushes.
+  forced: boolean;
+  // incomplete is true if changedFiles may miss files, because the push was
+  // forced or its payload does not list all of its commits. Scripts can
+  // then run as if everything changed.
+  incomplete: boolean;
+}
+
+/**
+ * Person is the author or committer of a commit.
+ */
+export interface Person {
+  name: string;
+  email: string;
+}
+
+/**
+ * HeadCommit is the last commit of a push.
+ */
+export interface HeadCommit {
+  id: string;
+  message: string;
+  author: Person | null;
+  committer: Person | null;
+  // trailers are the "Key: value" lines closing the message, such as
+  // Signed-off-by, by key. A key may appear more than once.
+  trailers: { [key: string]: string[] };
+}
+
+/**
+ * previousBuild reads the previous build the controller passes to the worker,
+ * or returns null if there is none.
+ */
+export function previousBuild(env: NodeJS.ProcessEnv): PreviousBuild | null {
+  if (!env.BRIGADE_PREVIOUS_BUILD_ID) {
+    return null;
+  }
+  return {
+    id: env.BRIGADE_PREVIOUS_BUILD_ID,
+    status: env.BRIGADE_PREVIOUS_BUILD_STATUS || "",
+    commit: env.BRIGADE_PREVIOUS_COMMIT_ID || ""
+  };
+}
+
+/**
+ * commitRange reads the commit range of a push payload, as sent by GitHub and
+ * GitLab, or returns null if the payload is not a push.
+ */
+export function commitRange(payload: string): CommitRange | null {
+  const push = parsePush(payload);
+  if (!push) {
+    return null;
+  }
+
+  const commits: any[] = Array.isArray(push.commits) ? push.commits : [];
+  const changed = new Set<string>();
+  for (let commit of commits) {
+    for (let key of ["added", "modified", "removed"]) {
+      for (let file of (commit && commit[key]) || []) {
+        changed.add(file);
+      }
+    }
+  }
+  const forced = push.forced === true;
+  // GitLab lists the first 20 commits of a push, and counts them all.
+  const truncated =
+    typeof push.total_commits_count === "number" &&
+    push.total_commits_count > commits.length;
+  return {
+    before: push.before,
+    after: push.after,
+    changedFiles: Array.from(changed),
+    forced: forced,
+    incomplete: forced || truncated || !Array.isArray(push.commits)
+  };
+}
+
+/**
+ * headCommit reads the last commit of a push payload, as sent by GitHub and
+ * GitLab, or returns null if the payload is not a push or does not describe
+ * it.
+ */
+export function headCommit(payload: string): HeadCommit | null {
+  const push = parsePush(payload);
+  if (!push) {
+    return null;
+  }
+  // GitLab does not send head_commit, but lists it with the others.
+  let head = push.head_commit;
+  if (!head && Array.isArray(push.commits)) {
+    head = push.commits.find((c: any) => c && c.id === push.after);
+  }
+  if (!head || typeof head.id !== "string") {
+    return null;
+  }
+  const message = typeof head.message === "string" ? head.message : "";
+  return {
+    id: head.id,
+    message: message,
+    author: person(head.author),
+    committer: person(head.committer),
+    trailers: trailers(message)
+  };
+}
+
+/**
+ * trailers parses the "Key: value" lines of the last paragraph of a commit
+ * message, as git interpret-trailers does. A last paragraph with any other
+ * line has no trailers.
+ */
+export function trailers(message: string): { [key: string]: string[] } {
+  const result: { [key: string]: string[] } = {};
+  const paragraphs = message.trim().split(/\n\s*\n/);
+  if (paragraphs.length < 2) {
+    // A message of a single paragraph is all subject.
+    return result;
+  }
+  const lines = paragraphs[paragraphs.length - 1].split("\n");
+  const parsed = lines.map(line => /^([A-Za-z0-9-]+):\s*(.*\S)\s*$/.exec(line));
+  if (parsed.some(m => m === null)) {
+    return result;
+  }
+  for (let m of parsed) {
+    (result[m[1]] = result[m[1]] || []).push(m[2]);
+  }
+  return result;
+}
+
+function person(p: any): Person | null {
+  if (!p || typeof p !== "object") {
+    return null;
+  }
+  return { name: p.name || "", email: p.email || "" };
+}
+
+// parsePush parses a push payload, or returns null if the payload is not a
+// push.
+function parsePush(payload: string): any {
+  let push: any;
+  try {
+    push = JSON.parse(payload);
+  } catch (e) {
+    return null;
+  }
+  // The GitHub App gateway wraps the webhook's body.
+  if (push && push.body && typeof push.body === "object") {
+    push = push.body;
+  }
+  if (
+    !push ||
+    typeof push.before !== "string" ||
+    typeof push.after !== "string"
+  ) {
+    return null;
+  }
+  return push;
+}
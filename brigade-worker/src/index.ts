@@ -25,6 +25,14 @@
  *   for shared build storage if none is specified in project configuration.
  * - `BRIGADE_DEFAULT_CACHE_STORAGE_CLASS`: The Kubernetes StorageClass to use
  *   for caching jobs if none is specified in project configuration.
+ * - `BRIGADE_SLOW_SCRIPT_THRESHOLD`: The number of seconds after which a
+ *   script is reported as slow. Defaults to 300.
+ * - `BRIGADE_KNOWN_EVENTS`: A comma-separated list of custom events the
+ *   script may handle without a warning about an unknown event.
+ *
+ * Build arguments are read from the `build_args` key of the build secret and
+ * merged over the project's `defaultBuildArgs`. They are exposed to the
+ * script as `e.buildArgs`.
  *
  * Also, the Brigade script must be written to `brigade.js`.
  */
@@ -39,13 +47,24 @@ import * as process from "process";
 import * as ulid from "ulid";
 
 import * as events from "@brigadecore/brigadier/out/events";
-import { App } from "./app";
-import { ContextLogger, LogLevel } from "@brigadecore/brigadier/out/logger";
+import { App, parseLogLevel } from "./app";
+import {
+  commitRange,
+  CommitRange,
+  headCommit,
+  HeadCommit,
+  previousBuild,
+  PreviousBuild
+} from "./history";
+import { ContextLogger } from "@brigadecore/brigadier/out/logger";
 
 import { options } from "./k8s";
 
 // Script locations in order of precedence.
 const scripts = [
+  // project.BrigadejsPaths entry for the event type
+  process.env.BRIGADE_EVENT_SCRIPT,
+
   // manual override for debugging
   process.env.BRIGADE_SCRIPT,
 
@@ -64,7 +83,7 @@ const scripts = [
 
 function findScript() {
   for (let src of scripts) {
-    if (fs.existsSync(src) && fs.readFileSync(src, "utf8") != "") {
+    if (src && fs.existsSync(src) && fs.readFileSync(src, "utf8") != "") {
       return src;
     }
   }
@@ -112,7 +131,7 @@ if (script) {
 }
 
 // Log level may come in as lowercased 'log', 'info', etc., if run by the brig cli
-const logLevel = LogLevel[process.env.BRIGADE_LOG_LEVEL.toUpperCase() || "LOG"];
+const logLevel = parseLogLevel(process.env.BRIGADE_LOG_LEVEL);
 const logger = new ContextLogger([], logLevel);
 
 const version = require("../package.json").version;
@@ -129,7 +148,12 @@ const requiredEnvVar = (name: string): string => {
 const projectID: string = requiredEnvVar("BRIGADE_PROJECT_ID");
 const projectNamespace: string = requiredEnvVar("BRIGADE_PROJECT_NAMESPACE");
 const defaultULID = ulid().toLocaleLowerCase();
-let e: events.BrigadeEvent = {
+let e: events.BrigadeEvent & {
+  buildArgs: { [key: string]: string };
+  previousBuild: PreviousBuild | null;
+  commitRange: CommitRange | null;
+  headCommit: HeadCommit | null;
+} = {
   buildID: process.env.BRIGADE_BUILD_ID || defaultULID,
   workerID: process.env.BRIGADE_BUILD_NAME || `unknown-${defaultULID}`,
   type: process.env.BRIGADE_EVENT_TYPE || "ping",
@@ -139,7 +163,12 @@ let e: events.BrigadeEvent = {
     commit: process.env.BRIGADE_COMMIT_ID,
     ref: process.env.BRIGADE_COMMIT_REF
   },
-  logLevel: logLevel
+  logLevel: logLevel,
+  buildArgs: {},
+  // Defined even when null, so that scripts can test them on a first build.
+  previousBuild: previousBuild(process.env),
+  commitRange: null,
+  headCommit: null
 };
 
 try {
@@ -147,6 +176,42 @@ try {
 } catch (e) {
   logger.log("no payload loaded");
 }
+e.commitRange = commitRange(e.payload || "");
+e.headCommit = headCommit(e.payload || "");
+
+// Build arguments override the project defaults.
+for (let argsFile of [
+  "/etc/brigade-project/defaultBuildArgs",
+  "/etc/brigade/build_args"
+]) {
+  if (!fs.existsSync(argsFile)) {
+    continue;
+  }
+  let data = fs.readFileSync(argsFile, "utf8");
+  if (data.length > 0) {
+    Object.assign(e.buildArgs, JSON.parse(data));
+  }
+}
+
+// A missing event script is not fatal, so that the mapping can be set up
+// before the scripts are split out.
+const eventScript = process.env.BRIGADE_EVENT_SCRIPT;
+if (eventScript && script !== eventScript) {
+  logger.warn(
+    `script ${eventScript} for event "${e.type}" not found; falling back to ${script || "no script"}`
+  );
+}
+if (script) {
+  logger.info(`running ${script} for event "${e.type}"`);
+}
+
+// Only logged at the debug level.
+if (script) {
+  logger.log(`loaded ${script}:\n${fs.readFileSync(script, "utf8")}`);
+}
+for (let key of Object.keys(e.buildArgs)) {
+  logger.log(`build argument ${key}=${e.buildArgs[key]}`);
+}
 
 if (process.env.BRIGADE_SERVICE_ACCOUNT) {
   options.serviceAccount = process.env.BRIGADE_SERVICE_ACCOUNT;
@@ -168,4 +233,18 @@ if (process.env.BRIGADE_DEFAULT_CACHE_STORAGE_CLASS) {
 }
 
 // Run the app.
-new App(projectID, projectNamespace).run(e);
+const app = new App(projectID, projectNamespace);
+app.script = script;
+// Projects that send custom events can list them to silence the warnings
+// about unknown events.
+if (process.env.BRIGADE_KNOWN_EVENTS) {
+  app.knownEvents = app.knownEvents.concat(
+    process.env.BRIGADE_KNOWN_EVENTS.split(",").map(name => name.trim())
+  );
+}
+if (process.env.BRIGADE_SLOW_SCRIPT_THRESHOLD) {
+  app.setSlowScriptThreshold(
+    parseInt(process.env.BRIGADE_SLOW_SCRIPT_THRESHOLD, 10) * 1000
+  );
+}
+app.run(e);
@@ -105,10 +105,29 @@ export class KubernetesOptions {
   defaultCacheStorageClass: string;
 }
 
-class K8sResult implements jobs.Result {
+/**
+ * resultMaxBytes is the most output a job's result keeps. Scripts hold on to
+ * results, so a chatty job must not fill the worker's memory.
+ */
+export const resultMaxBytes = 1024 * 1024;
+
+/**
+ * K8sResult is the output of a job.
+ *
+ * Only the last resultMaxBytes of the output are kept, starting at a line, in
+ * which case truncated is set. The full output is in the job's logs.
+ */
+export class K8sResult implements jobs.Result {
   data: string;
+  truncated: boolean;
   constructor(msg: string) {
     this.data = msg;
+    this.truncated = false;
+    if (Buffer.byteLength(msg) > resultMaxBytes) {
+      let tail = Buffer.from(msg).slice(-resultMaxBytes).toString();
+      this.data = tail.slice(tail.indexOf("\n") + 1);
+      this.truncated = true;
+    }
   }
   toString(): string {
     return this.data;
@@ -153,6 +172,11 @@ export class BuildStorage {
         .then(() => {
           return this.name;
         })
+        .catch(reason => {
+          return Promise.reject(
+            namespaceError(this.proj.kubernetes.namespace, reason)
+          );
+        })
     );
   }
   /**
@@ -212,6 +236,23 @@ export class BuildStorage {
   }
 }
 
+/**
+ * namespaceError converts a failure to create a resource in the namespace ns
+ * into an Error. If the namespace does not exist, the error says so instead
+ * of relaying the API server's 404.
+ *
+ * This is exported for testability, and is not considered part of the stable API.
+ */
+export function namespaceError(ns: string, reason: any): Error {
+  const body = reason.body || {};
+  if (body.code == 404 && body.details && body.details.kind == "namespaces") {
+    return new Error(
+      `job namespace ${ns} does not exist: create it and grant the worker's service account access to it, or change the project's kubernetes.jobNamespace`
+    );
+  }
+  return new Error(body.message || reason);
+}
+
 /**
  * loadProject takes a Secret name and namespace and loads the Project
  * from the secret.
@@ -248,6 +289,8 @@ export class JobRunner implements jobs.JobRunner {
   pod: kubernetes.V1Pod;
   cancel: boolean;
   reconnect: boolean;
+  /** lineHandlers are called with each line the job prints while it runs. */
+  lineHandlers: ((line: string) => void)[] = [];
 
   constructor() { }
 
@@ -344,13 +387,26 @@ export class JobRunner implements jobs.JobRunner {
       }
     }
 
+    // Jobs join the build's trace unless they set their own trace context.
+    if (process.env.TRACEPARENT && !(job.env && "TRACEPARENT" in job.env)) {
+      envVars.push(envVar("TRACEPARENT", process.env.TRACEPARENT));
+    }
+
     this.runner.spec.containers[0].env = envVars;
 
     let mountPath = job.mountPath || this.options.mountPath;
 
-    // Add secret volume
+    // Add secret volume. Only the job's script is mounted, so that the
+    // clone credentials copied into the job's secret stay out of the job.
     this.runner.spec.volumes = [
-      { name: secName, secret: { secretName: secName } } as kubernetes.V1Volume
+      {
+        name: secName,
+        secret: {
+          secretName: secName,
+          items: [{ key: "main.sh", path: "main.sh" }],
+          optional: true
+        }
+      } as kubernetes.V1Volume
     ];
     this.runner.spec.containers[0].volumeMounts = [
       { name: secName, mountPath: "/hook" } as kubernetes.V1VolumeMount
@@ -358,12 +414,19 @@ export class JobRunner implements jobs.JobRunner {
 
     this.runner.spec.initContainers = [];
     if (job.useSource && project.repo.cloneURL && project.kubernetes.vcsSidecar) {
-      // Add the sidecar.
+      // Add the sidecar. The job may run outside of Brigade's namespace, where
+      // the project secret does not exist, so the sidecar reads the clone
+      // credentials from the job's secret.
+      let creds = cloneCredentials(project);
+      for (let key in creds) {
+        this.secret.data[key] = b64enc(creds[key]);
+      }
       let sidecar = sidecarSpec(
         e,
         "/src",
         project.kubernetes.vcsSidecar,
-        project
+        project,
+        secName
       );
       this.runner.spec.initContainers = [sidecar];
 
@@ -551,7 +614,7 @@ export class JobRunner implements jobs.JobRunner {
           resolve(this);
         })
         .catch(reason => {
-          reject(new Error(reason.body.message));
+          reject(namespaceError(ns, reason));
         });
     });
   }
@@ -694,7 +757,7 @@ export class JobRunner implements jobs.JobRunner {
         // make sure Pod is running before we start following its logs
         else if (phase == "Running") {
           // do that only if we haven't hooked up the follow request before
-          if (followLogsRequest == null && this.job.streamLogs) {
+          if (followLogsRequest == null && (this.job.streamLogs || this.lineHandlers.length > 0)) {
             followLogsRequest = followLogs(this.pod.metadata.namespace, this.pod.metadata.name);
           }
         } else if (phase == "Failed") {
@@ -757,20 +820,7 @@ export class JobRunner implements jobs.JobRunner {
           useQuerystring: true
         };
         kc.applyToRequest(requestOptions);
-        const stream = new byline_1.LineStream();
-        stream.on("data", data => {
-          let logs = null;
-          try {
-            if (data instanceof Buffer) {
-              logs = data.toString();
-            } else {
-              logs = data;
-            }
-            this.logger.log(
-              `${this.pod.metadata.namespace}/${this.pod.metadata.name} logs ${logs}`
-            );
-          } catch (e) { } //let it stay connected.
-        });
+        const stream = this.followLogStream();
         const req = request(requestOptions, (error, response, body) => {
           if (error) {
             if (error.body) {
@@ -795,6 +845,41 @@ export class JobRunner implements jobs.JobRunner {
 
     return Promise.race([poll, timer]);
   }
+  /**
+   * followLogStream returns a stream that splits the job's followed logs into
+   * lines. Each line is logged if the job streams its logs, and passed to
+   * the job's line handlers. A failing handler is logged, and does not stop
+   * the others or the job.
+   *
+   * This is exported for testability, and is not considered part of the stable API.
+   */
+  public followLogStream(): byline_1.LineStream {
+    const stream = new byline_1.LineStream();
+    stream.on("data", data => {
+      let logs = null;
+      try {
+        if (data instanceof Buffer) {
+          logs = data.toString();
+        } else {
+          logs = data;
+        }
+        if (this.job.streamLogs) {
+          this.logger.log(
+            `${this.pod.metadata.namespace}/${this.pod.metadata.name} logs ${logs}`
+          );
+        }
+      } catch (e) { } //let it stay connected.
+      for (let handler of this.lineHandlers) {
+        try {
+          handler(logs);
+        } catch (e) {
+          this.logger.error(`job ${this.job.name}: stream handler: ${e}`);
+        }
+      }
+    });
+    return stream;
+  }
+
   /**
    * cachePVC builds a persistent volume claim for storing a job's cache.
    *
@@ -832,11 +917,28 @@ export class JobRunner implements jobs.JobRunner {
   }
 }
 
+/**
+ * cloneCredentials returns the project's credentials for cloning its
+ * repository, keyed by their key in the job's secret.
+ */
+function cloneCredentials(project: Project): { [key: string]: string } {
+  let creds: { [key: string]: string } = {};
+  if (project.repo.sshKey) {
+    creds["vcs.sshKey"] = project.repo.sshKey;
+    creds["vcs.sshCert"] = project.repo.sshCert || "";
+  }
+  if (project.repo.token) {
+    creds["vcs.token"] = project.repo.token;
+  }
+  return creds;
+}
+
 function sidecarSpec(
   e: BrigadeEvent,
   local: string,
   image: string,
-  project: Project
+  project: Project,
+  secretName: string
 ): kubernetes.V1Container {
   var imageTag = image;
   let initGitSubmodules = project.repo.initGitSubmodules;
@@ -874,13 +976,35 @@ function sidecarSpec(
   (spec.imagePullPolicy = "IfNotPresent"),
     (spec.volumeMounts = [volumeMount("vcs-sidecar", local)]);
 
+  // Projects may clone from a git bundle instead of the repository. The
+  // key is optional, so projects without a bundle are unaffected.
+  spec.env.push({
+    name: "BRIGADE_BUNDLE_URI",
+    valueFrom: {
+      secretKeyRef: {
+        key: "bundleURI",
+        name: project.id,
+        optional: true
+      }
+    }
+  } as kubernetes.V1EnvVar);
+
+  // The controller passes the project's proxies for cloning through its own
+  // variables, so that they do not apply to the worker itself.
+  if (process.env.BRIGADE_VCS_HTTP_PROXY) {
+    spec.env.push(envVar("http_proxy", process.env.BRIGADE_VCS_HTTP_PROXY));
+  }
+  if (process.env.BRIGADE_VCS_HTTPS_PROXY) {
+    spec.env.push(envVar("https_proxy", process.env.BRIGADE_VCS_HTTPS_PROXY));
+  }
+
   if (project.repo.sshKey) {
     spec.env.push({
       name: "BRIGADE_REPO_KEY",
       valueFrom: {
         secretKeyRef: {
-          key: "sshKey",
-          name: project.id
+          key: "vcs.sshKey",
+          name: secretName
         }
       }
     } as kubernetes.V1EnvVar);
@@ -888,8 +1012,8 @@ function sidecarSpec(
       name: "BRIGADE_REPO_SSH_CERT",
       valueFrom: {
         secretKeyRef: {
-          key: "sshCert",
-          name: project.id
+          key: "vcs.sshCert",
+          name: secretName
         }
       }
     } as kubernetes.V1EnvVar);
@@ -901,8 +1025,8 @@ function sidecarSpec(
       name: "BRIGADE_REPO_AUTH_TOKEN",
       valueFrom: {
         secretKeyRef: {
-          key: "github.token",
-          name: project.id
+          key: "vcs.token",
+          name: secretName
         }
       }
     } as kubernetes.V1EnvVar);
@@ -1118,6 +1242,11 @@ export function secretToProject(
   if (secret.data.buildStorageSize) {
     p.kubernetes.buildStorageSize = b64dec(secret.data.buildStorageSize);
   }
+  // Jobs, their secrets and the build storage are created in the job
+  // namespace, which defaults to the namespace of the project secret.
+  if (secret.data["kubernetes.jobNamespace"]) {
+    p.kubernetes.namespace = b64dec(secret.data["kubernetes.jobNamespace"]);
+  }
   if (secret.data.cloneURL) {
     p.repo.cloneURL = b64dec(secret.data.cloneURL);
   }
@@ -1,6 +1,7 @@
 import "mocha";
 import { assert } from "chai";
 import * as events from "@brigadecore/brigadier/out/events";
+import { LogLevel } from "@brigadecore/brigadier/out/logger";
 import * as app from "../src/app";
 import * as mock from "./mock";
 import * as brigadier from "../src/brigadier";
@@ -31,8 +32,18 @@ describe("app", function() {
         a.run(e);
         done();
       });
+      it("runs the handlers of an event in registration order", function() {
+        let order: string[] = [];
+        brigadier.events.on("ordered", () => order.push("first"));
+        brigadier.events.on("ordered", () => order.push("second"));
+        let e = mock.mockEvent();
+        e.type = "ordered";
+        return a.run(e).then(() => {
+          assert.deepEqual(order, ["first", "second"]);
+        });
+      });
       context("when no event handler is registered", function() {
-        it("silently completes", function(done) {
+        it("completes without an error", function(done) {
           let e = mock.mockEvent();
           e.type = "no such event";
           a.run(e);
@@ -76,5 +87,66 @@ describe("app", function() {
         }); // turtles
       }); // all
     }); // the
+    describe("#validateEventHandlers", function() {
+      it("warns about handlers of unknown events", function() {
+        brigadier.events.on("psuh", () => {});
+        brigadier.events.on("push", () => {});
+        brigadier.events.on("check_suite:requested", () => {});
+        // Other tests register handlers too, so only look at these.
+        let warnings = a.validateEventHandlers().join("\n");
+        assert.include(warnings, '"psuh"');
+        assert.notInclude(warnings, '"push"');
+        assert.notInclude(warnings, '"check_suite:requested"');
+      });
+      it("accepts configured events", function() {
+        brigadier.events.on("deploy", () => {});
+        a.knownEvents = a.knownEvents.concat("deploy");
+        assert.notInclude(a.validateEventHandlers().join("\n"), '"deploy"');
+      });
+    });
+    describe("#checkSlowScript", function() {
+      it("calls the hook when the threshold is exceeded", function(done) {
+        let reported: string;
+        a.script = "/vcs/brigade.js";
+        a.slowScriptHook = (duration: number, script: string) => {
+          assert.isAbove(duration, 0);
+          reported = script;
+        };
+        a.setSlowScriptThreshold(0);
+        let e = mock.mockEvent();
+        e.type = "no such event";
+        a.run(e);
+        setTimeout(() => {
+          assert.isTrue(a.checkSlowScript());
+          assert.equal(reported, "/vcs/brigade.js");
+          done();
+        }, 5);
+      });
+      it("does not call the hook below the threshold", function() {
+        a.slowScriptHook = () => {
+          assert.fail("unexpected slow script report");
+        };
+        let e = mock.mockEvent();
+        e.type = "no such event";
+        a.run(e);
+        assert.isFalse(a.checkSlowScript());
+      });
+    });
   }); // way
+  describe("parseLogLevel", function() {
+    it("accepts the documented levels in any case", function() {
+      assert.equal(app.parseLogLevel("debug"), LogLevel.LOG);
+      assert.equal(app.parseLogLevel("INFO"), LogLevel.INFO);
+      assert.equal(app.parseLogLevel("Warn"), LogLevel.WARN);
+      assert.equal(app.parseLogLevel("error"), LogLevel.ERROR);
+    });
+    it("treats log as debug", function() {
+      assert.equal(app.parseLogLevel("log"), LogLevel.LOG);
+    });
+    it("treats empty and unknown levels as info", function() {
+      assert.equal(app.parseLogLevel(""), LogLevel.INFO);
+      assert.equal(app.parseLogLevel(undefined), LogLevel.INFO);
+      assert.equal(app.parseLogLevel("verbose"), LogLevel.INFO);
+    });
+  });
 }); // down
@@ -19,6 +19,9 @@ describe("brigadier", function() {
   it("has .Group", function() {
     assert.property(brigade, "Group");
   });
+  it("has #matrix", function() {
+    assert.property(brigade, "matrix");
+  });
   it("has .events", function() {
     assert.property(brigade, "events");
   });
@@ -120,4 +123,67 @@ describe("brigadier", function() {
       });
     });
   });
+
+  // Job tests
+  describe("Job", function() {
+    describe("#stream", function() {
+      it("registers line handlers", function() {
+        let lines: string[] = [];
+        let j = new brigade.Job("pequod", "whaler");
+        assert.strictEqual(j.stream(line => lines.push(line)), j);
+        j.lineHandlers.forEach(handler => handler("hello"));
+        assert.deepEqual(lines, ["hello"]);
+      });
+    });
+  });
+
+  // Matrix tests
+  describe("#matrix", function() {
+    it("runs a job per combination", function(done) {
+      let jobs: mock.MockJob[] = [];
+      brigade
+        .matrix({ os: ["linux", "darwin"], go: ["1.14", "1.15"] }, c => {
+          let j = new mock.MockJob(`test-${c.go}-${c.os}`);
+          jobs.push(j);
+          return j;
+        })
+        .then((rez: jobImpl.Result[]) => {
+          assert.deepEqual(rez.map(r => r.toString()), [
+            "test-1.14-linux",
+            "test-1.14-darwin",
+            "test-1.15-linux",
+            "test-1.15-darwin"
+          ]);
+          assert.equal(
+            jobs[1].annotations[brigade.matrixAnnotation],
+            "go=1.14,os=darwin"
+          );
+          done();
+        })
+        .catch(done);
+    });
+    context("when jobs fail", function() {
+      it("names each failed combination", function(done) {
+        let ran = 0;
+        brigade
+          .matrix({ go: ["1.14", "1.15", "1.16"] }, c => {
+            let j = new mock.MockJob(`test-${c.go}`);
+            j.fail = c.go != "1.15";
+            ran++;
+            return j;
+          })
+          .then(() => {
+            done("expected the matrix to fail");
+          })
+          .catch((err: Error) => {
+            assert.equal(ran, 3);
+            assert.equal(
+              err.message,
+              "matrix: 2 of 3 combinations failed:\ngo=1.14: Failed\ngo=1.16: Failed"
+            );
+            done();
+          });
+      });
+    });
+  });
 });
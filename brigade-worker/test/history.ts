@@ -0,0 +1,139 @@
+import "mocha";
+import { assert } from "chai";
+import * as history from "../src/history";
+
+describe("history", function() {
+  describe("previousBuild", function() {
+    it("reads the previous build", function() {
+      let b = history.previousBuild({
+        BRIGADE_PREVIOUS_BUILD_ID: "01e0000002",
+        BRIGADE_PREVIOUS_BUILD_STATUS: "Failed",
+        BRIGADE_PREVIOUS_COMMIT_ID: "bbb"
+      });
+      assert.deepEqual(b, {
+        id: "01e0000002",
+        status: "Failed",
+        commit: "bbb"
+      });
+    });
+    it("is null for the first build", function() {
+      assert.isNull(history.previousBuild({}));
+    });
+  });
+
+  describe("commitRange", function() {
+    it("flattens the changed files of a push", function() {
+      let payload = JSON.stringify({
+        before: "aaa",
+        after: "ccc",
+        commits: [
+          { added: ["src/new.ts"], modified: ["README.md"], removed: [] },
+          { added: [], modified: ["src/new.ts"], removed: ["src/old.ts"] }
+        ]
+      });
+      let r = history.commitRange(payload);
+      assert.equal(r.before, "aaa");
+      assert.equal(r.after, "ccc");
+      assert.sameMembers(r.changedFiles, [
+        "src/new.ts",
+        "README.md",
+        "src/old.ts"
+      ]);
+    });
+    it("unwraps GitHub App payloads", function() {
+      let payload = JSON.stringify({
+        token: "t",
+        body: { before: "aaa", after: "bbb" }
+      });
+      assert.deepEqual(history.commitRange(payload), {
+        before: "aaa",
+        after: "bbb",
+        changedFiles: [],
+        forced: false,
+        // Without commits, the changed files are unknown.
+        incomplete: true
+      });
+    });
+    it("flags force pushes and truncated commit lists", function() {
+      let forced = history.commitRange(
+        JSON.stringify({ before: "a", after: "b", forced: true, commits: [] })
+      );
+      assert.isTrue(forced.forced);
+      assert.isTrue(forced.incomplete);
+      let truncated = history.commitRange(
+        JSON.stringify({
+          before: "a",
+          after: "b",
+          total_commits_count: 21,
+          commits: [{ added: ["a.txt"] }]
+        })
+      );
+      assert.isFalse(truncated.forced);
+      assert.isTrue(truncated.incomplete);
+    });
+    it("is null for other payloads", function() {
+      assert.isNull(history.commitRange(""));
+      assert.isNull(history.commitRange("not json"));
+      assert.isNull(history.commitRange(JSON.stringify({ action: "opened" })));
+    });
+  });
+
+  describe("headCommit", function() {
+    it("reads GitHub's head commit", function() {
+      let payload = JSON.stringify({
+        before: "aaa",
+        after: "bbb",
+        head_commit: {
+          id: "bbb",
+          message:
+            "Fix the chart\n\nSigned-off-by: Ada <ada@example.com>\nCo-authored-by: Bob <bob@example.com>",
+          author: { name: "Ada", email: "ada@example.com", username: "ada" },
+          committer: { name: "GitHub", email: "noreply@github.com" }
+        }
+      });
+      let c = history.headCommit(payload);
+      assert.equal(c.id, "bbb");
+      assert.deepEqual(c.author, { name: "Ada", email: "ada@example.com" });
+      assert.equal(c.committer.name, "GitHub");
+      assert.deepEqual(c.trailers, {
+        "Signed-off-by": ["Ada <ada@example.com>"],
+        "Co-authored-by": ["Bob <bob@example.com>"]
+      });
+    });
+    it("finds GitLab's head commit among the commits", function() {
+      let payload = JSON.stringify({
+        before: "aaa",
+        after: "ccc",
+        commits: [
+          { id: "bbb", message: "first", author: { name: "Ada" } },
+          { id: "ccc", message: "second", author: { name: "Bob" } }
+        ]
+      });
+      let c = history.headCommit(payload);
+      assert.equal(c.message, "second");
+      assert.equal(c.author.name, "Bob");
+      assert.isNull(c.committer);
+    });
+    it("is null for other payloads", function() {
+      assert.isNull(history.headCommit(JSON.stringify({ action: "opened" })));
+    });
+  });
+
+  describe("trailers", function() {
+    it("ignores a last paragraph that is not all trailers", function() {
+      assert.deepEqual(
+        history.trailers("Subject\n\nSee: the docs\nfor details"),
+        {}
+      );
+    });
+    it("ignores single-paragraph messages", function() {
+      assert.deepEqual(history.trailers("Fixes: everything"), {});
+    });
+    it("collects repeated keys", function() {
+      assert.deepEqual(
+        history.trailers("Subject\n\nReviewed-by: Ada\nReviewed-by: Bob"),
+        { "Reviewed-by": ["Ada", "Bob"] }
+      );
+    });
+  });
+});
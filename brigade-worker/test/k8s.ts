@@ -66,6 +66,32 @@ describe("k8s", function () {
     });
   });
 
+  describe("secretToProject with a job namespace", function () {
+    it("creates jobs in the job namespace", function () {
+      let s = mockSecretVCS();
+      s.data["kubernetes.jobNamespace"] = "YnVpbGRz";
+      let p = k8s.secretToProject("default", s);
+      assert.equal(p.kubernetes.namespace, "builds");
+    });
+  });
+  describe("namespaceError", function () {
+    it("reports a missing namespace", function () {
+      let err = k8s.namespaceError("builds", {
+        body: {
+          code: 404,
+          message: 'namespaces "builds" not found',
+          details: { name: "builds", kind: "namespaces" }
+        }
+      });
+      assert.include(err.message, "job namespace builds does not exist");
+    });
+    it("relays other errors", function () {
+      let err = k8s.namespaceError("builds", {
+        body: { code: 403, message: "forbidden" }
+      });
+      assert.equal(err.message, "forbidden");
+    });
+  });
   describe("secretToProjectnoVCS", function () {
     it("converts secret to project - without a VCS", function () {
       let s = mockSecretnoVCS();
@@ -86,7 +112,81 @@ describe("k8s", function () {
     });
   });
 
+  describe("K8sResult", function () {
+    it("keeps short output", function () {
+      let r = new k8s.K8sResult("digest: sha256:c0ffee\n");
+      assert.equal(r.toString(), "digest: sha256:c0ffee\n");
+      assert.isFalse(r.truncated);
+    });
+    it("keeps the last lines of long output", function () {
+      let line = "x".repeat(1023) + "\n";
+      let r = new k8s.K8sResult(line.repeat(2048) + "digest: sha256:c0ffee\n");
+      assert.isTrue(r.truncated);
+      assert.isAtMost(Buffer.byteLength(r.toString()), k8s.resultMaxBytes);
+      assert.isTrue(r.toString().startsWith("x"));
+      assert.isTrue(r.toString().endsWith("digest: sha256:c0ffee\n"));
+    });
+  });
+
   describe("JobRunner", function () {
+    describe("#followLogStream", function () {
+      let jr: k8s.JobRunner;
+      let logged: string[];
+      let errors: string[];
+      beforeEach(function () {
+        let j = new mock.MockJob("pequod", "whaler", ["echo hello"]);
+        jr = new k8s.JobRunner().init(j, mock.mockEvent(), mock.mockProject());
+        jr.pod = { metadata: { namespace: "default", name: "pequod-1234" } } as kubernetes.V1Pod;
+        logged = [];
+        errors = [];
+        jr.logger.log = (msg: string) => logged.push(msg);
+        jr.logger.error = (msg: string) => errors.push(msg);
+      });
+      // follow writes the chunks to the job's log stream and calls done
+      // once every line was handled.
+      let follow = (chunks: string[], done: () => void) => {
+        let stream = jr.followLogStream();
+        stream.on("end", done);
+        for (let chunk of chunks) {
+          stream.write(chunk);
+        }
+        stream.end();
+      };
+      it("calls the handlers with each line", function (done) {
+        let lines: string[] = [];
+        jr.lineHandlers = [line => lines.push(line)];
+        follow(["call me ", "ishmael\nthar she ", "blows\n"], () => {
+          assert.deepEqual(lines, ["call me ishmael", "thar she blows"]);
+          done();
+        });
+      });
+      it("logs a failing handler and keeps calling the others", function (done) {
+        let lines: string[] = [];
+        jr.lineHandlers = [
+          line => {
+            throw new Error("stove");
+          },
+          line => lines.push(line)
+        ];
+        follow(["one\ntwo\n"], () => {
+          assert.deepEqual(lines, ["one", "two"]);
+          assert.lengthOf(errors, 2);
+          assert.include(errors[0], "stream handler: Error: stove");
+          done();
+        });
+      });
+      it("only logs the lines of jobs that stream their logs", function (done) {
+        jr.lineHandlers = [line => { }];
+        follow(["quiet\n"], () => {
+          assert.deepEqual(logged, []);
+          jr.job.streamLogs = true;
+          follow(["loud\n"], () => {
+            assert.deepEqual(logged, ["default/pequod-1234 logs loud"]);
+            done();
+          });
+        });
+      });
+    });
     describe("when constructed", function () {
       let j: Job;
       let p: Project;
@@ -319,6 +419,58 @@ describe("k8s", function () {
           assert.deepEqual(jr.runner.metadata.annotations, {});
         });
       });
+      it("passes the project's bundle URI to the sidecar", function () {
+        let jr = new k8s.JobRunner().init(j, e, p);
+        let sidecar = jr.runner.spec.initContainers[0];
+        let bundle = sidecar.env.find(v => v.name === "BRIGADE_BUNDLE_URI");
+        assert.deepEqual(bundle.valueFrom.secretKeyRef, {
+          key: "bundleURI",
+          name: p.id,
+          optional: true
+        } as kubernetes.V1SecretKeySelector);
+      });
+      context("when the build has a trace context", function () {
+        beforeEach(function () {
+          process.env.TRACEPARENT = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01";
+        });
+        afterEach(function () {
+          delete process.env.TRACEPARENT;
+        });
+        it("passes it to the job", function () {
+          let jr = new k8s.JobRunner().init(j, e, p);
+          let traceparent = jr.runner.spec.containers[0].env.find(v => v.name === "TRACEPARENT");
+          assert.equal(traceparent.value, process.env.TRACEPARENT);
+        });
+        it("lets the job set its own", function () {
+          j.env = { TRACEPARENT: "" };
+          let jr = new k8s.JobRunner().init(j, e, p);
+          let env = jr.runner.spec.containers[0].env.filter(v => v.name === "TRACEPARENT");
+          assert.lengthOf(env, 1);
+          assert.isUndefined(env[0].value);
+        });
+      });
+      context("when the controller passes proxies", function () {
+        beforeEach(function () {
+          process.env.BRIGADE_VCS_HTTP_PROXY = "http://proxy.example.com:3128";
+          process.env.BRIGADE_VCS_HTTPS_PROXY = "http://proxy.example.com:3129";
+        });
+        afterEach(function () {
+          delete process.env.BRIGADE_VCS_HTTP_PROXY;
+          delete process.env.BRIGADE_VCS_HTTPS_PROXY;
+        });
+        it("clones through them", function () {
+          let jr = new k8s.JobRunner().init(j, e, p);
+          let sidecar = jr.runner.spec.initContainers[0];
+          let env: { [name: string]: string } = {};
+          for (let v of sidecar.env) {
+            env[v.name] = v.value;
+          }
+          assert.equal(env["http_proxy"], "http://proxy.example.com:3128");
+          assert.equal(env["https_proxy"], "http://proxy.example.com:3129");
+          let main = jr.runner.spec.containers[0];
+          assert.isUndefined(main.env.find(v => v.name === "http_proxy"));
+        });
+      });
       context("when SSH key is provided", function () {
         beforeEach(function () {
           p.repo.sshKey = "SUPER SECRET";
@@ -326,7 +478,7 @@ describe("k8s", function () {
         it("attaches key to pod", function () {
           let jr = new k8s.JobRunner().init(j, e, p);
           let sidecar = jr.runner.spec.initContainers[0];
-          assert.equal(sidecar.env.length, 15);
+          assert.equal(sidecar.env.length, 16);
 
           let hasBrigadeRepoKey: boolean = false;
           for (let i of sidecar.env) {
@@ -337,6 +489,21 @@ describe("k8s", function () {
           }
           assert.isTrue(hasBrigadeRepoKey, "Has BRIGADE REPO KEY as param");
         });
+        it("reads it from the job's secret in the job namespace", function () {
+          p.kubernetes.namespace = "builds";
+          let jr = new k8s.JobRunner().init(j, e, p);
+          let sidecar = jr.runner.spec.initContainers[0];
+          for (let name of ["BRIGADE_REPO_KEY", "BRIGADE_REPO_SSH_CERT", "BRIGADE_REPO_AUTH_TOKEN"]) {
+            let ref = sidecar.env.find(v => v.name === name).valueFrom.secretKeyRef;
+            assert.equal(ref.name, jr.secret.metadata.name, name);
+            assert.property(jr.secret.data, ref.key, name);
+          }
+          assert.equal(jr.secret.data["vcs.sshKey"], k8s.b64enc("SUPER SECRET"));
+          // The job itself only sees its script.
+          assert.deepEqual(jr.runner.spec.volumes[0].secret.items, [
+            { key: "main.sh", path: "main.sh" }
+          ]);
+        });
       });
       context("when sidecar is disabled", function () {
         beforeEach(function () {
@@ -1,7 +1,23 @@
 #!/bin/sh
 set -euo pipefail
 
-set -x
+# One of debug, info, warn or error. brig sends "log" for debug.
+: "${BRIGADE_LOG_LEVEL:=info}"
+
+case "$(echo "${BRIGADE_LOG_LEVEL}" | tr '[:upper:]' '[:lower:]')" in
+  debug|log)
+    debug=true
+    ;;
+  *)
+    debug=false
+    ;;
+esac
+
+# Commands are not traced, as the remote URL they take may hold credentials.
+if [ "${debug}" = "true" ]; then
+  # Strip credentials from the remote URL before logging it.
+  echo "Cloning $(echo "${BRIGADE_REMOTE_URL}" | sed -E 's|://[^/@]*@|://|') at ${BRIGADE_COMMIT_REF:-${BRIGADE_COMMIT_ID:-}}"
+fi
 
 # retry solution discovered here: https://unix.stackexchange.com/a/137639
 
@@ -27,6 +43,80 @@ function retry {
   done
 }
 
+# Like retry, but with 3 attempts and an exponential backoff.
+function retry_backoff {
+  local n=1
+  local max=3
+  local delay=2
+  while true; do
+    "$@" && break || {
+      if test "$n" -lt "$max" ; then
+        echo "Command failed. Attempt $n/$max. Waiting for ${delay} seconds before retrying."
+        sleep "${delay}"
+        delay=$((delay*2))
+        n=$((n+1))
+      else
+        fail "The command has failed after $n attempts."
+      fi
+    }
+  done
+}
+
+# Reports the number and size of the downloaded LFS objects every 5 seconds
+# until it is killed.
+function lfs_progress {
+  while sleep 5; do
+    count=$(find .git/lfs/objects -type f 2>/dev/null | wc -l)
+    size=$(du -sh .git/lfs/objects 2>/dev/null | cut -f1)
+    echo "Downloaded ${count} LFS objects (${size:-0})"
+  done
+}
+
+# Pulls the LFS objects of the checkout with BRIGADE_LFS_CONCURRENCY parallel
+# transfers. The git-lfs of the image always asks the batch API for up to 100
+# objects at a time.
+function lfs_pull {
+  lfs_progress &
+  progress=$!
+  status=0
+  git -c lfs.concurrenttransfers="${BRIGADE_LFS_CONCURRENCY}" lfs pull || status=$?
+  kill "${progress}"
+  return "${status}"
+}
+
+# Prints the HTTPS URL of a bundle URI. Bucket objects are downloaded
+# anonymously, through the public endpoints of their object store.
+function bundle_url {
+  case "$1" in
+    s3://*)
+      bucket_path="${1#s3://}"
+      echo "https://${bucket_path%%/*}.s3.amazonaws.com/${bucket_path#*/}"
+      ;;
+    gs://*)
+      echo "https://storage.googleapis.com/${1#gs://}"
+      ;;
+    *)
+      echo "$1"
+      ;;
+  esac
+}
+
+# Fetches the branches and tags of the bundle at BRIGADE_BUNDLE_URI into the
+# current repository.
+function fetch_bundle {
+  bundle=$(mktemp)
+  case "${BRIGADE_BUNDLE_URI}" in
+    *://*)
+      retry curl -fsSL -o "${bundle}" "$(bundle_url "${BRIGADE_BUNDLE_URI}")"
+      ;;
+    *)
+      cp "${BRIGADE_BUNDLE_URI}" "${bundle}"
+      ;;
+  esac
+  git fetch -q --force --update-head-ok "${bundle}" '+refs/heads/*:refs/heads/*' '+refs/tags/*:refs/tags/*'
+  rm -f "${bundle}"
+}
+
 # The Git SHA1 of the revision.
 : "${BRIGADE_COMMIT_ID:=}"
 
@@ -40,17 +130,68 @@ function retry {
 # The working directory.
 : "${BRIGADE_WORKSPACE:=/src}"
 
-refspec="${BRIGADE_COMMIT_REF}"
-if full_ref=$(git ls-remote --exit-code "${BRIGADE_REMOTE_URL}" "${BRIGADE_COMMIT_REF}" | cut -f2); then
-  refspec="+${full_ref}:${full_ref}"
-fi
+# The number of LFS objects downloaded in parallel.
+: "${BRIGADE_LFS_CONCURRENCY:=4}"
+
+# The URI of a git bundle to clone instead of BRIGADE_REMOTE_URL, for
+# clusters without access to the repository. s3://, gs://, http(s):// and
+# local paths are supported.
+: "${BRIGADE_BUNDLE_URI:=}"
+
+# Comma-separated list of directories to check out.
+#
+# If not set, the whole repository is checked out.
+: "${BRIGADE_SPARSE_CHECKOUT_PATHS:=}"
 
 git init -q "${BRIGADE_WORKSPACE}"
 cd "${BRIGADE_WORKSPACE}"
 
-retry git fetch -q --force --update-head-ok "${BRIGADE_REMOTE_URL}" "${refspec}"
+if [ -n "${BRIGADE_BUNDLE_URI}" ]; then
+  fetch_bundle
+fi
 
-retry git checkout -q --force "${BRIGADE_COMMIT_REF}"
+# A commit that the bundle contains is built from the bundle, by its ID: the
+# bundle may not have the ref, or its copy of the ref may have moved on.
+# Otherwise the revision is fetched from the repository: refs move, so the
+# bundle's copy of a ref may be out of date.
+checkout="${BRIGADE_COMMIT_REF}"
+if [ -n "${BRIGADE_COMMIT_ID}" ] && git rev-parse -q --verify "${BRIGADE_COMMIT_ID}^{commit}" > /dev/null; then
+  checkout="${BRIGADE_COMMIT_ID}"
+else
+  refspec="${BRIGADE_COMMIT_REF}"
+  if full_ref=$(git ls-remote --exit-code "${BRIGADE_REMOTE_URL}" "${BRIGADE_COMMIT_REF}" | cut -f2); then
+    refspec="+${full_ref}:${full_ref}"
+  fi
+  if [ -n "${BRIGADE_BUNDLE_URI}" ] && git rev-parse -q --verify "${BRIGADE_COMMIT_REF}^{commit}" > /dev/null; then
+    # Clusters that cannot reach the repository build the bundle's copy.
+    git fetch -q --force --update-head-ok "${BRIGADE_REMOTE_URL}" "${refspec}" ||
+      echo "Warning: could not fetch ${BRIGADE_COMMIT_REF}, building it as of the bundle, which may be out of date" >&2
+  else
+    retry git fetch -q --force --update-head-ok "${BRIGADE_REMOTE_URL}" "${refspec}"
+  fi
+fi
+
+# Later fetches, for instance by submodules or scripts, use the repository.
+if [ -n "${BRIGADE_REMOTE_URL}" ]; then
+  git remote add origin "${BRIGADE_REMOTE_URL}"
+fi
+
+if [ -n "${BRIGADE_SPARSE_CHECKOUT_PATHS}" ]; then
+  # Cone mode always includes the files at the repository root. Git before
+  # 2.35, such as that of the image, only enables it through init.
+  git sparse-checkout init --cone
+  git sparse-checkout set $(echo "${BRIGADE_SPARSE_CHECKOUT_PATHS}" | tr ',' ' ')
+fi
+
+# The checkout leaves LFS pointer files, whose objects are then pulled in
+# parallel rather than one at a time by the smudge filter.
+git lfs install --local --skip-smudge > /dev/null
+
+retry git checkout -q --force "${checkout}"
+
+if [ -n "$(git lfs ls-files)" ]; then
+  retry_backoff lfs_pull
+fi
 
 if [ "${BRIGADE_SUBMODULES:=}" = "true" ]; then
     retry git submodule update --init --recursive
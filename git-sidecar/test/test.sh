@@ -53,6 +53,69 @@ test_clone() {
   rm -rf "${BRIGADE_WORKSPACE}"
 }
 
+# Checks out a local repository with two directories, of which only one is in
+# BRIGADE_SPARSE_CHECKOUT_PATHS.
+test_sparse_clone() {
+  local origin="${tempdir}/sparse.git"
+
+  git init -q "${origin}"
+  mkdir -p "${origin}/app" "${origin}/docs"
+  echo app > "${origin}/app/main.txt"
+  echo docs > "${origin}/docs/index.txt"
+  echo root > "${origin}/README.md"
+  git -C "${origin}" add .
+  git -C "${origin}" -c user.name=test -c user.email=test@example.com commit -q -m sparse
+  git -C "${origin}" branch -M master
+
+  BRIGADE_REMOTE_URL="file://${origin}" BRIGADE_COMMIT_REF="master" BRIGADE_SPARSE_CHECKOUT_PATHS="app" ./rootfs/clone.sh
+
+  check_equal "true" "$([[ -f ${BRIGADE_WORKSPACE}/app/main.txt ]] && echo true || echo false)" "app/ is checked out"
+  check_equal "true" "$([[ -f ${BRIGADE_WORKSPACE}/README.md ]] && echo true || echo false)" "root files are checked out"
+  check_equal "false" "$([[ -e ${BRIGADE_WORKSPACE}/docs ]] && echo true || echo false)" "docs/ is not checked out"
+
+  rm -rf "${BRIGADE_WORKSPACE}" "${origin}"
+}
+
+# Builds a ref from a bundle that predates the ref's latest commit, which must
+# be fetched from the repository, and a commit that the bundle contains.
+test_bundle_clone() {
+  local origin="${tempdir}/bundle.git" bundle="${tempdir}/repo.bundle"
+  local git="git -C ${origin} -c user.name=test -c user.email=test@example.com"
+
+  git init -q "${origin}"
+  $git commit -q --allow-empty -m bundled
+  $git branch -M master
+  local bundled="$($git rev-parse master)"
+  $git bundle create -q "${bundle}" --all
+  $git commit -q --allow-empty -m latest
+  local latest="$($git rev-parse master)"
+
+  BRIGADE_REMOTE_URL="file://${origin}" BRIGADE_BUNDLE_URI="${bundle}" BRIGADE_COMMIT_REF="master" ./rootfs/clone.sh
+  check_equal "${latest}" "$(git -C ${BRIGADE_WORKSPACE} rev-parse HEAD)" "a ref is fetched on top of the bundle"
+  rm -rf "${BRIGADE_WORKSPACE}"
+
+  BRIGADE_REMOTE_URL="file://${tempdir}/missing.git" BRIGADE_BUNDLE_URI="${bundle}" BRIGADE_COMMIT_ID="${bundled}" BRIGADE_COMMIT_REF="master" ./rootfs/clone.sh
+  check_equal "${bundled}" "$(git -C ${BRIGADE_WORKSPACE} rev-parse HEAD)" "a commit in the bundle is not fetched"
+  rm -rf "${BRIGADE_WORKSPACE}"
+
+  # The bundle's master has moved past the commit, and it has no pull refs.
+  $git bundle create -q "${bundle}" --all
+  for ref in master refs/pull/1/head; do
+    BRIGADE_REMOTE_URL="file://${tempdir}/missing.git" BRIGADE_BUNDLE_URI="${bundle}" BRIGADE_COMMIT_ID="${bundled}" BRIGADE_COMMIT_REF="${ref}" ./rootfs/clone.sh
+    check_equal "${bundled}" "$(git -C ${BRIGADE_WORKSPACE} rev-parse HEAD)" "a commit in the bundle is checked out by its ID for ${ref}"
+    rm -rf "${BRIGADE_WORKSPACE}"
+  done
+  rm -rf "${origin}" "${bundle}"
+}
+
+echo ":: Sparse checkout"
+test_sparse_clone
+echo
+
+echo ":: Bundle"
+test_bundle_clone
+echo
+
 setup_git_server
 
 echo ":: Checkout tag"
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// authorizeAdmin checks that the request carries the admin token as a bearer
+// token, and answers it otherwise. Without an admin token, the action is
+// disabled and the request is refused with the disabled message. It returns
+// whether the handler may go on.
+func authorizeAdmin(adminToken, disabled string, request *restful.Request, response *restful.Response) bool {
+	if adminToken == "" {
+		response.WriteErrorString(http.StatusForbidden, disabled)
+		return false
+	}
+	token := strings.TrimPrefix(request.HeaderParameter("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		response.WriteErrorString(http.StatusUnauthorized, "An admin token is required.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Approval represents the build approval api handlers.
+type Approval struct {
+	store      storage.Store
+	adminToken string
+}
+
+// Approval returns a handler for build approvals. Builds can only be approved
+// with the given admin token, and not at all if it is empty.
+func (api API) Approval(adminToken string) Approval {
+	return Approval{store: api.store, adminToken: adminToken}
+}
+
+// BuildApproval is where a build stands on approval.
+type BuildApproval struct {
+	Approval brigade.ApprovalStatus `json:"approval"`
+}
+
+// Get creates a new gin handler for the GET /build/:id/approval endpoint
+func (api Approval) Get(request *restful.Request, response *restful.Response) {
+	build, err := api.store.GetBuild(request.PathParameter("id"))
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	response.WriteEntity(BuildApproval{Approval: build.Approval})
+}
+
+// Approve creates a new gin handler for the POST /build/:id/approval endpoint
+//
+// It approves a build waiting for approval. The request must carry the admin
+// token as a bearer token.
+func (api Approval) Approve(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Approvals are disabled: the API has no admin token.", request, response) {
+		return
+	}
+
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	if build.Approval != brigade.ApprovalPending {
+		response.WriteErrorString(http.StatusConflict, "Build is not waiting for approval.")
+		return
+	}
+	if err := api.store.ApproveBuild(id); err == storage.ErrConflict {
+		response.WriteErrorString(http.StatusConflict, "The build was changed while it was approved. Try again.")
+		return
+	} else if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be approved.")
+		return
+	}
+	response.WriteEntity(BuildApproval{Approval: brigade.ApprovalApproved})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+// conflictingStore fails approvals as if the build changed meanwhile.
+type conflictingStore struct {
+	*mock.Store
+}
+
+func (s conflictingStore) ApproveBuild(id string) error {
+	return storage.ErrConflict
+}
+
+func TestApprovalApprove(t *testing.T) {
+	store := mock.New()
+	store.Builds[0].Approval = brigade.ApprovalPending
+	mockAPI := New(store)
+
+	approve := func(adminToken, authorization string) int {
+		httpRequest := httptest.NewRequest("POST", "/?a=b", bytes.NewBuffer(nil))
+		if authorization != "" {
+			httpRequest.Header.Set("Authorization", authorization)
+		}
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = store.Builds[0].ID
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.Approval(adminToken).Approve(req, respo)
+		return httpWriter.Code
+	}
+
+	if code := approve("", "Bearer "); code != http.StatusForbidden {
+		t.Errorf("expected approvals without an admin token to be disabled, got %d", code)
+	}
+	if code := approve("starbuck", "Bearer stubb"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", code)
+	}
+	if store.Builds[0].Approval != brigade.ApprovalPending {
+		t.Fatalf("expected the build to still wait for approval, got %q", store.Builds[0].Approval)
+	}
+	if code := approve("starbuck", "Bearer starbuck"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if store.Builds[0].Approval != brigade.ApprovalApproved {
+		t.Errorf("expected the build to be approved, got %q", store.Builds[0].Approval)
+	}
+	if code := approve("starbuck", "Bearer starbuck"); code != http.StatusConflict {
+		t.Errorf("expected an approved build to conflict, got %d", code)
+	}
+}
+
+func TestApprovalApprove_Conflict(t *testing.T) {
+	store := mock.New()
+	store.Builds[0].Approval = brigade.ApprovalPending
+	mockAPI := New(conflictingStore{store})
+
+	httpRequest := httptest.NewRequest("POST", "/?a=b", bytes.NewBuffer(nil))
+	httpRequest.Header.Set("Authorization", "Bearer starbuck")
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = store.Builds[0].ID
+	httpWriter := httptest.NewRecorder()
+	respo := restful.NewResponse(httpWriter)
+	respo.SetRequestAccepts("application/json")
+	mockAPI.Approval("starbuck").Approve(req, respo)
+	if httpWriter.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, httpWriter.Code)
+	}
+}
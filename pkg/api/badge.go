@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// badgeTemplate renders a flat badge. It takes the message, its color and
+// the message width, followed by the message again for the text.
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[3]d" height="20" role="img" aria-label="build: %[1]s">
+<title>build: %[1]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[3]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="37" height="20" fill="#555"/><rect x="37" width="%[4]d" height="20" fill="%[2]s"/><rect width="%[3]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="18.5" y="14">build</text><text x="%[5]d" y="14">%[1]s</text>
+</g>
+</svg>
+`
+
+type badge struct {
+	message, color string
+}
+
+var (
+	badgePassing = badge{"passing", "#4c1"}
+	badgeFailing = badge{"failing", "#e05d44"}
+	badgeUnknown = badge{"unknown", "#9f9f9f"}
+)
+
+func (b badge) render() string {
+	// Verdana at 11px averages about 7px per character, plus padding.
+	width := 7*len(b.message) + 10
+	return fmt.Sprintf(badgeTemplate, b.message, b.color, 37+width, width, 37+width/2)
+}
+
+// Badge creates a new handler for the GET /badge/{id}/{branch}.svg endpoint.
+//
+// It renders an SVG badge for the latest completed build of the branch.
+// Unknown projects and branches get an "unknown" badge rather than an
+// error, so that a README embedding the badge never shows a broken image.
+func (api Project) Badge(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	branch := strings.TrimSuffix(request.PathParameter("branch"), ".svg")
+
+	b := badgeUnknown
+	if proj, err := api.store.GetProject(id); err == nil {
+		if builds, err := api.store.GetProjectBuilds(proj); err == nil {
+			b = badgeFor(latestCompletedBuild(builds, branch))
+		}
+	}
+
+	// GitHub proxies README images through camo, which caches them unless
+	// told otherwise.
+	response.AddHeader("Content-Type", "image/svg+xml")
+	response.AddHeader("Cache-Control", "no-cache, no-store, must-revalidate")
+	response.AddHeader("Expires", "0")
+	response.WriteHeader(http.StatusOK)
+	response.Write([]byte(b.render()))
+}
+
+func latestCompletedBuild(builds []*brigade.Build, branch string) *brigade.Build {
+	var latest *brigade.Build
+	for _, b := range builds {
+		if b.Revision == nil || strings.TrimPrefix(b.Revision.Ref, "refs/heads/") != branch {
+			continue
+		}
+		if b.Worker == nil || (b.Worker.Status != brigade.JobSucceeded && b.Worker.Status != brigade.JobFailed) {
+			continue
+		}
+		if latest == nil || b.Worker.StartTime.After(latest.Worker.StartTime) {
+			latest = b
+		}
+	}
+	return latest
+}
+
+func badgeFor(build *brigade.Build) badge {
+	if build == nil {
+		return badgeUnknown
+	}
+	if build.Worker.Status == brigade.JobFailed {
+		return badgeFailing
+	}
+	return badgePassing
+}
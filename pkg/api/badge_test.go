@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestProjectBadge(t *testing.T) {
+	now := time.Now()
+	build := func(ref string, status brigade.JobStatus, start time.Time) *brigade.Build {
+		return &brigade.Build{
+			Revision: &brigade.Revision{Ref: ref},
+			Worker:   &brigade.Worker{Status: status, StartTime: start},
+		}
+	}
+	store := mock.New()
+	store.Builds = []*brigade.Build{
+		build("refs/heads/master", brigade.JobSucceeded, now.Add(-2*time.Hour)),
+		build("refs/heads/master", brigade.JobFailed, now.Add(-time.Hour)),
+		build("refs/heads/master", brigade.JobRunning, now),
+		build("refs/heads/feature/badges", brigade.JobSucceeded, now),
+	}
+
+	container := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/badge/{id}/{branch:*}").To(New(store).Project().Badge).Produces("image/svg+xml", "*/*"))
+	container.Add(ws)
+
+	for _, tt := range []struct {
+		path    string
+		message string
+	}{
+		{"/badge/project-id/master.svg", "failing"},
+		{"/badge/project-id/feature/badges.svg", "passing"},
+		{"/badge/project-id/develop.svg", "unknown"},
+		{"/badge/no-such-project/master.svg", "unknown"},
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept", "image/webp,image/*")
+		container.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.path, rw.Code)
+		}
+		if ct := rw.Header().Get("Content-Type"); ct != "image/svg+xml" {
+			t.Errorf("%s: expected an SVG content type, got %q", tt.path, ct)
+		}
+		if cc := rw.Header().Get("Cache-Control"); !strings.Contains(cc, "no-cache") {
+			t.Errorf("%s: expected the badge not to be cached, got %q", tt.path, cc)
+		}
+		if body := rw.Body.String(); !strings.Contains(body, "build: "+tt.message) {
+			t.Errorf("%s: expected a %s badge, got %s", tt.path, tt.message, body)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	restful "github.com/emicklei/go-restful"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage"
 )
 
@@ -26,6 +27,40 @@ func (api Build) Get(request *restful.Request, response *restful.Response) {
 	response.WriteEntity(build)
 }
 
+// BuildDiff is how the environments of two builds differ.
+type BuildDiff struct {
+	Build   string                            `json:"build"`
+	Compare string                            `json:"compare"`
+	Changes map[string]brigade.SnapshotChange `json:"changes"`
+}
+
+// Diff creates a new gin handler for the GET /build/:id/diff endpoint
+//
+// It compares the environment snapshot of the build with that of the build
+// given by the compare query parameter.
+func (api Build) Diff(request *restful.Request, response *restful.Response) {
+	compare := request.QueryParameter("compare")
+	if compare == "" {
+		response.WriteErrorString(http.StatusBadRequest, "The build to compare with is required.")
+		return
+	}
+	build, err := api.store.GetBuild(request.PathParameter("id"))
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	other, err := api.store.GetBuild(compare)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build to compare with could not be found.")
+		return
+	}
+	response.WriteEntity(BuildDiff{
+		Build:   build.ID,
+		Compare: other.ID,
+		Changes: build.EnvironmentSnapshot.Diff(other.EnvironmentSnapshot),
+	})
+}
+
 // Jobs creates a new gin handler for the GET /build/:id/jobs endpoint
 func (api Build) Jobs(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
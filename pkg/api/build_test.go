@@ -2,13 +2,16 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	restful "github.com/emicklei/go-restful"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage/mock"
 )
 
@@ -60,3 +63,38 @@ func TestBuildLogs(t *testing.T) {
 	}
 
 }
+
+func TestBuildDiff(t *testing.T) {
+	store := mock.New()
+	store.Builds[0].EnvironmentSnapshot = &brigade.EnvironmentSnapshot{WorkerImageID: "sha256:aaa", Commit: "abc"}
+	store.Builds[1].EnvironmentSnapshot = &brigade.EnvironmentSnapshot{WorkerImageID: "sha256:bbb", Commit: "abc"}
+	mockAPI := New(store)
+
+	diff := func(query string) *httptest.ResponseRecorder {
+		httpRequest := httptest.NewRequest("GET", "/"+query, bytes.NewBuffer(nil))
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = store.Builds[0].ID
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.Build().Diff(req, respo)
+		return httpWriter
+	}
+
+	if w := diff("?a=b"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d without a build to compare with, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := diff("?compare=" + store.Builds[1].ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	got := BuildDiff{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]brigade.SnapshotChange{"worker_image_id": {From: "sha256:aaa", To: "sha256:bbb"}}
+	if got.Compare != store.Builds[1].ID || !reflect.DeepEqual(got.Changes, want) {
+		t.Errorf("expected changes %v against %s, got %+v", want, store.Builds[1].ID, got)
+	}
+}
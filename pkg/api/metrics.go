@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Metrics creates a new gin handler for the GET /metrics endpoint
+//
+// It reports the hits and misses of the project cache in the Prometheus text
+// format.
+func (api API) Metrics(request *restful.Request, response *restful.Response) {
+	response.AddHeader("Content-Type", "text/plain; version=0.0.4")
+	response.WriteHeader(http.StatusOK)
+	storage.WriteProjectCacheMetrics(response, api.store.ProjectCacheStats())
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestMetrics(t *testing.T) {
+	mockAPI := New(mock.New())
+
+	httpRequest := httptest.NewRequest("GET", "/?a=b", bytes.NewBuffer(nil))
+	req := restful.NewRequest(httpRequest)
+	httpWriter := httptest.NewRecorder()
+	respo := restful.NewResponse(httpWriter)
+
+	mockAPI.Metrics(req, respo)
+	body := httpWriter.Body.String()
+	for _, want := range []string{"brigade_project_cache_hits_total 0\n", "brigade_project_cache_misses_total 0\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in %q", want, body)
+		}
+	}
+}
@@ -63,6 +63,9 @@ func (api Project) getBuildSummariesForProjects(projects []*brigade.Project) []*
 }
 
 // Get creates a new gin handler for the GET /project/:id endpoint
+//
+// The project's ETag, to send when replacing it, is set unless the store
+// does not version projects.
 func (api Project) Get(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 	proj, err := api.store.GetProject(id)
@@ -70,6 +73,9 @@ func (api Project) Get(request *restful.Request, response *restful.Response) {
 		response.WriteErrorString(http.StatusNotFound, "No Project found.")
 		return
 	}
+	if proj.ResourceVersion != "" {
+		response.AddHeader("ETag", projectETag(proj))
+	}
 	response.WriteHeaderAndEntity(http.StatusOK, proj)
 }
 
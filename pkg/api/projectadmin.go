@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// ProjectAdmin represents the api handlers that change projects.
+type ProjectAdmin struct {
+	store      storage.Store
+	adminToken string
+}
+
+// ProjectAdmin returns a handler for changing projects. Projects can only be
+// changed with the given admin token, and not at all if it is empty.
+func (api API) ProjectAdmin(adminToken string) ProjectAdmin {
+	return ProjectAdmin{store: api.store, adminToken: adminToken}
+}
+
+// projectETag returns the ETag of a project: its quoted storage version.
+func projectETag(proj *brigade.Project) string {
+	return `"` + proj.ResourceVersion + `"`
+}
+
+// Replace creates a new gin handler for the PUT /project/:id endpoint
+//
+// It replaces the project with the one in the body. The request must carry
+// the admin token as a bearer token, and the project's ETag, as returned by
+// GET /project/:id, in an If-Match header. If the project changed since, it
+// responds with 409 Conflict. The fields never shown by the API, such as
+// credentials and redacted secrets, keep their stored values.
+func (api ProjectAdmin) Replace(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Changing projects is disabled: the API has no admin token.", request, response) {
+		return
+	}
+	// A weak ETag names the same version.
+	etag := strings.TrimPrefix(request.HeaderParameter("If-Match"), "W/")
+	if etag == "" {
+		response.WriteErrorString(http.StatusPreconditionRequired, "The If-Match header with the project's ETag is required.")
+		return
+	}
+
+	id := request.PathParameter("id")
+	// The cached project may be older than the ETag.
+	api.store.InvalidateProjectCache(id)
+	current, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	if etag != projectETag(current) {
+		response.WriteErrorString(http.StatusConflict, "The project was changed since it was read.")
+		return
+	}
+
+	proj := &brigade.Project{}
+	if err := request.ReadEntity(proj); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "Malformed project.")
+		return
+	}
+	if proj.Name != current.Name {
+		response.WriteErrorString(http.StatusBadRequest, "The project's name cannot be changed.")
+		return
+	}
+	proj.ID = current.ID
+	proj.ResourceVersion = current.ResourceVersion
+	proj.KeepCredentials(current)
+
+	// The store checks the version again, in case the project changed since
+	// it was loaded.
+	if err := api.store.ReplaceProject(proj); err == storage.ErrConflict {
+		response.WriteErrorString(http.StatusConflict, "The project was changed since it was read.")
+		return
+	} else if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Project could not be replaced.")
+		return
+	}
+	if updated, err := api.store.GetProject(id); err == nil {
+		proj = updated
+		response.AddHeader("ETag", projectETag(proj))
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, proj)
+}
+
+// InvalidateCache creates a new gin handler for the GET /project/:id/cache/invalidate endpoint
+//
+// It drops the project from the API's project cache and loads it again. The
+// request must carry the admin token as a bearer token.
+func (api ProjectAdmin) InvalidateCache(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Invalidating projects is disabled: the API has no admin token.", request, response) {
+		return
+	}
+	id := request.PathParameter("id")
+	api.store.InvalidateProjectCache(id)
+	proj, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, proj)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestProjectAdminReplace(t *testing.T) {
+	store := mock.New()
+	store.ProjectList = []*brigade.Project{{
+		ID:              mock.StubProject.ID,
+		Name:            mock.StubProject.Name,
+		SharedSecret:    "shh",
+		Secrets:         brigade.SecretsMap{"key": "value"},
+		ResourceVersion: "7",
+	}}
+	mockAPI := New(store)
+
+	replace := func(authorization, ifMatch, body string) *httptest.ResponseRecorder {
+		httpRequest := httptest.NewRequest("PUT", "/", bytes.NewBufferString(body))
+		httpRequest.Header.Set("Content-Type", "application/json")
+		httpRequest.Header.Set("Authorization", authorization)
+		if ifMatch != "" {
+			httpRequest.Header.Set("If-Match", ifMatch)
+		}
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = mock.StubProject.ID
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.ProjectAdmin("starbuck").Replace(req, respo)
+		return httpWriter
+	}
+	body := `{"name": "project-name", "secrets": {"key": "REDACTED"}, "defaultScript": "new"}`
+
+	if code := replace("Bearer stubb", `"7"`, body).Code; code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", code)
+	}
+	if code := replace("Bearer starbuck", "", body).Code; code != http.StatusPreconditionRequired {
+		t.Errorf("expected a missing If-Match header to be refused, got %d", code)
+	}
+	if code := replace("Bearer starbuck", `"6"`, body).Code; code != http.StatusConflict {
+		t.Errorf("expected a stale ETag to conflict, got %d", code)
+	}
+	renamed := `{"name": "other-name"}`
+	if code := replace("Bearer starbuck", `"7"`, renamed).Code; code != http.StatusBadRequest {
+		t.Errorf("expected a rename to be refused, got %d", code)
+	}
+	if store.ProjectList[0].DefaultScript != "" {
+		t.Fatal("expected the project to be unchanged")
+	}
+
+	resp := replace("Bearer starbuck", `W/"7"`, body)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+	if etag := resp.Header().Get("ETag"); etag != `"7"` {
+		t.Errorf("expected the new ETag, got %q", etag)
+	}
+	proj := store.ProjectList[0]
+	if proj.DefaultScript != "new" {
+		t.Errorf("expected the project to be replaced, got %+v", proj)
+	}
+	if proj.SharedSecret != "shh" || proj.Secrets["key"] != "value" {
+		t.Errorf("expected the credentials to be kept, got %+v", proj)
+	}
+}
+
+func TestProjectAdminInvalidateCache(t *testing.T) {
+	mockAPI := New(mock.New())
+
+	invalidate := func(adminToken, authorization string) int {
+		httpRequest := httptest.NewRequest("GET", "/", nil)
+		httpRequest.Header.Set("Authorization", authorization)
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = mock.StubProject.ID
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.ProjectAdmin(adminToken).InvalidateCache(req, respo)
+		return httpWriter.Code
+	}
+
+	if code := invalidate("", "Bearer "); code != http.StatusForbidden {
+		t.Errorf("expected invalidation without an admin token to be disabled, got %d", code)
+	}
+	if code := invalidate("starbuck", "Bearer stubb"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", code)
+	}
+	if code := invalidate("starbuck", "Bearer starbuck"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+}
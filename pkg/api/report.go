@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// CostRates are the prices used to estimate the cost of builds.
+type CostRates struct {
+	// CPUPerSecond is the price of one requested CPU for one second.
+	CPUPerSecond float64
+	// MemoryPerGBSecond is the price of one requested gigabyte of memory
+	// for one second.
+	MemoryPerGBSecond float64
+}
+
+// Report represents the report api handlers.
+type Report struct {
+	store storage.Store
+	rates CostRates
+}
+
+// Report returns a handler for reports that estimates costs with the given rates.
+func (api API) Report(rates CostRates) Report { return Report{store: api.store, rates: rates} }
+
+// CostReport is the cost of the builds of a month.
+type CostReport struct {
+	// Period is the month, in the form 2006-01.
+	Period  string      `json:"period"`
+	Entries []CostEntry `json:"entries"`
+}
+
+// CostEntry is the cost of the builds of a project and cost center.
+type CostEntry struct {
+	ProjectID       string  `json:"project_id"`
+	Project         string  `json:"project"`
+	CostCenter      string  `json:"cost_center"`
+	Builds          int     `json:"builds"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	MemoryGBSeconds float64 `json:"memory_gb_seconds"`
+	EstimatedCost   float64 `json:"estimated_cost"`
+}
+
+// Costs creates a new handler for the GET /reports/costs endpoint
+//
+// It reports the completed builds that started in the month given by the
+// period query parameter, grouped by project and cost center.
+func (api Report) Costs(request *restful.Request, response *restful.Response) {
+	period := request.QueryParameter("period")
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "The period must be a month, such as 2020-01.")
+		return
+	}
+	end := start.AddDate(0, 1, 0)
+
+	builds, err := api.store.GetBuilds()
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Builds could not be loaded.")
+		return
+	}
+	projects, err := api.store.GetProjects()
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Projects could not be loaded.")
+		return
+	}
+	names := map[string]string{}
+	for _, p := range projects {
+		names[p.ID] = p.Name
+	}
+
+	type group struct{ projectID, costCenter string }
+	entries := map[group]*CostEntry{}
+	for _, b := range builds {
+		w := b.Worker
+		if w == nil || (w.Status != brigade.JobSucceeded && w.Status != brigade.JobFailed) {
+			continue
+		}
+		if w.StartTime.Before(start) || !w.StartTime.Before(end) {
+			continue
+		}
+		g := group{b.ProjectID, b.CostCenter}
+		e, ok := entries[g]
+		if !ok {
+			e = &CostEntry{ProjectID: b.ProjectID, Project: names[b.ProjectID], CostCenter: b.CostCenter}
+			entries[g] = e
+		}
+		e.Builds++
+		if !w.EndTime.IsZero() {
+			e.DurationSeconds += w.EndTime.Sub(w.StartTime).Seconds()
+		}
+		e.CPUSeconds += b.CPUSeconds
+		e.MemoryGBSeconds += b.MemoryGBSeconds
+	}
+
+	report := CostReport{Period: period, Entries: []CostEntry{}}
+	for _, e := range entries {
+		e.EstimatedCost = e.CPUSeconds*api.rates.CPUPerSecond + e.MemoryGBSeconds*api.rates.MemoryPerGBSecond
+		report.Entries = append(report.Entries, *e)
+	}
+	sort.Slice(report.Entries, func(i, j int) bool {
+		a, b := report.Entries[i], report.Entries[j]
+		if a.ProjectID != b.ProjectID {
+			return a.ProjectID < b.ProjectID
+		}
+		return a.CostCenter < b.CostCenter
+	})
+	response.WriteEntity(report)
+}
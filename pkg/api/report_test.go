@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestReportCosts(t *testing.T) {
+	build := func(project, costCenter string, status brigade.JobStatus, start time.Time) *brigade.Build {
+		return &brigade.Build{
+			ProjectID:       project,
+			CostCenter:      costCenter,
+			CPUSeconds:      100,
+			MemoryGBSeconds: 200,
+			Worker:          &brigade.Worker{Status: status, StartTime: start, EndTime: start.Add(time.Minute)},
+		}
+	}
+	january := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	store := mock.New()
+	store.ProjectList = []*brigade.Project{{ID: "brigade-1234", Name: "tennyson/light-brigade"}}
+	store.Builds = []*brigade.Build{
+		build("brigade-1234", "poetry", brigade.JobSucceeded, january),
+		build("brigade-1234", "poetry", brigade.JobFailed, january),
+		build("brigade-1234", "", brigade.JobSucceeded, january),
+		build("brigade-1234", "poetry", brigade.JobRunning, january),
+		build("brigade-1234", "poetry", brigade.JobSucceeded, january.AddDate(0, 1, 0)),
+	}
+
+	container := restful.NewContainer()
+	ws := new(restful.WebService)
+	rates := CostRates{CPUPerSecond: 0.01, MemoryPerGBSecond: 0.001}
+	ws.Route(ws.GET("/reports/costs").To(New(store).Report(rates).Costs).Produces(restful.MIME_JSON))
+	container.Add(ws)
+
+	rw := httptest.NewRecorder()
+	container.ServeHTTP(rw, httptest.NewRequest("GET", "/reports/costs?period=2020-01", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	var report CostReport
+	if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	expected := []CostEntry{
+		{ProjectID: "brigade-1234", Project: "tennyson/light-brigade", Builds: 1, DurationSeconds: 60, CPUSeconds: 100, MemoryGBSeconds: 200, EstimatedCost: 1.2},
+		{ProjectID: "brigade-1234", Project: "tennyson/light-brigade", CostCenter: "poetry", Builds: 2, DurationSeconds: 120, CPUSeconds: 200, MemoryGBSeconds: 400, EstimatedCost: 2.4},
+	}
+	if !reflect.DeepEqual(report.Entries, expected) {
+		t.Errorf("expected entries %+v, got %+v", expected, report.Entries)
+	}
+
+	rw = httptest.NewRecorder()
+	container.ServeHTTP(rw, httptest.NewRequest("GET", "/reports/costs?period=january", nil))
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid period, got %d", rw.Code)
+	}
+}
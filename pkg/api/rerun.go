@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Rerun represents the build rerun api handlers.
+type Rerun struct {
+	store      storage.Store
+	adminToken string
+}
+
+// Rerun returns a handler for build reruns. Builds can only be rerun with the
+// given admin token, and not at all if it is empty.
+func (api API) Rerun(adminToken string) Rerun {
+	return Rerun{store: api.store, adminToken: adminToken}
+}
+
+// Create creates a new gin handler for the POST /build/:id/rerun endpoint
+//
+// It resubmits the build with the same project, event, revision, payload and
+// script under a new build ID, linked back to the original build. The request
+// must carry the admin token as a bearer token.
+func (api Rerun) Create(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Reruns are disabled: the API has no admin token.", request, response) {
+		return
+	}
+
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+
+	rerun := *build
+	rerun.ID = ""
+	rerun.Worker = nil
+	rerun.ParentBuildID = build.ID
+	// The rerun was neither requested with the original's idempotency key
+	// nor created by a flaky retry.
+	rerun.IdempotencyKey = ""
+	rerun.FlakyRetry = 0
+	if build.Revision != nil {
+		revision := *build.Revision
+		rerun.Revision = &revision
+	} else {
+		rerun.Revision = &brigade.Revision{}
+	}
+
+	if err := api.store.CreateBuild(&rerun); err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be rerun.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, &rerun)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestRerunCreate(t *testing.T) {
+	store := mock.New()
+	original := &brigade.Build{
+		ID:             "01a",
+		ProjectID:      mock.StubProject.ID,
+		Type:           "push",
+		Revision:       &brigade.Revision{Commit: "aaa"},
+		Worker:         &brigade.Worker{Status: brigade.JobFailed},
+		IdempotencyKey: "deploy-42",
+		FlakyRetry:     1,
+	}
+	store.Builds = []*brigade.Build{original}
+	mockAPI := New(store)
+
+	rerun := func(adminToken, authorization, id string) int {
+		httpRequest := httptest.NewRequest("POST", "/", bytes.NewBuffer(nil))
+		httpRequest.Header.Set("Authorization", authorization)
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = id
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.Rerun(adminToken).Create(req, respo)
+		return httpWriter.Code
+	}
+
+	if code := rerun("", "Bearer ", original.ID); code != http.StatusForbidden {
+		t.Errorf("expected reruns without an admin token to be disabled, got %d", code)
+	}
+	if code := rerun("starbuck", "Bearer stubb", original.ID); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", code)
+	}
+	if code := rerun("starbuck", "Bearer starbuck", "01z"); code != http.StatusNotFound {
+		t.Errorf("expected an unknown build to be not found, got %d", code)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected no build to be created, got %d builds", len(store.Builds))
+	}
+
+	if code := rerun("starbuck", "Bearer starbuck", original.ID); code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, code)
+	}
+	if len(store.Builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(store.Builds))
+	}
+	build := store.Builds[1]
+	if build.ParentBuildID != original.ID {
+		t.Errorf("expected parent build ID %q, got %q", original.ID, build.ParentBuildID)
+	}
+	if build.ProjectID != original.ProjectID || build.Type != original.Type || build.Revision.Commit != "aaa" {
+		t.Errorf("expected the rerun to keep project, event and revision, got %+v", build)
+	}
+	if build.Worker != nil {
+		t.Error("expected the rerun to have no worker")
+	}
+	if build.IdempotencyKey != "" || build.FlakyRetry != 0 {
+		t.Errorf("expected the rerun to drop the idempotency key and flaky retry count, got %q and %d", build.IdempotencyKey, build.FlakyRetry)
+	}
+	if original.ParentBuildID != "" || original.Worker == nil || original.IdempotencyKey != "deploy-42" {
+		t.Error("expected the original build to be left untouched")
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// RollbackEvent is the event type of builds created by a rollback.
+const RollbackEvent = "rollback"
+
+// Rollback represents the rollback api handlers.
+type Rollback struct {
+	store      storage.Store
+	adminToken string
+}
+
+// Rollback returns a handler for rollbacks. Rollbacks can only be triggered
+// with the given admin token, and not at all if it is empty.
+func (api API) Rollback(adminToken string) Rollback {
+	return Rollback{store: api.store, adminToken: adminToken}
+}
+
+// Create creates a new gin handler for the POST /project/:id/rollback endpoint
+//
+// Builds are deployments to the environment named by their "env" build
+// argument. It creates a rollback build of the commit of the most recent
+// successful deployment to the env query parameter's environment whose commit
+// differs from the latest deployment's. The build gets the environment as
+// e.buildArgs.env and the commit it rolls back from as
+// e.buildArgs.rollbackFrom; the script's rollback handler does the rest. The
+// request must carry the admin token as a bearer token.
+func (api Rollback) Create(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Rollbacks are disabled: the API has no admin token.", request, response) {
+		return
+	}
+
+	env := request.QueryParameter("env")
+	if env == "" {
+		response.WriteErrorString(http.StatusBadRequest, "The environment to roll back is required.")
+		return
+	}
+	proj, err := api.store.GetProject(request.PathParameter("id"))
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	builds, err := api.store.GetProjectBuilds(proj)
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Builds could not be loaded.")
+		return
+	}
+	current, target := rollbackTarget(builds, env)
+	if target == nil {
+		response.WriteErrorString(http.StatusNotFound, "No earlier successful deployment to roll back to.")
+		return
+	}
+
+	build := &brigade.Build{
+		ProjectID: proj.ID,
+		Type:      RollbackEvent,
+		Provider:  "brigade-api",
+		Revision:  &brigade.Revision{Commit: target.Revision.Commit},
+		BuildArgs: map[string]string{
+			"env":          env,
+			"rollbackFrom": current.Revision.Commit,
+		},
+		Priority:   proj.DefaultPriority,
+		CostCenter: proj.CostCenter,
+	}
+	if err := api.store.CreateBuild(build); err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be created.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, build)
+}
+
+// rollbackTarget returns the latest deployment to the environment, and the
+// most recent successful deployment of another commit. Deployments are the
+// builds of a commit with the environment as their "env" build argument.
+func rollbackTarget(builds []*brigade.Build, env string) (current, target *brigade.Build) {
+	var deployments []*brigade.Build
+	for _, b := range builds {
+		if b.BuildArgs["env"] == env && b.Revision != nil && b.Revision.Commit != "" {
+			deployments = append(deployments, b)
+		}
+	}
+	// Build IDs are ULIDs, so they sort by creation time.
+	for _, b := range deployments {
+		if current == nil || b.ID > current.ID {
+			current = b
+		}
+	}
+	for _, b := range deployments {
+		if b.Revision.Commit == current.Revision.Commit || b.Worker == nil || b.Worker.Status != brigade.JobSucceeded {
+			continue
+		}
+		if target == nil || b.ID > target.ID {
+			target = b
+		}
+	}
+	return current, target
+}
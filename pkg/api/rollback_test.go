@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestRollbackCreate(t *testing.T) {
+	deployment := func(id, env, commit string, status brigade.JobStatus) *brigade.Build {
+		return &brigade.Build{
+			ID:        id,
+			Revision:  &brigade.Revision{Commit: commit},
+			BuildArgs: map[string]string{"env": env},
+			Worker:    &brigade.Worker{Status: status},
+		}
+	}
+	store := mock.New()
+	store.Builds = []*brigade.Build{
+		deployment("01a", "production", "aaa", brigade.JobSucceeded),
+		deployment("01b", "production", "bbb", brigade.JobSucceeded),
+		deployment("01c", "staging", "ccc", brigade.JobSucceeded),
+		deployment("01d", "production", "ddd", brigade.JobFailed),
+		deployment("01e", "production", "eee", brigade.JobFailed),
+	}
+	mockAPI := New(store)
+
+	rollback := func(adminToken, authorization, env string) int {
+		httpRequest := httptest.NewRequest("POST", "/?env="+env, bytes.NewBuffer(nil))
+		httpRequest.Header.Set("Authorization", authorization)
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = mock.StubProject.ID
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.Rollback(adminToken).Create(req, respo)
+		return httpWriter.Code
+	}
+
+	if code := rollback("", "Bearer ", "production"); code != http.StatusForbidden {
+		t.Errorf("expected rollbacks without an admin token to be disabled, got %d", code)
+	}
+	if code := rollback("starbuck", "Bearer stubb", "production"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", code)
+	}
+	if code := rollback("starbuck", "Bearer starbuck", ""); code != http.StatusBadRequest {
+		t.Errorf("expected a missing environment to be refused, got %d", code)
+	}
+	if code := rollback("starbuck", "Bearer starbuck", "qa"); code != http.StatusNotFound {
+		t.Errorf("expected an environment without deployments to be not found, got %d", code)
+	}
+	if len(store.Builds) != 5 {
+		t.Fatalf("expected no build to be created, got %d builds", len(store.Builds))
+	}
+
+	if code := rollback("starbuck", "Bearer starbuck", "production"); code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, code)
+	}
+	build := store.Builds[len(store.Builds)-1]
+	if build.Type != RollbackEvent {
+		t.Errorf("expected a %q build, got %q", RollbackEvent, build.Type)
+	}
+	if build.Revision.Commit != "bbb" {
+		t.Errorf("expected a rollback to commit bbb, got %q", build.Revision.Commit)
+	}
+	if build.BuildArgs["env"] != "production" || build.BuildArgs["rollbackFrom"] != "eee" {
+		t.Errorf("unexpected build args %v", build.BuildArgs)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Trigger represents the api handlers that create builds.
+type Trigger struct {
+	store      storage.Store
+	adminToken string
+}
+
+// Trigger returns a handler for creating builds. Builds can only be created
+// with the given admin token, and not at all if it is empty.
+func (api API) Trigger(adminToken string) Trigger {
+	return Trigger{store: api.store, adminToken: adminToken}
+}
+
+// BuildRequest is the body of a request to create a build.
+type BuildRequest struct {
+	// Type is the event type of the build. It defaults to "exec".
+	Type      string            `json:"type"`
+	Revision  *brigade.Revision `json:"revision"`
+	Payload   string            `json:"payload"`
+	BuildArgs map[string]string `json:"build_args"`
+	// Priority is high, normal or low. It defaults to the project's
+	// DefaultPriority.
+	Priority string `json:"priority"`
+}
+
+// IdempotencyKeyHeader is the header that carries the idempotency key of a
+// request to create a build.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyPattern matches the keys that can be stored as a label value.
+var idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+// Create creates a new gin handler for the POST /project/:id/builds endpoint
+//
+// If the request has an Idempotency-Key header and the project already has a
+// build created with that key within the last 24 hours, that build is
+// returned with 200 instead of creating another one. The request must carry
+// the admin token as a bearer token.
+func (api Trigger) Create(request *restful.Request, response *restful.Response) {
+	if !authorizeAdmin(api.adminToken, "Creating builds is disabled: the API has no admin token.", request, response) {
+		return
+	}
+
+	id := request.PathParameter("id")
+	proj, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+
+	key := request.HeaderParameter(IdempotencyKeyHeader)
+	if key != "" && !idempotencyKeyPattern.MatchString(key) {
+		response.WriteErrorString(http.StatusBadRequest, "The Idempotency-Key must be at most 63 letters, digits, '-', '_' or '.'.")
+		return
+	}
+
+	req := BuildRequest{}
+	if request.Request.ContentLength != 0 {
+		if err := request.ReadEntity(&req); err != nil {
+			response.WriteErrorString(http.StatusBadRequest, "Malformed build request.")
+			return
+		}
+	}
+	priority := proj.DefaultPriority
+	if req.Priority != "" {
+		if priority, err = brigade.ParseBuildPriority(req.Priority); err != nil {
+			response.WriteErrorString(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	build := &brigade.Build{
+		ProjectID:      proj.ID,
+		Type:           req.Type,
+		Provider:       "brigade-api",
+		Revision:       req.Revision,
+		Payload:        []byte(req.Payload),
+		BuildArgs:      req.BuildArgs,
+		Priority:       priority,
+		CostCenter:     proj.CostCenter,
+		IdempotencyKey: key,
+	}
+	if build.Type == "" {
+		build.Type = "exec"
+	}
+	if build.Revision == nil || (build.Revision.Commit == "" && build.Revision.Ref == "") {
+		build.Revision = &brigade.Revision{Ref: "master"}
+	}
+	if key == "" {
+		err = api.store.CreateBuild(build)
+	} else {
+		var existing *brigade.Build
+		existing, err = api.store.CreateIdempotentBuild(build)
+		if err == nil && existing != nil {
+			response.WriteHeaderAndEntity(http.StatusOK, existing)
+			return
+		}
+	}
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be created.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, build)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestTriggerCreate(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	createWith := func(adminToken, authorization, key, body string) *httptest.ResponseRecorder {
+		httpRequest := httptest.NewRequest("POST", "/?a=b", bytes.NewBufferString(body))
+		httpRequest.Header.Set("Content-Type", "application/json")
+		httpRequest.Header.Set("Authorization", authorization)
+		if key != "" {
+			httpRequest.Header.Set(IdempotencyKeyHeader, key)
+		}
+		req := restful.NewRequest(httpRequest)
+		req.PathParameters()["id"] = "project-id"
+		httpWriter := httptest.NewRecorder()
+		respo := restful.NewResponse(httpWriter)
+		respo.SetRequestAccepts("application/json")
+		mockAPI.Trigger(adminToken).Create(req, respo)
+		return httpWriter
+	}
+	create := func(key, body string) *httptest.ResponseRecorder {
+		return createWith("starbuck", "Bearer starbuck", key, body)
+	}
+	buildOf := func(w *httptest.ResponseRecorder) *brigade.Build {
+		b := &brigade.Build{}
+		if err := json.Unmarshal(w.Body.Bytes(), b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if w := createWith("", "Bearer ", "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected creating builds without an admin token to be disabled, got %d", w.Code)
+	}
+	if w := createWith("starbuck", "Bearer stubb", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", w.Code)
+	}
+	if l := len(store.Builds); l != 2 {
+		t.Fatalf("expected no build to be created, have %d builds", l)
+	}
+
+	key := "4f0b4e3c-2d6a-4b8e-9c1f-7a3e5d2b1c0a"
+	first := create(key, `{"type": "deploy", "revision": {"ref": "main"}}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, first.Code)
+	}
+	original := buildOf(first)
+	if original.Type != "deploy" || original.Revision.Ref != "main" || original.IdempotencyKey != key {
+		t.Errorf("unexpected build %+v", original)
+	}
+
+	retry := create(key, `{"type": "deploy", "revision": {"ref": "main"}}`)
+	if retry.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, retry.Code)
+	}
+	if b := buildOf(retry); b.ID != original.ID {
+		t.Errorf("expected the retry to return build %q, got %q", original.ID, b.ID)
+	}
+	if l := len(store.Builds); l != 3 {
+		t.Errorf("expected the retry not to create a build, have %d builds", l)
+	}
+
+	if w := create("", ""); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if b := store.Builds[len(store.Builds)-1]; b.Type != "exec" || b.Revision.Ref != "master" {
+		t.Errorf("expected an exec build of master, got %q of %+v", b.Type, b.Revision)
+	}
+
+	if w := create("not a valid key", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w := create("", `{"priority": "urgent"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid priority to be refused, got %d", w.Code)
+	}
+	if w := create("", `{"priority": "high"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if b := store.Builds[len(store.Builds)-1]; b.Priority != brigade.PriorityHigh {
+		t.Errorf("expected a high priority build, got %q", b.Priority)
+	}
+}
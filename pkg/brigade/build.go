@@ -1,5 +1,49 @@
 package brigade
 
+import (
+	"fmt"
+	"time"
+)
+
+// BuildPriority is the priority of a build waiting for a worker.
+//
+// The controller starts the workers of high priority builds before normal
+// ones, and normal ones before low.
+type BuildPriority string
+
+// Build priorities.
+const (
+	PriorityHigh   BuildPriority = "high"
+	PriorityNormal BuildPriority = "normal"
+	PriorityLow    BuildPriority = "low"
+)
+
+// ApprovalStatus is where a build that requires approval stands.
+type ApprovalStatus string
+
+// Approval statuses. Builds that do not require approval have none.
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+// DefaultApprovalTimeout is how long a build waits for approval unless its
+// project sets an ApprovalTimeout.
+const DefaultApprovalTimeout = time.Hour
+
+// ParseBuildPriority parses a build priority. An empty string is the
+// normal priority.
+func ParseBuildPriority(s string) (BuildPriority, error) {
+	switch p := BuildPriority(s); p {
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return p, nil
+	case "":
+		return PriorityNormal, nil
+	}
+	return "", fmt.Errorf("invalid build priority %q: must be high, normal or low", s)
+}
+
 // Build represents an invocation of an event in Brigade.
 //
 // Each build has a unique ID, and is tied to a project, as well as an event type.
@@ -45,6 +89,71 @@ type Build struct {
 	// LogLevel determines what level of logging from the Javascript
 	// to print to console.
 	LogLevel string `json:"log_level,omitempty"`
+	// ParentBuildID is the ID of the build this build is a rerun of.
+	// It is empty for builds that were not created by a rerun.
+	ParentBuildID string `json:"parent_build_id,omitempty"`
+	// FlakyRetry counts the flaky failures this build is a rerun of. It is
+	// zero for builds the controller did not rerun.
+	FlakyRetry int `json:"flaky_retry,omitempty"`
+	// RetriedAs is the ID of the build the controller reran this build as
+	// after it failed in a way known to be flaky.
+	RetriedAs string `json:"retried_as,omitempty"`
+	// BuildArgs are the arguments the build was invoked with. They override
+	// the project's DefaultBuildArgs and are exposed to brigade.js as
+	// e.buildArgs.
+	BuildArgs map[string]string `json:"build_args,omitempty"`
+	// Matrix is the combination of the project's Matrix the build runs, in
+	// the form "go=1.14,os=linux". It is empty for builds of projects
+	// without a matrix.
+	Matrix string `json:"matrix,omitempty"`
+	// NotificationErrors are the errors of the notifications sent about the
+	// build. They never affect the build's result.
+	NotificationErrors []string `json:"notification_errors,omitempty"`
+	// Priority is the priority of the build while it waits for a worker.
+	// Gateways default it to the project's DefaultPriority.
+	Priority BuildPriority `json:"priority,omitempty"`
+	// CostCenter is the project's CostCenter at the time the build was created.
+	CostCenter string `json:"cost_center,omitempty"`
+	// IdempotencyKey is the key of the request that created the build. A
+	// request with the same key returns this build instead of creating
+	// another one.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// TraceParent is the W3C trace context the build's spans are children
+	// of, in the form of a traceparent header. Gateways take it from the
+	// event's request, so that the build joins the trace of the systems that
+	// forwarded the event.
+	TraceParent string `json:"trace_parent,omitempty"`
+	// EnvironmentSnapshot describes the environment the build's worker ran
+	// in. It is recorded by the controller when the worker completes.
+	EnvironmentSnapshot *EnvironmentSnapshot `json:"environment_snapshot,omitempty"`
+	// DurationRegression is set if the build took much longer than its
+	// project's recent builds. It is recorded by the controller when the
+	// worker completes.
+	DurationRegression *DurationRegression `json:"duration_regression,omitempty"`
+	// Approval is where the build stands if its project requires approval
+	// before it runs.
+	Approval ApprovalStatus `json:"approval,omitempty"`
+	// CPUSeconds and MemoryGBSeconds are the resources requested by the
+	// build's worker and job pods, multiplied by how long each pod ran.
+	// They are recorded by the controller when the worker completes.
+	CPUSeconds      float64 `json:"cpu_seconds,omitempty"`
+	MemoryGBSeconds float64 `json:"memory_gb_seconds,omitempty"`
+	// QueuedTime is the time the build was created and queued for a worker.
+	QueuedTime time.Time `json:"queued_time"`
+	// AcceptedTime is the time the controller created the build's worker.
+	// It is zero while the build is still queued. The times the worker
+	// started building and completed are recorded on the Worker.
+	AcceptedTime time.Time `json:"accepted_time"`
+}
+
+// DurationRegression compares a build's duration with the average duration
+// of its project's recent builds.
+type DurationRegression struct {
+	// AverageSeconds is the average duration of the project's recent
+	// successful builds, outliers excluded.
+	AverageSeconds float64 `json:"average_seconds"`
+	// Factor is the build's duration divided by the average.
+	Factor float64 `json:"factor"`
 }
 
 // Revision describes a vcs revision.
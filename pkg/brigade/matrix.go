@@ -0,0 +1,58 @@
+package brigade
+
+import (
+	"sort"
+	"strings"
+)
+
+// ExpandMatrix returns one copy of the build per combination of the
+// project's Matrix, with the combination merged into its BuildArgs and
+// recorded in its Matrix field.
+//
+// Combinations are ordered by the sorted matrix keys, then by the order of
+// each key's values. The build itself is returned if the project has no
+// matrix.
+func (p *Project) ExpandMatrix(b *Build) []*Build {
+	keys := make([]string, 0, len(p.Matrix))
+	for k, values := range p.Matrix {
+		if len(values) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return []*Build{b}
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, k := range keys {
+		var next []map[string]string
+		for _, c := range combinations {
+			for _, v := range p.Matrix[k] {
+				combination := map[string]string{k: v}
+				for ck, cv := range c {
+					combination[ck] = cv
+				}
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+
+	builds := make([]*Build, len(combinations))
+	for i, c := range combinations {
+		build := *b
+		build.BuildArgs = map[string]string{}
+		for k, v := range b.BuildArgs {
+			build.BuildArgs[k] = v
+		}
+		pairs := make([]string, len(keys))
+		for j, k := range keys {
+			build.BuildArgs[k] = c[k]
+			pairs[j] = k + "=" + c[k]
+		}
+		build.Matrix = strings.Join(pairs, ",")
+		builds[i] = &build
+	}
+	return builds
+}
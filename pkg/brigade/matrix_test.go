@@ -0,0 +1,40 @@
+package brigade
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	proj := &Project{Matrix: map[string][]string{
+		"os": {"linux", "darwin"},
+		"go": {"1.14", "1.15"},
+	}}
+	b := &Build{ProjectID: "brigade-1234", BuildArgs: map[string]string{"suite": "unit", "os": "windows"}}
+
+	builds := proj.ExpandMatrix(b)
+	var got []string
+	for _, build := range builds {
+		got = append(got, build.Matrix)
+		if build.BuildArgs["suite"] != "unit" || build.ProjectID != "brigade-1234" {
+			t.Errorf("expected %s to keep the build's fields, got %+v", build.Matrix, build)
+		}
+	}
+	expected := []string{"go=1.14,os=linux", "go=1.14,os=darwin", "go=1.15,os=linux", "go=1.15,os=darwin"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected combinations %v, got %v", expected, got)
+	}
+	if args := builds[3].BuildArgs; args["go"] != "1.15" || args["os"] != "darwin" {
+		t.Errorf("expected the combination in the build args, got %v", args)
+	}
+	if b.BuildArgs["os"] != "windows" {
+		t.Errorf("expected the original build args to be unchanged, got %v", b.BuildArgs)
+	}
+}
+
+func TestExpandMatrix_Empty(t *testing.T) {
+	b := &Build{}
+	if builds := (&Project{}).ExpandMatrix(b); len(builds) != 1 || builds[0] != b {
+		t.Errorf("expected the build itself, got %v", builds)
+	}
+}
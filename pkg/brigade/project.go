@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Project describes a Brigade project
@@ -30,16 +31,36 @@ type Project struct {
 	Kubernetes Kubernetes `json:"kubernetes"`
 	// SharedSecret is the GitHub shared key
 	SharedSecret string `json:"-"`
+	// ResourceVersion is the version of the project's record in storage.
+	// Replacing a project with a ResourceVersion fails if the record has
+	// changed since.
+	ResourceVersion string `json:"-"`
 	// Github holds information about Github.
 	Github Github `json:"github"`
 	// Secrets is environment variables for brigade.js
 	Secrets SecretsMap `json:"secrets"`
 	// Worker holds a set of project-specific worker settings which takes precedence over brigade-wide settings
 	Worker WorkerConfig `json:"worker"`
+	// DefaultPriority is the priority of the project's builds, unless the
+	// build sets its own.
+	DefaultPriority BuildPriority `json:"defaultPriority,omitempty"`
+	// CostCenter attributes the cost of the project's builds, as reported by
+	// the API's cost report.
+	CostCenter string `json:"costCenter,omitempty"`
+	// Notifications describes where the results of the project's builds are reported
+	Notifications Notifications `json:"notifications"`
 
 	// InitGitSubmodules initializes Git submodules in VCS if true.
 	InitGitSubmodules bool `json:"initGitSubmodules"`
 
+	// SparseCheckoutPaths limits the worker's checkout to the given directories
+	// of the repository. The whole repository is checked out if it is empty.
+	SparseCheckoutPaths []string `json:"sparseCheckoutPaths,omitempty"`
+
+	// LFSConcurrency is the number of Git LFS objects the VCS sidecar
+	// downloads in parallel. The sidecar defaults to 4 if it is zero.
+	LFSConcurrency int `json:"lfsConcurrency,omitempty"`
+
 	// AllowPrivilegedJobs allows jobs to use privileged mode.
 	AllowPrivilegedJobs bool `json:"allowPrivilegedJobs"`
 
@@ -57,9 +78,78 @@ type Project struct {
 	// BrigadejsPath contains the path for the Brigade.js file in the source repo
 	BrigadejsPath string `json:"brigadejsPath"`
 
+	// BrigadejsPaths maps event types to the script run for them, as paths in
+	// the source repo, so that e.g. push and release logic can live in
+	// separate files. Events not in the map run the BrigadejsPath script, as
+	// do those whose mapped file is missing.
+	BrigadejsPaths map[string]string `json:"brigadejsPaths,omitempty"`
+
 	// BrigadeConfigPath contains the path for the brigade.json file in the source repo
 	BrigadeConfigPath string `json:"brigadeConfigPath"`
 
+	// DefaultBuildArgs are the build arguments passed to every build of the
+	// project unless overridden by the build itself.
+	DefaultBuildArgs map[string]string `json:"defaultBuildArgs,omitempty"`
+
+	// AllowedBuildArgKeys lists the build arguments gateways may set on a build
+	// from the incoming request.
+	AllowedBuildArgKeys []string `json:"allowedBuildArgKeys,omitempty"`
+
+	// KnownEvents lists the custom events the project's script handles. The
+	// worker warns about handlers of events that are neither sent by Brigade
+	// nor listed here, as their names are most likely typos.
+	KnownEvents []string `json:"knownEvents,omitempty"`
+
+	// Matrix runs each build of a gateway event once per combination of
+	// its values, e.g. {"go": ["1.14", "1.15"], "os": ["linux", "darwin"]}
+	// runs four builds. Each combination is merged into the build's
+	// BuildArgs. Builds are not expanded if it is empty.
+	Matrix map[string][]string `json:"matrix,omitempty"`
+
+	// DebounceWindow groups the events a gateway receives for the same ref
+	// within the window into a single build of the latest event.
+	// Debouncing is disabled if it is zero.
+	DebounceWindow time.Duration `json:"debounceWindow"`
+
+	// BuildTimeout limits how long a build's worker may run. Kubernetes stops
+	// workers that run longer, and their builds fail. Builds are not limited
+	// if it is zero.
+	BuildTimeout time.Duration `json:"buildTimeout,omitempty"`
+
+	// WorkspaceSizeLimitMB limits the size of the worker's checkout of the
+	// repository, in megabytes. Kubernetes evicts workers that write more, and
+	// their builds fail. The checkout is not limited if it is zero.
+	WorkspaceSizeLimitMB int `json:"workspaceSizeLimitMB,omitempty"`
+
+	// FlakyPatterns are regular expressions matching the logs of failures
+	// known to be flaky, such as ECONNRESET. The controller reruns failed
+	// builds whose logs match one of them, up to FlakyRetryCount times.
+	FlakyPatterns   []string `json:"flakyPatterns,omitempty"`
+	FlakyRetryCount int      `json:"flakyRetryCount,omitempty"`
+
+	// MaxDurationRegressionFactor reports successful builds that take longer
+	// than this many times the average of the project's successful builds
+	// of the last 30 days, e.g. 2.0 for builds taking twice as long. They
+	// are reported to the project's notifications whatever their filters.
+	// Builds are not compared if it is zero.
+	MaxDurationRegressionFactor float64 `json:"maxDurationRegressionFactor,omitempty"`
+
+	// PostBuildScript is a shell script the controller runs after each build
+	// completes, whatever its result, for instance to delete resources the
+	// build created. It does not change the build's result.
+	PostBuildScript string `json:"postBuildScript,omitempty"`
+
+	// RequiresApproval holds deployment builds until someone approves them
+	// through the API. Builds that are not approved within ApprovalTimeout,
+	// or DefaultApprovalTimeout if it is zero, never run.
+	RequiresApproval bool          `json:"requiresApproval,omitempty"`
+	ApprovalTimeout  time.Duration `json:"approvalTimeout,omitempty"`
+
+	// HTTPProxy and HTTPSProxy are the proxies the VCS sidecars clone the
+	// repository through. They override the controller's defaults.
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
 	// GenericGatewaySecret is a string that contains the access code used by API Server to authenticate generic Gateway requests
 	GenericGatewaySecret string `json:"genericGatewaySecret"`
 }
@@ -80,6 +170,55 @@ func (s SecretsMap) MarshalJSON() ([]byte, error) {
 	return json.Marshal(dest)
 }
 
+// KeepCredentials copies the fields that are never marshaled to JSON from
+// the other project, so that a project decoded from JSON can replace it
+// without losing them. Redacted secrets keep the other project's value.
+// Webhooks keep the secret of the other project's webhook with the same URL,
+// and chat destinations the URL of its destination with the same type and
+// channel; the others are left without credentials.
+func (p *Project) KeepCredentials(other *Project) {
+	p.SharedSecret = other.SharedSecret
+	p.Github.Token = other.Github.Token
+	p.Repo.SSHKey = other.Repo.SSHKey
+	p.Repo.SSHCert = other.Repo.SSHCert
+	p.Notifications.Slack.WebhookURL = other.Notifications.Slack.WebhookURL
+	for k, v := range p.Secrets {
+		if old, ok := other.Secrets[k]; ok && v == redacted {
+			p.Secrets[k] = old
+		}
+	}
+
+	webhookSecrets := map[string]string{}
+	for _, w := range other.Notifications.Webhooks {
+		if _, ok := webhookSecrets[w.URL]; !ok {
+			webhookSecrets[w.URL] = w.Secret
+		}
+	}
+	for i := range p.Notifications.Webhooks {
+		p.Notifications.Webhooks[i].Secret = webhookSecrets[p.Notifications.Webhooks[i].URL]
+	}
+
+	// Destinations with the same type and channel keep their URLs in order.
+	chatURLs := map[chatDestination][]string{}
+	for _, c := range other.Notifications.Chat {
+		d := chatDestination{c.Type, c.Channel}
+		chatURLs[d] = append(chatURLs[d], c.URL)
+	}
+	for i := range p.Notifications.Chat {
+		c := &p.Notifications.Chat[i]
+		d := chatDestination{c.Type, c.Channel}
+		c.URL = ""
+		if urls := chatURLs[d]; len(urls) > 0 {
+			c.URL, chatURLs[d] = urls[0], urls[1:]
+		}
+	}
+}
+
+// chatDestination identifies a chat notification when its URL is hidden.
+type chatDestination struct {
+	Type, Channel string
+}
+
 // ProjectID will encode a project name.
 func ProjectID(id string) string {
 	if strings.HasPrefix(id, "brigade-") {
@@ -115,15 +254,105 @@ type Repo struct {
 	// CloneURL is the URL at which the repository can be cloned
 	// Traditionally, this is an HTTPS URL.
 	CloneURL string `json:"cloneURL"`
+	// BundleURI is the location of a git bundle the VCS sidecar clones
+	// before fetching from CloneURL, for clusters that cannot reach the
+	// repository. It may be an s3://, gs:// or http(s):// URI, or a path.
+	BundleURI string `json:"bundleURI,omitempty"`
 	// SSHKey is the auth string for SSH-based cloning
 	SSHKey  string `json:"-"`
 	SSHCert string `json:"-"`
 }
 
+// Notifications describes where the results of a project's builds are reported.
+type Notifications struct {
+	// Slack configures notifications to a Slack incoming webhook.
+	Slack SlackNotifications `json:"slack"`
+	// Chat lists further chat destinations, such as Microsoft Teams or
+	// Mattermost channels.
+	Chat []ChatNotification `json:"chat"`
+	// Email configures email notifications. The SMTP server is configured
+	// on the controller.
+	Email EmailNotifications `json:"email"`
+	// Webhooks are the destinations that receive a signed JSON document
+	// when a build starts, succeeds or fails.
+	Webhooks []WebhookNotification `json:"webhooks"`
+}
+
+// WebhookNotification describes an outbound webhook of a project.
+type WebhookNotification struct {
+	// URL is the URL the build documents are POSTed to.
+	URL string `json:"url"`
+	// Secret is the key used to sign the build documents.
+	Secret string `json:"-"`
+}
+
+// SlackNotifications describes the Slack notifications of a project.
+type SlackNotifications struct {
+	// WebhookURL is the URL of the Slack incoming webhook.
+	// Slack notifications are disabled if it is empty.
+	WebhookURL string `json:"-"`
+	// Channel overrides the default channel of the webhook.
+	Channel string `json:"channel"`
+	// Branches limits notifications to builds of the given branches.
+	// Builds of all branches are reported if it is empty.
+	Branches []string `json:"branches"`
+	// OnlyOnFailure limits notifications to failed builds.
+	OnlyOnFailure bool `json:"onlyOnFailure"`
+	// OnlyOnRecovery limits notifications to the first successful build
+	// of a branch after a failure. If OnlyOnFailure is also set, both
+	// failures and recoveries are reported.
+	OnlyOnRecovery bool `json:"onlyOnRecovery"`
+}
+
+// Chat notification types.
+const (
+	ChatSlack      = "slack"
+	ChatTeams      = "teams"
+	ChatMattermost = "mattermost"
+)
+
+// ChatNotification describes a chat destination of a project's build results.
+type ChatNotification struct {
+	// Type is the kind of chat service: slack, teams or mattermost.
+	Type string `json:"type"`
+	// URL is the URL of the incoming webhook.
+	URL string `json:"-"`
+	// Channel overrides the default channel of the webhook. It is ignored
+	// by Microsoft Teams.
+	Channel string `json:"channel,omitempty"`
+	// Branches limits notifications to builds of the given branches.
+	// Builds of all branches are reported if it is empty.
+	Branches []string `json:"branches,omitempty"`
+	// OnlyOnFailure limits notifications to failed builds.
+	OnlyOnFailure bool `json:"onlyOnFailure,omitempty"`
+	// OnlyOnRecovery limits notifications to the first successful build
+	// of a branch after a failure.
+	OnlyOnRecovery bool `json:"onlyOnRecovery,omitempty"`
+}
+
+// EmailNotifications describes the email notifications of a project.
+type EmailNotifications struct {
+	// Recipients are the addresses build results are sent to.
+	// Email notifications are disabled if it is empty.
+	Recipients []string `json:"recipients"`
+	// Branches limits notifications to builds of the given branches.
+	// Builds of all branches are reported if it is empty.
+	Branches []string `json:"branches"`
+	// OnlyOnFailure limits notifications to failed builds.
+	OnlyOnFailure bool `json:"onlyOnFailure"`
+	// OnlyOnRecovery limits notifications to the first successful build
+	// of a branch after a failure. If OnlyOnFailure is also set, both
+	// failures and recoveries are reported.
+	OnlyOnRecovery bool `json:"onlyOnRecovery"`
+}
+
 // Kubernetes describes the Kubernetes configuration for a project.
 type Kubernetes struct {
 	// Namespace is the namespace of this project.
 	Namespace string `json:"namespace"`
+	// JobNamespace is the namespace the worker runs the project's jobs in.
+	// Jobs run in the project's namespace if it is empty.
+	JobNamespace string `json:"jobNamespace,omitempty"`
 	// VCSSidecar is the image name/tag for the sidecar that pulls VCS data
 	VCSSidecar string `json:"vcsSidecar"`
 	// BuildStorageSize is the size of the build shared storage used by the jobs
@@ -137,3 +366,12 @@ type Kubernetes struct {
 	// ServiceAccount is the service account to use for this project
 	ServiceAccount string `json:"serviceAccount"`
 }
+
+// JobPodNamespace returns the namespace the project's jobs run in: its
+// JobNamespace, or its namespace if that is empty.
+func (k Kubernetes) JobPodNamespace() string {
+	if k.JobNamespace != "" {
+		return k.JobNamespace
+	}
+	return k.Namespace
+}
@@ -81,3 +81,75 @@ func TestProjectWorkerConfig(t *testing.T) {
 		t.Errorf("unexpected Project.Worker.PullPolicy: %s != Always", got.Worker.PullPolicy)
 	}
 }
+
+func TestProjectKeepCredentials(t *testing.T) {
+	stored := &Project{
+		SharedSecret: "shh",
+		Secrets:      SecretsMap{"kept": "old", "changed": "old"},
+		Github:       Github{Token: "ghtoken"},
+		Repo:         Repo{SSHKey: "key"},
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proj Project
+	if err := json.Unmarshal(data, &proj); err != nil {
+		t.Fatal(err)
+	}
+	proj.Secrets["changed"] = "new"
+	proj.Secrets["added"] = "new"
+
+	proj.KeepCredentials(stored)
+	if proj.SharedSecret != "shh" || proj.Github.Token != "ghtoken" || proj.Repo.SSHKey != "key" {
+		t.Errorf("expected credentials to be kept, got %+v", proj)
+	}
+	expect := SecretsMap{"kept": "old", "changed": "new", "added": "new"}
+	for k, v := range expect {
+		if proj.Secrets[k] != v {
+			t.Errorf("expected secret %s to be %q, got %q", k, v, proj.Secrets[k])
+		}
+	}
+}
+
+func TestProjectKeepCredentials_Notifications(t *testing.T) {
+	stored := &Project{Notifications: Notifications{
+		Webhooks: []WebhookNotification{
+			{URL: "https://a.example.com", Secret: "a"},
+			{URL: "https://b.example.com", Secret: "b"},
+		},
+		Chat: []ChatNotification{
+			{Type: "slack", Channel: "#builds", URL: "https://slack/builds"},
+			{Type: "teams", URL: "https://teams"},
+			{Type: "slack", Channel: "#alerts", URL: "https://slack/alerts"},
+		},
+	}}
+	// The destinations are reordered, and new ones inserted.
+	proj := &Project{Notifications: Notifications{
+		Webhooks: []WebhookNotification{
+			{URL: "https://new.example.com"},
+			{URL: "https://b.example.com"},
+			{URL: "https://a.example.com"},
+		},
+		Chat: []ChatNotification{
+			{Type: "mattermost", Channel: "#builds"},
+			{Type: "slack", Channel: "#alerts"},
+			{Type: "slack", Channel: "#builds"},
+			{Type: "teams"},
+		},
+	}}
+
+	proj.KeepCredentials(stored)
+	expectSecrets := []string{"", "b", "a"}
+	for i, w := range proj.Notifications.Webhooks {
+		if w.Secret != expectSecrets[i] {
+			t.Errorf("expected webhook %s to have secret %q, got %q", w.URL, expectSecrets[i], w.Secret)
+		}
+	}
+	expectURLs := []string{"", "https://slack/alerts", "https://slack/builds", "https://teams"}
+	for i, c := range proj.Notifications.Chat {
+		if c.URL != expectURLs[i] {
+			t.Errorf("expected %s %s to have URL %q, got %q", c.Type, c.Channel, expectURLs[i], c.URL)
+		}
+	}
+}
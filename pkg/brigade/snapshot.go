@@ -0,0 +1,62 @@
+package brigade
+
+// EnvironmentSnapshot describes the environment a build's worker ran in, so
+// that builds with different results can be told apart by what changed
+// around them.
+//
+// Image IDs are digests, so they pin the tools installed in the images even
+// when a tag such as "latest" moves.
+type EnvironmentSnapshot struct {
+	WorkerImage    string `json:"worker_image"`
+	WorkerImageID  string `json:"worker_image_id"`
+	SidecarImage   string `json:"sidecar_image,omitempty"`
+	SidecarImageID string `json:"sidecar_image_id,omitempty"`
+	// Commit is the commit that was checked out.
+	Commit string `json:"commit,omitempty"`
+	// ScriptSHA256 is the digest of the script sent with the build or of the
+	// project's default script. It is empty for scripts read from the
+	// repository, which are pinned by Commit.
+	ScriptSHA256 string `json:"script_sha256,omitempty"`
+	// The node's versions are only recorded if the controller may read nodes.
+	KernelVersion           string `json:"kernel_version,omitempty"`
+	OSImage                 string `json:"os_image,omitempty"`
+	KubeletVersion          string `json:"kubelet_version,omitempty"`
+	ContainerRuntimeVersion string `json:"container_runtime_version,omitempty"`
+}
+
+// SnapshotChange is a field that differs between two snapshots.
+type SnapshotChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff returns the fields that differ from the other snapshot, by their JSON
+// names. A nil snapshot has every field empty.
+func (s *EnvironmentSnapshot) Diff(other *EnvironmentSnapshot) map[string]SnapshotChange {
+	from, to := s.fields(), other.fields()
+	diff := map[string]SnapshotChange{}
+	for name, value := range from {
+		if value != to[name] {
+			diff[name] = SnapshotChange{From: value, To: to[name]}
+		}
+	}
+	return diff
+}
+
+func (s *EnvironmentSnapshot) fields() map[string]string {
+	if s == nil {
+		s = &EnvironmentSnapshot{}
+	}
+	return map[string]string{
+		"worker_image":              s.WorkerImage,
+		"worker_image_id":           s.WorkerImageID,
+		"sidecar_image":             s.SidecarImage,
+		"sidecar_image_id":          s.SidecarImageID,
+		"commit":                    s.Commit,
+		"script_sha256":             s.ScriptSHA256,
+		"kernel_version":            s.KernelVersion,
+		"os_image":                  s.OSImage,
+		"kubelet_version":           s.KubeletVersion,
+		"container_runtime_version": s.ContainerRuntimeVersion,
+	}
+}
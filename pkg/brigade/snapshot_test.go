@@ -0,0 +1,27 @@
+package brigade
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentSnapshotDiff(t *testing.T) {
+	a := &EnvironmentSnapshot{WorkerImage: "brigadecore/brigade-worker:latest", WorkerImageID: "sha256:aaa", Commit: "abc"}
+	b := &EnvironmentSnapshot{WorkerImage: "brigadecore/brigade-worker:latest", WorkerImageID: "sha256:bbb", Commit: "abc", KernelVersion: "5.4.0"}
+
+	want := map[string]SnapshotChange{
+		"worker_image_id": {From: "sha256:aaa", To: "sha256:bbb"},
+		"kernel_version":  {From: "", To: "5.4.0"},
+	}
+	if got := a.Diff(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := a.Diff(a); len(got) != 0 {
+		t.Errorf("expected no changes, got %v", got)
+	}
+
+	var missing *EnvironmentSnapshot
+	if got := missing.Diff(a); got["commit"] != (SnapshotChange{From: "", To: "abc"}) {
+		t.Errorf("expected a missing snapshot to diff as empty, got %v", got)
+	}
+}
@@ -21,4 +21,10 @@ type Worker struct {
 	ExitCode int32 `json:"exit_code"`
 	// Status is a textual representation of the job's running status
 	Status JobStatus `json:"status"`
+	// TimedOut is true if the worker was stopped for running longer than the
+	// project's build timeout.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// WorkspaceSizeExceeded is true if the worker was evicted for writing more
+	// to its checkout than the project's workspace size limit.
+	WorkspaceSizeExceeded bool `json:"workspace_size_exceeded,omitempty"`
 }
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// Chat posts build results to the incoming webhooks of a project's chat
+// destinations: its Slack webhook and each entry of Notifications.Chat.
+//
+// Slack and Mattermost receive Slack messages, which Mattermost accepts
+// as is. Microsoft Teams receives Office 365 connector cards.
+type Chat struct {
+	client  *http.Client
+	results ResultStore
+}
+
+// NewChat creates a chat notifier that tracks previous results in the given store.
+func NewChat(results ResultStore) *Chat {
+	return &Chat{
+		client:  &http.Client{Timeout: DefaultTimeout},
+		results: results,
+	}
+}
+
+// Notify reports a completed build to each of the project's chat
+// destinations whose filters allow it. Builds that have not completed are
+// ignored.
+func (c *Chat) Notify(ctx context.Context, e BuildEvent) error {
+	if !completed(e.Build) {
+		return nil
+	}
+	proj, build := e.Project, e.Build
+	status := build.Worker.Status
+
+	branch := Branch(build)
+	previous, _ := c.results.LastResult(proj.ID, branch)
+	c.results.SetResult(proj.ID, branch, status)
+
+	var errs []string
+	for _, dest := range chatDestinations(proj) {
+		f := filter{dest.Branches, dest.OnlyOnFailure, dest.OnlyOnRecovery}
+		if dest.URL == "" || !f.wants(branch, status, previous, build.DurationRegression != nil) {
+			continue
+		}
+		if err := c.post(ctx, dest, proj, build, branch); err != nil {
+			errs = append(errs, fmt.Sprintf("could not notify %s of build %s: %s", dest.Type, build.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// chatDestinations returns the project's legacy Slack configuration, if it
+// has a webhook, followed by its chat destinations.
+func chatDestinations(proj *brigade.Project) []brigade.ChatNotification {
+	var dests []brigade.ChatNotification
+	if slack := proj.Notifications.Slack; slack.WebhookURL != "" {
+		dests = append(dests, brigade.ChatNotification{
+			Type:           brigade.ChatSlack,
+			URL:            slack.WebhookURL,
+			Channel:        slack.Channel,
+			Branches:       slack.Branches,
+			OnlyOnFailure:  slack.OnlyOnFailure,
+			OnlyOnRecovery: slack.OnlyOnRecovery,
+		})
+	}
+	return append(dests, proj.Notifications.Chat...)
+}
+
+func (c *Chat) post(ctx context.Context, dest brigade.ChatNotification, proj *brigade.Project, build *brigade.Build, branch string) error {
+	var msg interface{}
+	switch dest.Type {
+	case brigade.ChatSlack, brigade.ChatMattermost:
+		msg = slackMessageFor(proj, build, branch, dest.Channel)
+	case brigade.ChatTeams:
+		msg = teamsMessageFor(proj, build, branch)
+	default:
+		return fmt.Errorf("unknown chat type %q", dest.Type)
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
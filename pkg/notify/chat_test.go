@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestChatNotifyTeams(t *testing.T) {
+	var card teamsMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+			t.Errorf("could not decode Teams card: %s", err)
+		}
+	}))
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		ID:   "brigade-1234",
+		Name: "tennyson/light-brigade",
+		Notifications: brigade.Notifications{Chat: []brigade.ChatNotification{
+			{Type: brigade.ChatTeams, URL: srv.URL},
+		}},
+	}
+	e := BuildEvent{proj, completedBuild("refs/heads/master", brigade.JobFailed)}
+	if err := NewChat(NewMemoryResultStore()).Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if card.Type != "MessageCard" {
+		t.Errorf("expected a MessageCard, got %q", card.Type)
+	}
+	if !strings.Contains(card.Summary, "failed") {
+		t.Errorf("expected summary to report the failure, got %q", card.Summary)
+	}
+	if len(card.Sections) != 1 || len(card.Sections[0].Facts) != 4 {
+		t.Fatalf("unexpected sections: %+v", card.Sections)
+	}
+}
+
+func TestChatNotifyDestinations(t *testing.T) {
+	var mattermost, slack []slackMessage
+	mattermostSrv := newSlackServer(t, &mattermost)
+	defer mattermostSrv.Close()
+	slackSrv := newSlackServer(t, &slack)
+	defer slackSrv.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+
+	proj := &brigade.Project{
+		ID: "brigade-1234",
+		Notifications: brigade.Notifications{
+			Slack: brigade.SlackNotifications{WebhookURL: slackSrv.URL, OnlyOnFailure: true},
+			Chat: []brigade.ChatNotification{
+				{Type: brigade.ChatMattermost, URL: mattermostSrv.URL, Channel: "town-square"},
+				{Type: brigade.ChatTeams, URL: failing.URL},
+			},
+		},
+	}
+	e := BuildEvent{proj, completedBuild("refs/heads/master", brigade.JobSucceeded)}
+	err := NewChat(NewMemoryResultStore()).Notify(context.Background(), e)
+	if err == nil || !strings.Contains(err.Error(), "teams") {
+		t.Errorf("expected an error from the Teams destination, got %v", err)
+	}
+	if len(mattermost) != 1 || mattermost[0].Channel != "town-square" {
+		t.Errorf("expected 1 Mattermost message to town-square, got %+v", mattermost)
+	}
+	if len(slack) != 0 {
+		t.Errorf("expected the Slack filter to skip the successful build, got %d messages", len(slack))
+	}
+}
@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// DefaultEmailQueueSize is the default number of emails waiting to be sent.
+const DefaultEmailQueueSize = 100
+
+// SMTPConfig describes the SMTP server used to send email notifications.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From is the sender address of the notifications.
+	From string
+}
+
+// LogTailer returns the last lines of the logs of a build's worker.
+type LogTailer func(build *brigade.Build) (string, error)
+
+// Email sends build results to a project's email recipients.
+//
+// Emails are queued and sent one at a time by a background goroutine, so a
+// slow SMTP server never delays the caller. When the queue is full, new
+// emails are dropped.
+type Email struct {
+	config  SMTPConfig
+	results ResultStore
+	logs    LogTailer
+	queue   chan *email
+	send    func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+type email struct {
+	proj   *brigade.Project
+	build  *brigade.Build
+	branch string
+}
+
+// NewEmail creates an email notifier that sends through the given SMTP server
+// and tracks previous results in the given store. Email notifications are
+// disabled if the server has no host.
+//
+// If logs is not nil, it is used to include the tail of the worker's logs.
+func NewEmail(config SMTPConfig, results ResultStore, logs LogTailer) *Email {
+	e := &Email{
+		config:  config,
+		results: results,
+		logs:    logs,
+		queue:   make(chan *email, DefaultEmailQueueSize),
+		send:    smtp.SendMail,
+	}
+	go e.run()
+	return e
+}
+
+// Notify queues an email reporting a completed build to the project's
+// recipients, if the project's filters allow it. Builds that have not
+// completed are ignored.
+func (e *Email) Notify(ctx context.Context, ev BuildEvent) error {
+	if !completed(ev.Build) {
+		return nil
+	}
+	proj, build := ev.Project, ev.Build
+	status := build.Worker.Status
+
+	branch := Branch(build)
+	previous, _ := e.results.LastResult(proj.ID, branch)
+	e.results.SetResult(proj.ID, branch, status)
+
+	cfg := proj.Notifications.Email
+	f := filter{cfg.Branches, cfg.OnlyOnFailure, cfg.OnlyOnRecovery}
+	if e.config.Host == "" || len(cfg.Recipients) == 0 || !f.wants(branch, status, previous, build.DurationRegression != nil) {
+		return nil
+	}
+
+	select {
+	case e.queue <- &email{proj: proj, build: build, branch: branch}:
+		return nil
+	default:
+		return fmt.Errorf("email queue is full, dropping the email for build %s", build.ID)
+	}
+}
+
+func (e *Email) run() {
+	addr := e.config.Host + ":" + strconv.Itoa(e.config.Port)
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+	for m := range e.queue {
+		if err := e.deliver(addr, auth, m); err != nil {
+			log.Printf("notify: could not email the result of build %s: %s", m.build.ID, err)
+		}
+	}
+}
+
+func (e *Email) deliver(addr string, auth smtp.Auth, m *email) error {
+	logTail := ""
+	if e.logs != nil {
+		var err error
+		if logTail, err = e.logs(m.build); err != nil {
+			log.Printf("notify: could not load logs of build %s: %s", m.build.ID, err)
+		}
+	}
+	to := m.proj.Notifications.Email.Recipients
+	msg, err := emailMessageFor(e.config.From, to, m.proj, m.build, m.branch, logTail)
+	if err != nil {
+		return err
+	}
+	return e.send(addr, auth, e.config.From, to, msg)
+}
+
+var emailHTML = template.Must(template.New("email").Parse(`<html>
+<body>
+<p>{{.Summary}}</p>
+<table>
+<tr><th align="left">Repository</th><td>{{.Repository}}</td></tr>
+<tr><th align="left">Branch</th><td>{{.Branch}}</td></tr>
+<tr><th align="left">Commit</th><td>{{.Commit}}</td></tr>
+<tr><th align="left">Duration</th><td>{{.Duration}}</td></tr>
+</table>
+{{if .Logs}}<pre>{{.Logs}}</pre>{{end}}
+</body>
+</html>
+`))
+
+func emailMessageFor(from string, to []string, proj *brigade.Project, build *brigade.Build, branch, logTail string) ([]byte, error) {
+	result := "succeeded"
+	if build.Worker.Status == brigade.JobFailed {
+		result = "failed"
+	}
+	commit := ""
+	if build.Revision != nil {
+		commit = build.Revision.Commit
+	}
+	duration := buildDuration(build)
+	data := struct {
+		Summary, Repository, Branch, Commit, Duration, Logs string
+	}{
+		Summary:    fmt.Sprintf("Build %s of %s %s", build.ID, proj.Name, result),
+		Repository: proj.Repo.Name,
+		Branch:     branch,
+		Commit:     commit,
+		Duration:   duration,
+		Logs:       logTail,
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	text, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(text, "%s\r\n\r\nRepository: %s\r\nBranch: %s\r\nCommit: %s\r\nDuration: %s\r\n",
+		data.Summary, data.Repository, data.Branch, data.Commit, data.Duration)
+	html, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	if err := emailHTML.Execute(html, data); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s\r\n", proj.Name, data.Summary)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func emailProject() *brigade.Project {
+	return &brigade.Project{
+		ID:   "brigade-1234",
+		Name: "tennyson/light-brigade",
+		Repo: brigade.Repo{Name: "github.com/tennyson/light-brigade"},
+		Notifications: brigade.Notifications{Email: brigade.EmailNotifications{
+			Recipients:    []string{"cardigan@example.com"},
+			OnlyOnFailure: true,
+		}},
+	}
+}
+
+func TestEmailNotify(t *testing.T) {
+	sent := make(chan string, 1)
+	config := SMTPConfig{Host: "smtp.example.com", Port: 587, From: "brigade@example.com"}
+	logs := func(build *brigade.Build) (string, error) {
+		return "Error: <boom>", nil
+	}
+	e := NewEmail(config, NewMemoryResultStore(), logs)
+	e.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" {
+			t.Errorf("expected address smtp.example.com:587, got %s", addr)
+		}
+		if len(to) != 1 || to[0] != "cardigan@example.com" {
+			t.Errorf("unexpected recipients %v", to)
+		}
+		sent <- string(msg)
+		return nil
+	}
+
+	if err := e.Notify(context.Background(), BuildEvent{emailProject(), completedBuild("refs/heads/master", brigade.JobSucceeded)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Notify(context.Background(), BuildEvent{emailProject(), completedBuild("refs/heads/master", brigade.JobFailed)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg string
+	select {
+	case msg = <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an email to be sent")
+	}
+	for _, expected := range []string{
+		"Subject: [tennyson/light-brigade] Build 01bx5zzkbkactav9wevgemmvry of tennyson/light-brigade failed",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/plain",
+		"Content-Type: text/html",
+		"Duration: 1m30s",
+		"<pre>Error: &lt;boom&gt;</pre>",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected the email to contain %q, got:\n%s", expected, msg)
+		}
+	}
+	select {
+	case msg := <-sent:
+		t.Errorf("expected the successful build not to be reported, got:\n%s", msg)
+	default:
+	}
+}
+
+func TestEmailNotifyQueueFull(t *testing.T) {
+	// Without a goroutine draining the queue, the second email does not fit.
+	e := &Email{
+		config:  SMTPConfig{Host: "smtp.example.com"},
+		results: NewMemoryResultStore(),
+		queue:   make(chan *email, 1),
+	}
+	build := completedBuild("refs/heads/master", brigade.JobFailed)
+	if err := e.Notify(context.Background(), BuildEvent{emailProject(), build}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Notify(context.Background(), BuildEvent{emailProject(), build}); err == nil {
+		t.Error("expected an error when the queue is full")
+	}
+}
+
+func TestEmailNotifyDisabled(t *testing.T) {
+	e := &Email{results: NewMemoryResultStore(), queue: make(chan *email)}
+	if err := e.Notify(context.Background(), BuildEvent{emailProject(), completedBuild("master", brigade.JobFailed)}); err != nil {
+		t.Errorf("expected no error without an SMTP server, got %s", err)
+	}
+}
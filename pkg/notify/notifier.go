@@ -0,0 +1,93 @@
+// Package notify reports the results of completed builds to external services.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// DefaultTimeout is the default timeout of a notification request.
+const DefaultTimeout = 10 * time.Second
+
+// BuildEvent is a change in the state of a build worth reporting.
+type BuildEvent struct {
+	Project *brigade.Project
+	// Build is the build, with its worker in its new state.
+	Build *brigade.Build
+}
+
+// Notifier reports build events to an external service.
+//
+// Notifiers decide from the project's configuration whether an event is
+// reported, and return nil for events they ignore.
+type Notifier interface {
+	Notify(ctx context.Context, e BuildEvent) error
+}
+
+// Dispatcher fans build events out to a set of named notifiers.
+type Dispatcher struct {
+	notifiers map[string]Notifier
+	timeout   time.Duration
+}
+
+// NewDispatcher creates a dispatcher for the given notifiers, keyed by name.
+func NewDispatcher(notifiers map[string]Notifier) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers, timeout: DefaultTimeout}
+}
+
+// Dispatch sends the event to all notifiers concurrently, each with its own
+// timeout, and waits for them to return.
+//
+// It returns the errors of the notifiers that failed, prefixed with their
+// names and sorted.
+func (d *Dispatcher) Dispatch(e BuildEvent) []error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, n := range d.notifiers {
+		wg.Add(1)
+		go func(name string, n Notifier) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+			defer cancel()
+			if err := n.Notify(ctx, e); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", name, err))
+				mu.Unlock()
+			}
+		}(name, n)
+	}
+	wg.Wait()
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// buildDuration describes how long a build's worker ran, and how that
+// compares to its project's recent builds if it regressed.
+func buildDuration(build *brigade.Build) string {
+	w := build.Worker
+	if w.StartTime.IsZero() || w.EndTime.IsZero() {
+		return "unknown"
+	}
+	duration := w.EndTime.Sub(w.StartTime).Round(time.Second).String()
+	if r := build.DurationRegression; r != nil {
+		average := time.Duration(r.AverageSeconds) * time.Second
+		duration += fmt.Sprintf(" (%.1fx the average of %s)", r.Factor, average)
+	}
+	return duration
+}
+
+// completed returns true if the build's worker has succeeded or failed.
+func completed(build *brigade.Build) bool {
+	if build.Worker == nil {
+		return false
+	}
+	return build.Worker.Status == brigade.JobSucceeded || build.Worker.Status == brigade.JobFailed
+}
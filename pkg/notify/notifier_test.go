@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type notifierFunc func(ctx context.Context, e BuildEvent) error
+
+func (f notifierFunc) Notify(ctx context.Context, e BuildEvent) error { return f(ctx, e) }
+
+func TestDispatch(t *testing.T) {
+	d := NewDispatcher(map[string]Notifier{
+		"ok": notifierFunc(func(ctx context.Context, e BuildEvent) error { return nil }),
+		"broken": notifierFunc(func(ctx context.Context, e BuildEvent) error {
+			return errors.New("boom")
+		}),
+		"slow": notifierFunc(func(ctx context.Context, e BuildEvent) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	})
+	d.timeout = 10 * time.Millisecond
+
+	errs := d.Dispatch(BuildEvent{})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if errs[0].Error() != "broken: boom" {
+		t.Errorf("expected %q, got %q", "broken: boom", errs[0])
+	}
+	if errs[1].Error() != "slow: "+context.DeadlineExceeded.Error() {
+		t.Errorf("expected the slow notifier to time out, got %q", errs[1])
+	}
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// ResultStore remembers the result of the last completed build of each
+// project and branch.
+type ResultStore interface {
+	// LastResult returns the result of the last completed build of the
+	// branch, and false if no build of the branch has been recorded.
+	LastResult(projectID, branch string) (brigade.JobStatus, bool)
+	// SetResult records the result of a completed build of the branch.
+	SetResult(projectID, branch string, status brigade.JobStatus)
+}
+
+// NewMemoryResultStore returns a ResultStore that keeps results in memory.
+//
+// Results are lost when the process restarts, so the first build of a branch
+// after a restart is never reported as a recovery.
+func NewMemoryResultStore() ResultStore {
+	return &memoryResultStore{results: map[string]brigade.JobStatus{}}
+}
+
+type memoryResultStore struct {
+	mu      sync.Mutex
+	results map[string]brigade.JobStatus
+}
+
+func (m *memoryResultStore) LastResult(projectID, branch string) (brigade.JobStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status, ok := m.results[projectID+"/"+branch]
+	return status, ok
+}
+
+func (m *memoryResultStore) SetResult(projectID, branch string, status brigade.JobStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[projectID+"/"+branch] = status
+}
+
+// Branch returns the branch name of a build's revision, e.g. "master" for
+// "refs/heads/master". Refs that are not branches are returned unchanged.
+func Branch(build *brigade.Build) string {
+	if build.Revision == nil {
+		return ""
+	}
+	return strings.TrimPrefix(build.Revision.Ref, "refs/heads/")
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// filter describes which completed builds a notifier reports.
+type filter struct {
+	branches       []string
+	onlyOnFailure  bool
+	onlyOnRecovery bool
+}
+
+// wants returns true if the filter asks for a notification of a build of the
+// branch that completed with the given status. Builds whose duration
+// regressed are reported whatever their status.
+func (f filter) wants(branch string, status, previous brigade.JobStatus, regressed bool) bool {
+	if len(f.branches) > 0 && !contains(f.branches, branch) {
+		return false
+	}
+	if regressed {
+		return true
+	}
+	failure := status == brigade.JobFailed
+	recovery := status == brigade.JobSucceeded && previous == brigade.JobFailed
+	switch {
+	case f.onlyOnFailure && f.onlyOnRecovery:
+		return failure || recovery
+	case f.onlyOnFailure:
+		return failure
+	case f.onlyOnRecovery:
+		return recovery
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func slackMessageFor(proj *brigade.Project, build *brigade.Build, branch, channel string) slackMessage {
+	result, color := "succeeded", "good"
+	if build.Worker.Status == brigade.JobFailed {
+		result, color = "failed", "danger"
+	}
+
+	commit := ""
+	if build.Revision != nil {
+		commit = build.Revision.Commit
+	}
+	if commit != "" && strings.HasPrefix(proj.Repo.Name, "github.com/") {
+		commit = fmt.Sprintf("<https://%s/commit/%s|%.7s>", proj.Repo.Name, commit, commit)
+	}
+
+	duration := buildDuration(build)
+
+	return slackMessage{
+		Channel: channel,
+		Text:    fmt.Sprintf("Build %s of %s %s", build.ID, proj.Name, result),
+		Attachments: []slackAttachment{{
+			Color: color,
+			Fields: []slackField{
+				{Title: "Repository", Value: proj.Repo.Name, Short: true},
+				{Title: "Branch", Value: branch, Short: true},
+				{Title: "Commit", Value: commit, Short: true},
+				{Title: "Duration", Value: duration, Short: true},
+			},
+		}},
+	}
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func newSlackServer(t *testing.T, messages *[]slackMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("could not decode Slack message: %s", err)
+		}
+		*messages = append(*messages, msg)
+	}))
+}
+
+func completedBuild(ref string, status brigade.JobStatus) *brigade.Build {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &brigade.Build{
+		ID:       "01bx5zzkbkactav9wevgemmvry",
+		Revision: &brigade.Revision{Ref: ref, Commit: "589e15029e1e44dee48de4800daf1f78e64287c0"},
+		Worker: &brigade.Worker{
+			Status:    status,
+			StartTime: start,
+			EndTime:   start.Add(90 * time.Second),
+		},
+	}
+}
+
+func TestSlackNotify(t *testing.T) {
+	var messages []slackMessage
+	srv := newSlackServer(t, &messages)
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		ID:   "brigade-1234",
+		Name: "tennyson/light-brigade",
+		Repo: brigade.Repo{Name: "github.com/tennyson/light-brigade"},
+		Notifications: brigade.Notifications{Slack: brigade.SlackNotifications{
+			WebhookURL: srv.URL,
+			Channel:    "#builds",
+		}},
+	}
+
+	e := BuildEvent{proj, completedBuild("refs/heads/master", brigade.JobFailed)}
+	if err := NewChat(NewMemoryResultStore()).Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.Channel != "#builds" {
+		t.Errorf("expected channel #builds, got %q", msg.Channel)
+	}
+	if !strings.Contains(msg.Text, "failed") {
+		t.Errorf("expected text to report the failure, got %q", msg.Text)
+	}
+	fields := map[string]string{}
+	for _, f := range msg.Attachments[0].Fields {
+		fields[f.Title] = f.Value
+	}
+	if fields["Branch"] != "master" {
+		t.Errorf("expected branch master, got %q", fields["Branch"])
+	}
+	if fields["Duration"] != "1m30s" {
+		t.Errorf("expected duration 1m30s, got %q", fields["Duration"])
+	}
+	if !strings.Contains(fields["Commit"], "https://github.com/tennyson/light-brigade/commit/589e15029e1e44dee48de4800daf1f78e64287c0") {
+		t.Errorf("expected a link to the commit, got %q", fields["Commit"])
+	}
+}
+
+func TestSlackNotifyFilters(t *testing.T) {
+	var messages []slackMessage
+	srv := newSlackServer(t, &messages)
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		ID: "brigade-1234",
+		Notifications: brigade.Notifications{Slack: brigade.SlackNotifications{
+			WebhookURL:     srv.URL,
+			Branches:       []string{"master"},
+			OnlyOnRecovery: true,
+		}},
+	}
+	chat := NewChat(NewMemoryResultStore())
+
+	for _, step := range []struct {
+		ref    string
+		status brigade.JobStatus
+		sent   int
+	}{
+		{"refs/heads/master", brigade.JobSucceeded, 0},
+		{"refs/heads/master", brigade.JobFailed, 0},
+		{"refs/heads/feature", brigade.JobSucceeded, 0},
+		{"refs/heads/master", brigade.JobSucceeded, 1},
+		{"refs/heads/master", brigade.JobSucceeded, 1},
+	} {
+		if err := chat.Notify(context.Background(), BuildEvent{proj, completedBuild(step.ref, step.status)}); err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != step.sent {
+			t.Fatalf("after a %s build of %s, expected %d messages, got %d", step.status, step.ref, step.sent, len(messages))
+		}
+	}
+}
+
+func TestSlackNotifyDurationRegression(t *testing.T) {
+	var messages []slackMessage
+	srv := newSlackServer(t, &messages)
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		ID: "brigade-1234",
+		Notifications: brigade.Notifications{Slack: brigade.SlackNotifications{
+			WebhookURL:    srv.URL,
+			OnlyOnFailure: true,
+		}},
+	}
+	build := completedBuild("refs/heads/master", brigade.JobSucceeded)
+	build.DurationRegression = &brigade.DurationRegression{AverageSeconds: 30, Factor: 3}
+	if err := NewChat(NewMemoryResultStore()).Notify(context.Background(), BuildEvent{proj, build}); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected the regressed build to be reported, got %d messages", len(messages))
+	}
+	for _, f := range messages[0].Attachments[0].Fields {
+		if f.Title == "Duration" && f.Value != "1m30s (3.0x the average of 30s)" {
+			t.Errorf("unexpected duration %q", f.Value)
+		}
+	}
+}
+
+func TestSlackNotifyIncompleteBuild(t *testing.T) {
+	var messages []slackMessage
+	srv := newSlackServer(t, &messages)
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		Notifications: brigade.Notifications{Slack: brigade.SlackNotifications{WebhookURL: srv.URL}},
+	}
+	e := BuildEvent{proj, completedBuild("master", brigade.JobRunning)}
+	if err := NewChat(NewMemoryResultStore()).Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("expected a running build not to be reported, got %d messages", len(messages))
+	}
+}
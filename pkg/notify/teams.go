@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// teamsMessage is an Office 365 connector card, the payload of Microsoft
+// Teams incoming webhooks.
+type teamsMessage struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Sections   []teamsSection `json:"sections"`
+}
+
+type teamsSection struct {
+	ActivityTitle string      `json:"activityTitle"`
+	Facts         []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func teamsMessageFor(proj *brigade.Project, build *brigade.Build, branch string) teamsMessage {
+	result, color := "succeeded", "2EB886"
+	if build.Worker.Status == brigade.JobFailed {
+		result, color = "failed", "A30200"
+	}
+
+	commit := ""
+	if build.Revision != nil {
+		commit = build.Revision.Commit
+	}
+
+	duration := buildDuration(build)
+
+	summary := fmt.Sprintf("Build %s of %s %s", build.ID, proj.Name, result)
+	return teamsMessage{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: color,
+		Summary:    summary,
+		Sections: []teamsSection{{
+			ActivityTitle: summary,
+			Facts: []teamsFact{
+				{Name: "Repository", Value: proj.Repo.Name},
+				{Name: "Branch", Value: branch},
+				{Name: "Commit", Value: commit},
+				{Name: "Duration", Value: duration},
+			},
+		}},
+	}
+}
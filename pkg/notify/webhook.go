@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/webhook"
+)
+
+// SignatureHeader is the header carrying the signature of a build document.
+//
+// The signature uses the same scheme as inbound GitHub webhooks: a hex SHA1
+// HMAC of the body, keyed with the destination's secret and prefixed with
+// "sha1=".
+const SignatureHeader = "X-Brigade-Signature"
+
+// Build lifecycle events reported to outbound webhooks.
+const (
+	EventBuildStarted   = "build.started"
+	EventBuildSucceeded = "build.succeeded"
+	EventBuildFailed    = "build.failed"
+)
+
+// defaultAttempts is the number of times a delivery is attempted.
+const defaultAttempts = 3
+
+// Webhook POSTs signed build documents to a project's outbound webhooks.
+//
+// Deliveries are asynchronous: Notify returns immediately, and failed
+// deliveries are retried a few times before being logged and dropped.
+type Webhook struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+
+	delivered uint64
+	failed    uint64
+}
+
+// NewWebhook creates a new outbound webhook notifier.
+func NewWebhook() *Webhook {
+	return &Webhook{
+		client:   &http.Client{Timeout: DefaultTimeout},
+		attempts: defaultAttempts,
+		backoff:  time.Second,
+	}
+}
+
+// BuildDocument is the JSON document sent to outbound webhooks.
+type BuildDocument struct {
+	Event     string            `json:"event"`
+	ProjectID string            `json:"project_id"`
+	Project   string            `json:"project"`
+	BuildID   string            `json:"build_id"`
+	Type      string            `json:"type"`
+	Provider  string            `json:"provider"`
+	Revision  *brigade.Revision `json:"revision"`
+	Status    brigade.JobStatus `json:"status"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+	// Duration is the duration of the build in seconds. It is zero until
+	// the build completes.
+	Duration float64 `json:"duration"`
+	// DurationRegression is set if the build took much longer than its
+	// project's recent builds.
+	DurationRegression *brigade.DurationRegression `json:"duration_regression,omitempty"`
+}
+
+// Notify sends the build's current state to each of the project's outbound
+// webhooks. Builds whose worker is neither running nor completed are ignored.
+//
+// Deliveries outlive the context, which only bounds the call itself.
+func (w *Webhook) Notify(ctx context.Context, e BuildEvent) error {
+	proj, build := e.Project, e.Build
+	if len(proj.Notifications.Webhooks) == 0 || build.Worker == nil {
+		return nil
+	}
+	event, ok := lifecycleEvent(build.Worker.Status)
+	if !ok {
+		return nil
+	}
+
+	doc := BuildDocument{
+		Event:     event,
+		ProjectID: proj.ID,
+		Project:   proj.Name,
+		BuildID:   build.ID,
+		Type:      build.Type,
+		Provider:  build.Provider,
+		Revision:  build.Revision,
+		Status:    build.Worker.Status,
+		StartTime: build.Worker.StartTime,
+		EndTime:   build.Worker.EndTime,
+
+		DurationRegression: build.DurationRegression,
+	}
+	if !doc.EndTime.IsZero() {
+		doc.Duration = doc.EndTime.Sub(doc.StartTime).Seconds()
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	for _, dest := range proj.Notifications.Webhooks {
+		go w.deliver(dest, event, body)
+	}
+	return nil
+}
+
+// Stats returns the number of successful and failed deliveries.
+func (w *Webhook) Stats() (delivered, failed uint64) {
+	return atomic.LoadUint64(&w.delivered), atomic.LoadUint64(&w.failed)
+}
+
+func lifecycleEvent(status brigade.JobStatus) (string, bool) {
+	switch status {
+	case brigade.JobRunning:
+		return EventBuildStarted, true
+	case brigade.JobSucceeded:
+		return EventBuildSucceeded, true
+	case brigade.JobFailed:
+		return EventBuildFailed, true
+	}
+	return "", false
+}
+
+func (w *Webhook) deliver(dest brigade.WebhookNotification, event string, body []byte) {
+	signature := webhook.SHA1HMAC([]byte(dest.Secret), body)
+	var err error
+	for attempt := 1; attempt <= w.attempts; attempt++ {
+		if err = w.post(dest.URL, signature, body); err == nil {
+			atomic.AddUint64(&w.delivered, 1)
+			return
+		}
+		if attempt < w.attempts {
+			time.Sleep(w.backoff * time.Duration(attempt))
+		}
+	}
+	failed := atomic.AddUint64(&w.failed, 1)
+	log.Printf("notify: dropping %s for %s after %d attempts (%d failed deliveries): %s", event, dest.URL, w.attempts, failed, err)
+}
+
+func (w *Webhook) post(url, signature string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/webhook"
+)
+
+func waitForStats(t *testing.T, w *Webhook, delivered, failed uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if d, f := w.Stats(); d == delivered && f == failed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	d, f := w.Stats()
+	t.Fatalf("expected %d delivered and %d failed, got %d and %d", delivered, failed, d, f)
+}
+
+func TestWebhookNotify(t *testing.T) {
+	docs := make(chan BuildDocument, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), webhook.SHA1HMAC([]byte("half-a-league"), body); got != want {
+			t.Errorf("expected signature %q, got %q", want, got)
+		}
+		var doc BuildDocument
+		if err := json.Unmarshal(body, &doc); err != nil {
+			t.Error(err)
+		}
+		docs <- doc
+	}))
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		ID:   "brigade-1234",
+		Name: "tennyson/light-brigade",
+		Notifications: brigade.Notifications{
+			Webhooks: []brigade.WebhookNotification{{URL: srv.URL, Secret: "half-a-league"}},
+		},
+	}
+	w := NewWebhook()
+	if err := w.Notify(context.Background(), BuildEvent{proj, completedBuild("refs/heads/master", brigade.JobFailed)}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := <-docs
+	if doc.Event != EventBuildFailed {
+		t.Errorf("expected event %s, got %s", EventBuildFailed, doc.Event)
+	}
+	if doc.ProjectID != "brigade-1234" || doc.Revision.Ref != "refs/heads/master" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if doc.Duration != 90 {
+		t.Errorf("expected a duration of 90 seconds, got %v", doc.Duration)
+	}
+	waitForStats(t, w, 1, 0)
+}
+
+func TestWebhookNotifyRetries(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	proj := &brigade.Project{
+		Notifications: brigade.Notifications{
+			Webhooks: []brigade.WebhookNotification{{URL: srv.URL}},
+		},
+	}
+	w := NewWebhook()
+	w.backoff = time.Millisecond
+	if err := w.Notify(context.Background(), BuildEvent{proj, completedBuild("master", brigade.JobRunning)}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStats(t, w, 0, 1)
+	if got := atomic.LoadInt32(&requests); got != defaultAttempts {
+		t.Errorf("expected %d attempts, got %d", defaultAttempts, got)
+	}
+}
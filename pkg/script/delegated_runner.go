@@ -53,6 +53,12 @@ type Runner struct {
 	NoProgress bool
 	Background bool
 	Verbose    bool
+
+	// BuildArgs are passed to the builds created by SendScript.
+	BuildArgs map[string]string
+	// Priority is the priority of the builds created by SendScript. The
+	// project's DefaultPriority is used if it is empty.
+	Priority brigade.BuildPriority
 }
 
 // SendBuild creates and runs a given Brigade build
@@ -100,7 +106,8 @@ func (a *Runner) SendBuild(b *brigade.Build) error {
 func (a *Runner) SendScript(projectName string, data []byte, config []byte, event, commitish, ref string, payload []byte, logLevel string) error {
 
 	projectID := brigade.ProjectID(projectName)
-	if _, err := a.store.GetProject(projectID); err != nil {
+	proj, err := a.store.GetProject(projectID)
+	if err != nil {
 		return fmt.Errorf("could not find the project %q: %s", projectName, err)
 	}
 
@@ -112,10 +119,16 @@ func (a *Runner) SendScript(projectName string, data []byte, config []byte, even
 			Commit: commitish,
 			Ref:    ref,
 		},
-		Payload:  payload,
-		Script:   data,
-		Config:   config,
-		LogLevel: logLevel,
+		Payload:    payload,
+		Script:     data,
+		Config:     config,
+		LogLevel:   logLevel,
+		BuildArgs:  a.BuildArgs,
+		Priority:   a.Priority,
+		CostCenter: proj.CostCenter,
+	}
+	if b.Priority == "" {
+		b.Priority = proj.DefaultPriority
 	}
 	return a.SendBuild(b)
 }
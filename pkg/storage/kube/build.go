@@ -2,14 +2,18 @@ package kube
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/oklog/ulid"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
@@ -18,7 +22,43 @@ import (
 
 const secretTypeBuild = "brigade.sh/build"
 
-const jobFilter = "component in (build, job), heritage = brigade, build = %s"
+// secretTypeIdempotencyKey is the type of the secrets that claim the
+// idempotency keys of builds.
+const secretTypeIdempotencyKey = "brigade.sh/idempotency-key"
+
+// AcceptedTimeAnnotation records when the controller created a build's worker.
+const AcceptedTimeAnnotation = "brigade.sh/accepted-time"
+
+// Resource usage annotations record the resources requested by a build's
+// pods, multiplied by how long each pod ran.
+const (
+	CPUSecondsAnnotation      = "brigade.sh/cpu-seconds"
+	MemoryGBSecondsAnnotation = "brigade.sh/memory-gb-seconds"
+)
+
+// EnvironmentSnapshotAnnotation records the JSON environment snapshot of a
+// build's worker.
+const EnvironmentSnapshotAnnotation = "brigade.sh/environment-snapshot"
+
+// DurationRegressionAnnotation records the JSON duration regression of a
+// build that took much longer than its project's recent builds.
+const DurationRegressionAnnotation = "brigade.sh/duration-regression"
+
+// ApprovalAnnotation records where a build that requires approval stands.
+const ApprovalAnnotation = "brigade.sh/approval"
+
+// RetriedAsAnnotation records the ID of the build a flaky build was rerun as.
+const RetriedAsAnnotation = "brigade.sh/retried-as"
+
+// CompletedAnnotation records when the controller began processing the
+// completion of a build's worker, so that it does so only once.
+const CompletedAnnotation = "brigade.sh/completed"
+
+// NotificationErrorsAnnotation records the errors of the notifications sent
+// about a build, one per line.
+const NotificationErrorsAnnotation = "brigade.sh/notification-errors"
+
+const jobFilter = "component in (build, job, postbuild, postlude), heritage = brigade, build = %s"
 
 // GetBuild returns the build.
 func (s *store) GetBuild(id string) (*brigade.Build, error) {
@@ -83,6 +123,12 @@ func (s *store) DeleteBuild(bid string, options storage.DeleteBuildOptions) erro
 
 // CreateBuild creates a new Secret based on the build options and writes it to storage.
 func (s *store) CreateBuild(build *brigade.Build) error {
+	_, err := s.createBuildSecret(build)
+	return err
+}
+
+// createBuildSecret creates the secret of a build and returns it.
+func (s *store) createBuildSecret(build *brigade.Build) (*v1.Secret, error) {
 	if build.ID == "" {
 		build.ID = genID()
 	}
@@ -106,44 +152,180 @@ func (s *store) CreateBuild(build *brigade.Build) error {
 			"payload": build.Payload,
 		},
 		StringData: map[string]string{
-			"build_id":       buildName,
-			"build_name":     buildName,
-			"short_title":    build.ShortTitle,
-			"long_title":     build.LongTitle,
-			"clone_url":      build.CloneURL,
-			"commit_id":      build.Revision.Commit,
-			"commit_ref":     build.Revision.Ref,
-			"event_provider": build.Provider,
-			"event_type":     build.Type,
-			"project_id":     build.ProjectID,
-			"log_level":      build.LogLevel,
+			"build_id":        buildName,
+			"build_name":      buildName,
+			"short_title":     build.ShortTitle,
+			"long_title":      build.LongTitle,
+			"clone_url":       build.CloneURL,
+			"commit_id":       build.Revision.Commit,
+			"commit_ref":      build.Revision.Ref,
+			"event_provider":  build.Provider,
+			"event_type":      build.Type,
+			"project_id":      build.ProjectID,
+			"log_level":       build.LogLevel,
+			"parent_build_id": build.ParentBuildID,
+			"matrix":          build.Matrix,
+			"cost_center":     build.CostCenter,
+			"trace_parent":    build.TraceParent,
 		},
 	}
 
-	_, err := s.client.CoreV1().Secrets(s.namespace).Create(context.TODO(), &secret, meta.CreateOptions{})
+	if build.FlakyRetry > 0 {
+		secret.StringData["flaky_retry"] = strconv.Itoa(build.FlakyRetry)
+	}
+
+	if build.Priority != "" {
+		secret.Labels["priority"] = string(build.Priority)
+	}
+	if build.IdempotencyKey != "" {
+		secret.Labels["idempotency-key"] = build.IdempotencyKey
+	}
+
+	if len(build.BuildArgs) > 0 {
+		args, err := json.Marshal(build.BuildArgs)
+		if err != nil {
+			return nil, err
+		}
+		secret.Data["build_args"] = args
+	}
+
+	return s.client.CoreV1().Secrets(s.namespace).Create(context.TODO(), &secret, meta.CreateOptions{})
+}
+
+// CreateIdempotentBuild creates a build that carries an idempotency key,
+// unless the key already belongs to a build of the project created within
+// storage.IdempotencyKeyTTL, which it returns instead.
+//
+// The key is claimed by creating a marker secret whose name is derived from
+// the project and the key, so the API server rejects a second claim with
+// AlreadyExists however many API replicas race for it. The marker holds the
+// build ID before the build exists: a request that finds the marker but not
+// the build creates the build under that ID, and the build secret's name
+// keeps that from happening twice. The marker is owned by the build secret,
+// so deleting the build releases the key.
+func (s *store) CreateIdempotentBuild(build *brigade.Build) (*brigade.Build, error) {
+	name := idempotencyMarkerName(build.ProjectID, build.IdempotencyKey)
+	secrets := s.client.CoreV1().Secrets(s.namespace)
+	for attempt := 0; attempt < 3; attempt++ {
+		if build.ID == "" {
+			build.ID = genID()
+		}
+		marker := &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"component": "idempotency-key",
+					"heritage":  "brigade",
+					"project":   build.ProjectID,
+				},
+			},
+			Type: secretTypeIdempotencyKey,
+			Data: map[string][]byte{"build_id": []byte(build.ID)},
+		}
+		marker, err := secrets.Create(context.TODO(), marker, meta.CreateOptions{})
+		if err == nil {
+			return s.createMarkedBuild(build, marker)
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		marker, err = secrets.Get(context.TODO(), name, meta.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(marker.CreationTimestamp.Time) > storage.IdempotencyKeyTTL {
+			// Only delete the expired marker, not one that replaced it since.
+			opts := meta.DeleteOptions{Preconditions: meta.NewUIDPreconditions(string(marker.UID))}
+			if err := secrets.Delete(context.TODO(), name, opts); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				return nil, err
+			}
+			build.ID = ""
+			continue
+		}
+		build.ID = string(marker.Data["build_id"])
+		return s.createMarkedBuild(build, marker)
+	}
+	return nil, fmt.Errorf("could not claim idempotency key %q of project %s", build.IdempotencyKey, build.ProjectID)
+}
+
+// createMarkedBuild creates the build of an idempotency key marker. If the
+// build already exists, it returns that build instead.
+func (s *store) createMarkedBuild(build *brigade.Build, marker *v1.Secret) (*brigade.Build, error) {
+	secret, err := s.createBuildSecret(build)
+	if apierrors.IsAlreadyExists(err) {
+		secret, err = s.client.CoreV1().Secrets(s.namespace).Get(context.TODO(), "brigade-worker-"+build.ID, meta.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		b := NewBuildFromSecret(*secret)
+		// The worker does not exist while the build is queued.
+		b.Worker, _ = s.GetWorker(b.ID)
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	marker.OwnerReferences = []meta.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Name:       secret.Name,
+		UID:        secret.UID,
+	}}
+	if _, err := s.client.CoreV1().Secrets(s.namespace).Update(context.TODO(), marker, meta.UpdateOptions{}); err != nil {
+		log.Printf("could not make build %s the owner of its idempotency key: %s", build.ID, err)
+	}
+	return nil, nil
+}
+
+// idempotencyMarkerName returns the name of the marker secret of a project's
+// idempotency key.
+func idempotencyMarkerName(projectID, key string) string {
+	return fmt.Sprintf("brigade-idempotency-%x", sha256.Sum256([]byte(projectID+"/"+key)))
+}
+
+// ApproveBuild approves a build that is waiting for approval. It fails with
+// storage.ErrConflict if the build secret changed while it was approved.
+func (s *store) ApproveBuild(id string) error {
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(context.TODO(), "brigade-worker-"+id, meta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not find build %s: %s", id, err)
+	}
+	if status := brigade.ApprovalStatus(secret.Annotations[ApprovalAnnotation]); status != brigade.ApprovalPending {
+		return fmt.Errorf("build %s is not waiting for approval", id)
+	}
+	secret.Annotations[ApprovalAnnotation] = string(brigade.ApprovalApproved)
+	_, err = s.client.CoreV1().Secrets(s.namespace).Update(context.TODO(), secret, meta.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return storage.ErrConflict
+	}
 	return err
 }
 
 // GetBuilds returns all the builds in storage.
 func (s *store) GetBuilds() ([]*brigade.Build, error) {
-	lo := meta.ListOptions{LabelSelector: "heritage=brigade,component=build"}
+	selector := "heritage=brigade,component=build"
 
-	secretList, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), lo)
+	secrets, err := s.listSecrets(selector)
 	if err != nil {
 		return nil, err
 	}
 
-	podList, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), lo)
+	pods, err := s.listPods(selector)
 	if err != nil {
 		return nil, err
 	}
 
-	buildList := make([]*brigade.Build, len(secretList.Items))
-	for i := range secretList.Items {
-		b := NewBuildFromSecret(secretList.Items[i])
+	buildList := make([]*brigade.Build, len(secrets))
+	for i := range secrets {
+		b := NewBuildFromSecret(secrets[i])
 		// The error is ErrWorkerNotFound, and in that case, we just ignore
 		// it and assign nil to the worker.
-		b.Worker, _ = findWorker(b.ID, podList.Items)
+		b.Worker, _ = findWorker(b.ID, pods)
 		buildList[i] = b
 	}
 	return buildList, nil
@@ -201,7 +383,7 @@ func findWorker(id string, pods []v1.Pod) (*brigade.Worker, bool) {
 func NewBuildFromSecret(secret v1.Secret) *brigade.Build {
 	lbs := secret.ObjectMeta.Labels
 	sv := SecretValues(secret.Data)
-	return &brigade.Build{
+	build := &brigade.Build{
 		ID:         lbs["build"],
 		ProjectID:  lbs["project"],
 		Type:       sv.String("event_type"),
@@ -213,9 +395,50 @@ func NewBuildFromSecret(secret v1.Secret) *brigade.Build {
 			Commit: sv.String("commit_id"),
 			Ref:    sv.String("commit_ref"),
 		},
-		Payload: sv.Bytes("payload"),
-		Script:  sv.Bytes("script"),
+		Payload:        sv.Bytes("payload"),
+		Script:         sv.Bytes("script"),
+		Config:         sv.Bytes("config"),
+		ParentBuildID:  sv.String("parent_build_id"),
+		Matrix:         sv.String("matrix"),
+		CostCenter:     sv.String("cost_center"),
+		Priority:       brigade.BuildPriority(lbs["priority"]),
+		IdempotencyKey: lbs["idempotency-key"],
+		TraceParent:    sv.String("trace_parent"),
+		QueuedTime:     secret.CreationTimestamp.Time,
+	}
+	if accepted, ok := secret.Annotations[AcceptedTimeAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, accepted); err == nil {
+			build.AcceptedTime = t
+		}
+	}
+	build.Approval = brigade.ApprovalStatus(secret.Annotations[ApprovalAnnotation])
+	build.FlakyRetry, _ = strconv.Atoi(sv.String("flaky_retry"))
+	build.RetriedAs = secret.Annotations[RetriedAsAnnotation]
+	if snapshot := secret.Annotations[EnvironmentSnapshotAnnotation]; snapshot != "" {
+		build.EnvironmentSnapshot = &brigade.EnvironmentSnapshot{}
+		if err := json.Unmarshal([]byte(snapshot), build.EnvironmentSnapshot); err != nil {
+			log.Printf("build %s has a malformed environment snapshot: %s", build.ID, err)
+			build.EnvironmentSnapshot = nil
+		}
+	}
+	if regression := secret.Annotations[DurationRegressionAnnotation]; regression != "" {
+		build.DurationRegression = &brigade.DurationRegression{}
+		if err := json.Unmarshal([]byte(regression), build.DurationRegression); err != nil {
+			log.Printf("build %s has a malformed duration regression: %s", build.ID, err)
+			build.DurationRegression = nil
+		}
+	}
+	build.CPUSeconds, _ = strconv.ParseFloat(secret.Annotations[CPUSecondsAnnotation], 64)
+	build.MemoryGBSeconds, _ = strconv.ParseFloat(secret.Annotations[MemoryGBSecondsAnnotation], 64)
+	if errs := secret.Annotations[NotificationErrorsAnnotation]; errs != "" {
+		build.NotificationErrors = strings.Split(errs, "\n")
+	}
+	if args := sv.Bytes("build_args"); len(args) > 0 {
+		if err := json.Unmarshal(args, &build.BuildArgs); err != nil {
+			log.Printf("build %s has malformed build args: %s", build.ID, err)
+		}
 	}
+	return build
 }
 
 var entropy = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -4,11 +4,17 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/brigadecore/brigade/pkg/storage"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 )
@@ -39,6 +45,64 @@ func TestNewBuildFromSecret(t *testing.T) {
 	}
 }
 
+func TestNewBuildFromSecret_PhaseTimes(t *testing.T) {
+	queued := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(queued),
+			Annotations: map[string]string{
+				AcceptedTimeAnnotation: "2020-01-01T00:00:30Z",
+			},
+		},
+	}
+	build := NewBuildFromSecret(secret)
+	if !build.QueuedTime.Equal(queued) {
+		t.Errorf("expected queued time %s, got %s", queued, build.QueuedTime)
+	}
+	if expected := queued.Add(30 * time.Second); !build.AcceptedTime.Equal(expected) {
+		t.Errorf("expected accepted time %s, got %s", expected, build.AcceptedTime)
+	}
+
+	delete(secret.Annotations, AcceptedTimeAnnotation)
+	if build := NewBuildFromSecret(secret); !build.AcceptedTime.IsZero() {
+		t.Errorf("expected a queued build to have no accepted time, got %s", build.AcceptedTime)
+	}
+}
+
+func TestNewBuildFromSecret_NotificationErrors(t *testing.T) {
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				NotificationErrorsAnnotation: "chat: unexpected status 404\nemail: queue is full",
+			},
+		},
+	}
+	build := NewBuildFromSecret(secret)
+	expected := []string{"chat: unexpected status 404", "email: queue is full"}
+	if !reflect.DeepEqual(build.NotificationErrors, expected) {
+		t.Errorf("expected notification errors %v, got %v", expected, build.NotificationErrors)
+	}
+}
+
+func TestNewBuildFromSecret_ResourceUsage(t *testing.T) {
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				CPUSecondsAnnotation:      "90.50",
+				MemoryGBSecondsAnnotation: "180.00",
+			},
+		},
+		Data: map[string][]byte{"cost_center": []byte("poetry")},
+	}
+	build := NewBuildFromSecret(secret)
+	if build.CPUSeconds != 90.5 || build.MemoryGBSeconds != 180 {
+		t.Errorf("expected 90.5 CPU and 180 memory seconds, got %v and %v", build.CPUSeconds, build.MemoryGBSeconds)
+	}
+	if build.CostCenter != "poetry" {
+		t.Errorf("expected cost center poetry, got %q", build.CostCenter)
+	}
+}
+
 func TestCreateBuild(t *testing.T) {
 	k, s := fakeStore()
 	if err := s.CreateBuild(stubBuild); err != nil {
@@ -51,6 +115,51 @@ func TestCreateBuild(t *testing.T) {
 	}
 }
 
+func TestCreateBuild_BuildArgs(t *testing.T) {
+	k, s := fakeStore()
+	createFakeWorker(k, stubWorkerPod)
+	build := &brigade.Build{
+		ID:        stubBuildID,
+		ProjectID: stubProjectID,
+		Type:      "exec",
+		Revision:  &brigade.Revision{Ref: "master"},
+		BuildArgs: map[string]string{"suite": "integration", "target": "staging"},
+	}
+	if err := s.CreateBuild(build); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := s.GetBuild(build.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b.BuildArgs, build.BuildArgs) {
+		t.Errorf("expected build args %v, got %v", build.BuildArgs, b.BuildArgs)
+	}
+}
+
+func TestCreateBuild_Priority(t *testing.T) {
+	k, s := fakeStore()
+	createFakeWorker(k, stubWorkerPod)
+	build := &brigade.Build{
+		ID:        stubBuildID,
+		ProjectID: stubProjectID,
+		Revision:  &brigade.Revision{Ref: "master"},
+		Priority:  brigade.PriorityHigh,
+	}
+	if err := s.CreateBuild(build); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := s.GetBuild(build.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Priority != brigade.PriorityHigh {
+		t.Errorf("expected priority high, got %q", b.Priority)
+	}
+}
+
 func TestDeleteBuild(t *testing.T) {
 	k, s := fakeStore()
 	if err := s.CreateBuild(stubBuild); err != nil {
@@ -72,6 +181,34 @@ func TestDeleteBuild(t *testing.T) {
 	}
 }
 
+func TestDeleteBuild_AfterBuildPods(t *testing.T) {
+	k, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range []string{"job", "postbuild", "postlude"} {
+		createFakeJob(k, v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "brigade-" + component + "-" + stubBuildID,
+				Labels: map[string]string{
+					"build":     stubBuildID,
+					"component": component,
+					"heritage":  "brigade",
+				},
+			},
+		})
+	}
+
+	if err := s.DeleteBuild(stubBuild.ID, storage.DeleteBuildOptions{SkipRunningBuilds: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	pods, _ := k.CoreV1().Pods("default").List(context.TODO(), metav1.ListOptions{})
+	for _, p := range pods.Items {
+		t.Errorf("expected pod %s to be deleted", p.Name)
+	}
+}
+
 func TestGetBuild(t *testing.T) {
 	k, s := fakeStore()
 	createFakeWorker(k, stubWorkerPod)
@@ -224,3 +361,127 @@ func TestGetProjectBuilds(t *testing.T) {
 		t.Fatalf("expected 2 builds, got %d", l)
 	}
 }
+
+func TestCreateIdempotentBuild(t *testing.T) {
+	k, s := fakeStore()
+	key := "4f0b4e3c-2d6a-4b8e-9c1f-7a3e5d2b1c0a"
+	newBuild := func() *brigade.Build {
+		return &brigade.Build{
+			ProjectID:      stubProjectID,
+			Revision:       &brigade.Revision{Ref: "master"},
+			IdempotencyKey: key,
+		}
+	}
+	secrets := k.CoreV1().Secrets("default")
+	markerName := idempotencyMarkerName(stubProjectID, key)
+
+	original := newBuild()
+	if b, err := s.CreateIdempotentBuild(original); err != nil || b != nil {
+		t.Fatalf("expected the build to be created, got %+v, %v", b, err)
+	}
+	marker, err := secrets.Get(context.TODO(), markerName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(marker.Data["build_id"]) != original.ID {
+		t.Errorf("expected the marker to hold build %s, got %q", original.ID, marker.Data["build_id"])
+	}
+	if refs := marker.OwnerReferences; len(refs) != 1 || refs[0].Name != "brigade-worker-"+original.ID {
+		t.Errorf("expected the build to own the marker, got %+v", refs)
+	}
+	// The fake client does not set creation timestamps.
+	marker.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	secrets.Update(context.TODO(), marker, metav1.UpdateOptions{})
+
+	b, err := s.CreateIdempotentBuild(newBuild())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || b.ID != original.ID || b.IdempotencyKey != key {
+		t.Fatalf("expected build %s, got %+v", original.ID, b)
+	}
+
+	// A request that claimed the key but did not get to create its build.
+	marker.Data["build_id"] = []byte("orphan")
+	secrets.Update(context.TODO(), marker, metav1.UpdateOptions{})
+	if b, err := s.CreateIdempotentBuild(newBuild()); err != nil || b != nil {
+		t.Fatalf("expected the marked build to be created, got %+v, %v", b, err)
+	}
+	if _, err := secrets.Get(context.TODO(), "brigade-worker-orphan", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected build orphan to be created: %s", err)
+	}
+
+	marker, _ = secrets.Get(context.TODO(), markerName, metav1.GetOptions{})
+	marker.CreationTimestamp = metav1.NewTime(time.Now().Add(-25 * time.Hour))
+	secrets.Update(context.TODO(), marker, metav1.UpdateOptions{})
+	expired := newBuild()
+	if b, err := s.CreateIdempotentBuild(expired); err != nil || b != nil {
+		t.Fatalf("expected an expired key to create a build, got %+v, %v", b, err)
+	}
+	if expired.ID == "orphan" || expired.ID == original.ID {
+		t.Errorf("expected a new build, got %s", expired.ID)
+	}
+
+	other := newBuild()
+	other.IdempotencyKey = "other-key"
+	if b, err := s.CreateIdempotentBuild(other); err != nil || b != nil {
+		t.Errorf("expected another key to create a build, got %+v, %v", b, err)
+	}
+}
+
+func TestApproveBuild(t *testing.T) {
+	k, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApproveBuild(stubBuild.ID); err == nil {
+		t.Error("expected an error approving a build that is not waiting for approval")
+	}
+
+	secrets := k.CoreV1().Secrets("default")
+	secret, _ := secrets.Get(context.TODO(), "brigade-worker-"+stubBuild.ID, metav1.GetOptions{})
+	secret.Annotations = map[string]string{ApprovalAnnotation: string(brigade.ApprovalPending)}
+	secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+
+	if err := s.ApproveBuild(stubBuild.ID); err != nil {
+		t.Fatal(err)
+	}
+	secret, _ = secrets.Get(context.TODO(), "brigade-worker-"+stubBuild.ID, metav1.GetOptions{})
+	if b := NewBuildFromSecret(*secret); b.Approval != brigade.ApprovalApproved {
+		t.Errorf("expected the build to be approved, got %q", b.Approval)
+	}
+}
+
+func TestApproveBuild_Conflict(t *testing.T) {
+	k, s := fakeStore()
+	build := &brigade.Build{ID: "conflicted", ProjectID: stubProjectID, Revision: &brigade.Revision{Ref: "master"}}
+	if err := s.CreateBuild(build); err != nil {
+		t.Fatal(err)
+	}
+	secrets := k.CoreV1().Secrets("default")
+	secret, _ := secrets.Get(context.TODO(), "brigade-worker-conflicted", metav1.GetOptions{})
+	secret.Annotations = map[string]string{ApprovalAnnotation: string(brigade.ApprovalPending)}
+	secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+
+	// The controller updates the secret between the store's get and update.
+	k.(*fake.Clientset).PrependReactor("update", "secrets", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "brigade-worker-conflicted", nil)
+	})
+	if err := s.ApproveBuild("conflicted"); err != storage.ErrConflict {
+		t.Errorf("expected %v, got %v", storage.ErrConflict, err)
+	}
+}
+
+func TestNewBuildFromSecret_EnvironmentSnapshot(t *testing.T) {
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				EnvironmentSnapshotAnnotation: `{"worker_image":"brigadecore/brigade-worker:latest","worker_image_id":"sha256:aaa"}`,
+			},
+		},
+	}
+	build := NewBuildFromSecret(secret)
+	if s := build.EnvironmentSnapshot; s == nil || s.WorkerImageID != "sha256:aaa" {
+		t.Errorf("expected the worker image ID to be sha256:aaa, got %+v", s)
+	}
+}
@@ -0,0 +1,49 @@
+package kube
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// listPageSize is the number of objects requested per List call. Lists of
+// builds and projects are assembled from pages of this size, so the API
+// server never has to send all of them in one response.
+const listPageSize = 100
+
+// listSecrets returns all the secrets in the store's namespace matching the
+// label selector, following the continue token of each page.
+func (s *store) listSecrets(selector string) ([]v1.Secret, error) {
+	var secrets []v1.Secret
+	lo := meta.ListOptions{LabelSelector: selector, Limit: listPageSize}
+	for {
+		page, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), lo)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, page.Items...)
+		if page.Continue == "" {
+			return secrets, nil
+		}
+		lo.Continue = page.Continue
+	}
+}
+
+// listPods returns all the pods in the store's namespace matching the label
+// selector, following the continue token of each page.
+func (s *store) listPods(selector string) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	lo := meta.ListOptions{LabelSelector: selector, Limit: listPageSize}
+	for {
+		page, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), lo)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, page.Items...)
+		if page.Continue == "" {
+			return pods, nil
+		}
+		lo.Continue = page.Continue
+	}
+}
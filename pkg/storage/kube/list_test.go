@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+func TestListSecretsPaginates(t *testing.T) {
+	const total = 2*listPageSize + 1
+	client := fake.NewSimpleClientset()
+	requests := 0
+	// The fake clientset neither records nor honors Limit and Continue, so
+	// serve one page per request.
+	client.PrependReactor("list", "secrets", func(action core.Action) (bool, runtime.Object, error) {
+		start := requests * listPageSize
+		requests++
+		list := &v1.SecretList{}
+		for i := start; i < total && i < start+listPageSize; i++ {
+			list.Items = append(list.Items, v1.Secret{ObjectMeta: meta.ObjectMeta{
+				Name:   fmt.Sprint("secret-", i),
+				Labels: map[string]string{"heritage": "brigade"},
+			}})
+		}
+		if end := start + listPageSize; end < total {
+			list.Continue = strconv.Itoa(end)
+		}
+		return true, list, nil
+	})
+	s := &store{client: client, namespace: "default"}
+
+	secrets, err := s.listSecrets("heritage=brigade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != total {
+		t.Fatalf("expected %d secrets, got %d", total, len(secrets))
+	}
+	if secrets[total-1].Name != fmt.Sprint("secret-", total-1) {
+		t.Errorf("expected the last secret to be secret-%d, got %s", total-1, secrets[total-1].Name)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
@@ -5,29 +5,33 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"strconv"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 const secretTypeProject = "brigade.sh/project"
 
 // GetProjects retrieves all projects from storage.
 func (s *store) GetProjects() ([]*brigade.Project, error) {
-	lo := meta.ListOptions{LabelSelector: "app=brigade,component=project"}
-	secretList, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), lo)
+	secrets, err := s.listSecrets("app=brigade,component=project")
 	if err != nil {
 		return nil, err
 	}
-	projList := make([]*brigade.Project, len(secretList.Items))
-	for i := range secretList.Items {
+	projList := make([]*brigade.Project, len(secrets))
+	for i := range secrets {
 		var err error
-		projList[i], err = NewProjectFromSecret(&secretList.Items[i], s.namespace)
+		projList[i], err = NewProjectFromSecret(&secrets[i], s.namespace)
 		if err != nil {
 			return nil, err
 		}
@@ -58,8 +62,80 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 		return v1.Secret{}, err
 	}
 
+	webhooksJSON, err := marshalWebhooks(project.Notifications.Webhooks)
+	if err != nil {
+		return v1.Secret{}, err
+	}
+	chatJSON, err := marshalChat(project.Notifications.Chat)
+	if err != nil {
+		return v1.Secret{}, err
+	}
+
+	var defaultBuildArgsJSON []byte
+	if len(project.DefaultBuildArgs) > 0 {
+		if defaultBuildArgsJSON, err = json.Marshal(project.DefaultBuildArgs); err != nil {
+			return v1.Secret{}, err
+		}
+	}
+
+	var brigadejsPathsJSON []byte
+	if len(project.BrigadejsPaths) > 0 {
+		if brigadejsPathsJSON, err = json.Marshal(project.BrigadejsPaths); err != nil {
+			return v1.Secret{}, err
+		}
+	}
+
+	var matrixJSON []byte
+	if len(project.Matrix) > 0 {
+		if matrixJSON, err = json.Marshal(project.Matrix); err != nil {
+			return v1.Secret{}, err
+		}
+	}
+
 	bfmt := func(b bool) string { return fmt.Sprintf("%t", b) }
 
+	var debounceWindow string
+	if project.DebounceWindow > 0 {
+		debounceWindow = project.DebounceWindow.String()
+	}
+
+	var buildTimeout string
+	if project.BuildTimeout > 0 {
+		buildTimeout = project.BuildTimeout.String()
+	}
+
+	var approvalTimeout string
+	if project.ApprovalTimeout > 0 {
+		approvalTimeout = project.ApprovalTimeout.String()
+	}
+
+	var lfsConcurrency string
+	if project.LFSConcurrency > 0 {
+		lfsConcurrency = strconv.Itoa(project.LFSConcurrency)
+	}
+
+	var workspaceSizeLimit string
+	if project.WorkspaceSizeLimitMB > 0 {
+		workspaceSizeLimit = strconv.Itoa(project.WorkspaceSizeLimitMB)
+	}
+
+	var flakyPatternsJSON []byte
+	if len(project.FlakyPatterns) > 0 {
+		if flakyPatternsJSON, err = json.Marshal(project.FlakyPatterns); err != nil {
+			return v1.Secret{}, err
+		}
+	}
+
+	var maxDurationRegressionFactor string
+	if project.MaxDurationRegressionFactor > 0 {
+		maxDurationRegressionFactor = strconv.FormatFloat(project.MaxDurationRegressionFactor, 'f', -1, 64)
+	}
+
+	var flakyRetryCount string
+	if project.FlakyRetryCount > 0 {
+		flakyRetryCount = strconv.Itoa(project.FlakyRetryCount)
+	}
+
 	secret := v1.Secret{
 		ObjectMeta: meta.ObjectMeta{
 			Name: project.ID,
@@ -92,6 +168,7 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 			"sshKey":     project.Repo.SSHKey,
 			"sshCert":    project.Repo.SSHCert,
 			"cloneURL":   project.Repo.CloneURL,
+			"bundleURI":  project.Repo.BundleURI,
 
 			"secrets": string(secretsJSON),
 
@@ -100,19 +177,53 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 			"worker.tag":        project.Worker.Tag,
 			"worker.pullPolicy": project.Worker.PullPolicy,
 
+			"notifications.slack.webhookURL":     project.Notifications.Slack.WebhookURL,
+			"notifications.slack.channel":        project.Notifications.Slack.Channel,
+			"notifications.slack.branches":       strings.Join(project.Notifications.Slack.Branches, ","),
+			"notifications.slack.onlyOnFailure":  bfmt(project.Notifications.Slack.OnlyOnFailure),
+			"notifications.slack.onlyOnRecovery": bfmt(project.Notifications.Slack.OnlyOnRecovery),
+			"notifications.email.recipients":     strings.Join(project.Notifications.Email.Recipients, ","),
+			"notifications.email.branches":       strings.Join(project.Notifications.Email.Branches, ","),
+			"notifications.email.onlyOnFailure":  bfmt(project.Notifications.Email.OnlyOnFailure),
+			"notifications.email.onlyOnRecovery": bfmt(project.Notifications.Email.OnlyOnRecovery),
+			"notifications.webhooks":             string(webhooksJSON),
+			"notifications.chat":                 string(chatJSON),
+
 			// These exist in the chart, but not in the brigade.Project
 			"initGitSubmodules":    bfmt(project.InitGitSubmodules),
+			"sparseCheckoutPaths":  strings.Join(project.SparseCheckoutPaths, ","),
+			"lfsConcurrency":       lfsConcurrency,
 			"imagePullSecrets":     project.ImagePullSecrets,
 			"allowPrivilegedJobs":  bfmt(project.AllowPrivilegedJobs),
 			"allowHostMounts":      bfmt(project.AllowHostMounts),
 			"workerCommand":        project.WorkerCommand,
 			"brigadejsPath":        project.BrigadejsPath,
+			"brigadejsPaths":       string(brigadejsPathsJSON),
 			"brigadeConfigPath":    project.BrigadeConfigPath,
 			"genericGatewaySecret": project.GenericGatewaySecret,
+			"defaultBuildArgs":     string(defaultBuildArgsJSON),
+			"allowedBuildArgKeys":  strings.Join(project.AllowedBuildArgKeys, ","),
+			"knownEvents":          strings.Join(project.KnownEvents, ","),
+			"matrix":               string(matrixJSON),
+			"debounceWindow":       debounceWindow,
+			"buildTimeout":         buildTimeout,
+			"workspaceSizeLimitMB": workspaceSizeLimit,
+			"flakyPatterns":        string(flakyPatternsJSON),
+			"flakyRetryCount":      flakyRetryCount,
+			"httpProxy":            project.HTTPProxy,
+			"httpsProxy":           project.HTTPSProxy,
+			"defaultPriority":      string(project.DefaultPriority),
+			"costCenter":           project.CostCenter,
+			"postBuildScript":      project.PostBuildScript,
+			"requiresApproval":     bfmt(project.RequiresApproval),
+			"approvalTimeout":      approvalTimeout,
+
+			"maxDurationRegressionFactor": maxDurationRegressionFactor,
 
 			"kubernetes.cacheStorageClass": project.Kubernetes.CacheStorageClass,
 			"kubernetes.buildStorageClass": project.Kubernetes.BuildStorageClass,
 			"kubernetes.allowSecretKeyRef": strconv.FormatBool(project.Kubernetes.AllowSecretKeyRef),
+			"kubernetes.jobNamespace":      project.Kubernetes.JobNamespace,
 		},
 	}
 	return secret, nil
@@ -135,7 +246,9 @@ func (s *store) CreateProject(project *brigade.Project) error {
 
 // ReplaceProject replaces an existing project.
 //
-// Project ID is a required field. If empty, function will exit
+// Project ID is a required field. If empty, function will exit. If the
+// project has a ResourceVersion, the API server refuses the update unless
+// the secret is still at that version.
 func (s *store) ReplaceProject(project *brigade.Project) error {
 	if project.ID == "" {
 		return fmt.Errorf("Project ID is empty")
@@ -144,26 +257,47 @@ func (s *store) ReplaceProject(project *brigade.Project) error {
 	if err != nil {
 		return err
 	}
+	secret.ResourceVersion = project.ResourceVersion
 
 	_, err = s.client.CoreV1().Secrets(s.namespace).Update(context.TODO(), &secret, meta.UpdateOptions{})
-
+	s.projects.invalidate(project.ID)
+	if apierrors.IsConflict(err) {
+		return storage.ErrConflict
+	}
 	return err
 }
 
 // DeleteProject deletes a project from storage.
 func (s *store) DeleteProject(id string) error {
+	s.projects.invalidate(id)
 	return s.client.CoreV1().Secrets(s.namespace).Delete(context.TODO(), id, meta.DeleteOptions{})
 }
 
+// InvalidateProjectCache drops the project from the project cache.
+func (s *store) InvalidateProjectCache(id string) {
+	s.projects.invalidate(brigade.ProjectID(id))
+}
+
+// ProjectCacheStats returns the hits and misses of the project cache.
+func (s *store) ProjectCacheStats() storage.CacheStats {
+	return s.projects.statistics()
+}
+
 // loadProjectConfig loads a project config from inside of Kubernetes.
 //
-// The namespace is the namespace where the secret is stored.
+// The namespace is the namespace where the secret is stored. Secrets are
+// cached for DefaultProjectCacheTTL, or until they change.
 func (s *store) loadProjectConfig(id string) (*brigade.Project, error) {
+	s.watchProjects.Do(func() { s.projects.watch(s.client, s.namespace) })
+	if secret := s.projects.get(id); secret != nil {
+		return NewProjectFromSecret(secret, s.namespace)
+	}
 	// The project config is stored in a secret.
 	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(context.TODO(), id, meta.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
+	s.projects.add(id, secret)
 	return NewProjectFromSecret(secret, s.namespace)
 }
 
@@ -174,6 +308,7 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	proj := new(brigade.Project)
 	proj.ID = secret.ObjectMeta.Name
 	proj.Name = secret.Annotations["projectName"]
+	proj.ResourceVersion = secret.ObjectMeta.ResourceVersion
 
 	proj.SharedSecret = sv.String("sharedSecret")
 	proj.Github.Token = sv.String("github.token")
@@ -186,6 +321,7 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	proj.Kubernetes.BuildStorageClass = sv.String("kubernetes.buildStorageClass")
 	proj.Kubernetes.CacheStorageClass = sv.String("kubernetes.cacheStorageClass")
 	proj.Kubernetes.ServiceAccount = sv.String("serviceAccount")
+	proj.Kubernetes.JobNamespace = sv.String("kubernetes.jobNamespace")
 
 	if sv.String("kubernetes.allowSecretKeyRef") != "" {
 		if allowSecretKeyRef, err := strconv.ParseBool(sv.String("kubernetes.allowSecretKeyRef")); err == nil {
@@ -195,6 +331,78 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 		}
 	}
 
+	if sv.String("defaultPriority") != "" {
+		if priority, err := brigade.ParseBuildPriority(sv.String("defaultPriority")); err == nil {
+			proj.DefaultPriority = priority
+		} else {
+			return nil, fmt.Errorf("error parsing 'defaultPriority': %s", err.Error())
+		}
+	}
+
+	if sv.String("debounceWindow") != "" {
+		if debounceWindow, err := time.ParseDuration(sv.String("debounceWindow")); err == nil {
+			proj.DebounceWindow = debounceWindow
+		} else {
+			return nil, fmt.Errorf("error parsing 'debounceWindow': %s", err.Error())
+		}
+	}
+
+	if sv.String("buildTimeout") != "" {
+		if buildTimeout, err := time.ParseDuration(sv.String("buildTimeout")); err == nil {
+			proj.BuildTimeout = buildTimeout
+		} else {
+			return nil, fmt.Errorf("error parsing 'buildTimeout': %s", err.Error())
+		}
+	}
+
+	if sv.String("lfsConcurrency") != "" {
+		if lfsConcurrency, err := strconv.Atoi(sv.String("lfsConcurrency")); err == nil && lfsConcurrency > 0 {
+			proj.LFSConcurrency = lfsConcurrency
+		} else {
+			return nil, fmt.Errorf("error parsing 'lfsConcurrency': must be a positive integer, got %q", sv.String("lfsConcurrency"))
+		}
+	}
+
+	if sv.String("workspaceSizeLimitMB") != "" {
+		if limit, err := strconv.Atoi(sv.String("workspaceSizeLimitMB")); err == nil && limit >= 0 {
+			proj.WorkspaceSizeLimitMB = limit
+		} else {
+			return nil, fmt.Errorf("error parsing 'workspaceSizeLimitMB': must be a non-negative integer, got %q", sv.String("workspaceSizeLimitMB"))
+		}
+	}
+
+	if d := sv.Bytes("flakyPatterns"); len(d) > 0 {
+		if err := json.Unmarshal(d, &proj.FlakyPatterns); err != nil {
+			return nil, fmt.Errorf("error parsing 'flakyPatterns': %s", err)
+		}
+		for _, pattern := range proj.FlakyPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("error parsing 'flakyPatterns': %s", err)
+			}
+		}
+	}
+
+	if sv.String("flakyRetryCount") != "" {
+		if count, err := strconv.Atoi(sv.String("flakyRetryCount")); err == nil && count >= 0 {
+			proj.FlakyRetryCount = count
+		} else {
+			return nil, fmt.Errorf("error parsing 'flakyRetryCount': must be a non-negative integer, got %q", sv.String("flakyRetryCount"))
+		}
+	}
+
+	if sv.String("maxDurationRegressionFactor") != "" {
+		if factor, err := strconv.ParseFloat(sv.String("maxDurationRegressionFactor"), 64); err == nil && (factor == 0 || factor > 1) {
+			proj.MaxDurationRegressionFactor = factor
+		} else {
+			return nil, fmt.Errorf("error parsing 'maxDurationRegressionFactor': must be 0 or greater than 1, got %q", sv.String("maxDurationRegressionFactor"))
+		}
+	}
+
+	proj.HTTPProxy = sv.String("httpProxy")
+	proj.HTTPSProxy = sv.String("httpsProxy")
+	proj.CostCenter = sv.String("costCenter")
+	proj.PostBuildScript = sv.String("postBuildScript")
+
 	proj.DefaultScript = sv.String("defaultScript")
 	proj.DefaultScriptName = sv.String("defaultScriptName")
 	proj.DefaultConfig = sv.String("defaultConfig")
@@ -203,9 +411,10 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	proj.Repo = brigade.Repo{
 		Name: sv.String("repository"),
 		// Note that we have to undo the key escaping.
-		SSHKey:   strings.Replace(sv.String("sshKey"), "$", "\n", -1),
-		SSHCert:  strings.Replace(sv.String("sshCert"), "$", "\n", -1),
-		CloneURL: sv.String("cloneURL"),
+		SSHKey:    strings.Replace(sv.String("sshKey"), "$", "\n", -1),
+		SSHCert:   strings.Replace(sv.String("sshCert"), "$", "\n", -1),
+		CloneURL:  sv.String("cloneURL"),
+		BundleURI: sv.String("bundleURI"),
 	}
 
 	envVars := map[string]interface{}{}
@@ -218,6 +427,24 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 
 	proj.GenericGatewaySecret = sv.String("genericGatewaySecret")
 
+	if d := sv.Bytes("defaultBuildArgs"); len(d) > 0 {
+		if err := json.Unmarshal(d, &proj.DefaultBuildArgs); err != nil {
+			return nil, err
+		}
+	}
+	if d := sv.Bytes("matrix"); len(d) > 0 {
+		if err := json.Unmarshal(d, &proj.Matrix); err != nil {
+			return nil, fmt.Errorf("error parsing 'matrix': %s", err)
+		}
+	}
+
+	if keys := sv.String("allowedBuildArgKeys"); keys != "" {
+		proj.AllowedBuildArgKeys = strings.Split(keys, ",")
+	}
+	if known := sv.String("knownEvents"); known != "" {
+		proj.KnownEvents = strings.Split(known, ",")
+	}
+
 	proj.Worker = brigade.WorkerConfig{
 		Registry:   sv.String("worker.registry"),
 		Name:       sv.String("worker.name"),
@@ -225,17 +452,141 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 		PullPolicy: sv.String("worker.pullPolicy"),
 	}
 
+	proj.Notifications.Slack = brigade.SlackNotifications{
+		WebhookURL:     sv.String("notifications.slack.webhookURL"),
+		Channel:        sv.String("notifications.slack.channel"),
+		OnlyOnFailure:  strings.ToLower(sv.String("notifications.slack.onlyOnFailure")) == "true",
+		OnlyOnRecovery: strings.ToLower(sv.String("notifications.slack.onlyOnRecovery")) == "true",
+	}
+	if branches := sv.String("notifications.slack.branches"); branches != "" {
+		proj.Notifications.Slack.Branches = strings.Split(branches, ",")
+	}
+	proj.Notifications.Email = brigade.EmailNotifications{
+		OnlyOnFailure:  strings.ToLower(sv.String("notifications.email.onlyOnFailure")) == "true",
+		OnlyOnRecovery: strings.ToLower(sv.String("notifications.email.onlyOnRecovery")) == "true",
+	}
+	if recipients := sv.String("notifications.email.recipients"); recipients != "" {
+		proj.Notifications.Email.Recipients = strings.Split(recipients, ",")
+	}
+	if branches := sv.String("notifications.email.branches"); branches != "" {
+		proj.Notifications.Email.Branches = strings.Split(branches, ",")
+	}
+	webhooks, err := unmarshalWebhooks(sv.Bytes("notifications.webhooks"))
+	if err != nil {
+		return nil, err
+	}
+	proj.Notifications.Webhooks = webhooks
+	chat, err := unmarshalChat(sv.Bytes("notifications.chat"))
+	if err != nil {
+		return nil, err
+	}
+	proj.Notifications.Chat = chat
+
 	// git submodules and host mounts are false by default. Priv jobs are true by default.
 	proj.InitGitSubmodules = strings.ToLower(def(sv.String("initGitSubmodules"), "false")) == "true"
 	proj.AllowPrivilegedJobs = strings.ToLower(def(sv.String("allowPrivilegedJobs"), "true")) == "true"
 	proj.AllowHostMounts = strings.ToLower(def(sv.String("allowHostMounts"), "false")) == "true"
+	proj.RequiresApproval = strings.ToLower(def(sv.String("requiresApproval"), "false")) == "true"
+	if sv.String("approvalTimeout") != "" {
+		if approvalTimeout, err := time.ParseDuration(sv.String("approvalTimeout")); err == nil {
+			proj.ApprovalTimeout = approvalTimeout
+		} else {
+			return nil, fmt.Errorf("error parsing 'approvalTimeout': %s", err.Error())
+		}
+	}
 	proj.ImagePullSecrets = sv.String("imagePullSecrets")
 
+	if paths := sv.String("sparseCheckoutPaths"); paths != "" {
+		proj.SparseCheckoutPaths = strings.Split(paths, ",")
+	}
+
 	proj.BrigadejsPath = sv.String("brigadejsPath")
+	if d := sv.Bytes("brigadejsPaths"); len(d) > 0 {
+		if err := json.Unmarshal(d, &proj.BrigadejsPaths); err != nil {
+			return nil, fmt.Errorf("error parsing 'brigadejsPaths': %s", err)
+		}
+		for event, path := range proj.BrigadejsPaths {
+			if filepath.IsAbs(path) {
+				return nil, fmt.Errorf("error parsing 'brigadejsPaths': path %q of event %q must be relative to the repository root", path, event)
+			}
+		}
+	}
 	proj.WorkerCommand = sv.String("workerCommand")
 	return proj, nil
 }
 
+// storedWebhook is the form in which an outbound webhook is stored in the
+// project secret. Unlike brigade.WebhookNotification, it includes the secret.
+type storedWebhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+func marshalWebhooks(webhooks []brigade.WebhookNotification) ([]byte, error) {
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	stored := make([]storedWebhook, len(webhooks))
+	for i, w := range webhooks {
+		stored[i] = storedWebhook{URL: w.URL, Secret: w.Secret}
+	}
+	return json.Marshal(stored)
+}
+
+func unmarshalWebhooks(data []byte) ([]brigade.WebhookNotification, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var stored []storedWebhook
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("error parsing 'notifications.webhooks': %s", err)
+	}
+	webhooks := make([]brigade.WebhookNotification, len(stored))
+	for i, w := range stored {
+		webhooks[i] = brigade.WebhookNotification{URL: w.URL, Secret: w.Secret}
+	}
+	return webhooks, nil
+}
+
+// storedChat is the form in which a chat destination is stored in the
+// project secret. Unlike brigade.ChatNotification, it includes the URL.
+type storedChat struct {
+	brigade.ChatNotification
+	URL string `json:"url"`
+}
+
+func marshalChat(chat []brigade.ChatNotification) ([]byte, error) {
+	if len(chat) == 0 {
+		return nil, nil
+	}
+	stored := make([]storedChat, len(chat))
+	for i, c := range chat {
+		stored[i] = storedChat{ChatNotification: c, URL: c.URL}
+	}
+	return json.Marshal(stored)
+}
+
+func unmarshalChat(data []byte) ([]brigade.ChatNotification, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var stored []storedChat
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("error parsing 'notifications.chat': %s", err)
+	}
+	chat := make([]brigade.ChatNotification, len(stored))
+	for i, c := range stored {
+		switch c.Type {
+		case brigade.ChatSlack, brigade.ChatTeams, brigade.ChatMattermost:
+		default:
+			return nil, fmt.Errorf("error parsing 'notifications.chat': unknown chat type %q", c.Type)
+		}
+		chat[i] = c.ChatNotification
+		chat[i].URL = c.URL
+	}
+	return chat, nil
+}
+
 func def(a, b string) string {
 	if len(a) == 0 {
 		return b
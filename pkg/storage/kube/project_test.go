@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -65,15 +67,17 @@ func TestCreateProject(t *testing.T) {
 			BuildStorageClass: "3rdGrade",
 			CacheStorageClass: "underwaterbasketweaving",
 			ServiceAccount:    "project-sa",
+			JobNamespace:      "builds",
 		},
 		DefaultScript:     "console.log('hi');",
 		DefaultScriptName: "bernie",
 		DefaultConfig:     `{ "dependencies": { "year": "2.0.20" } }`,
 		DefaultConfigName: "sanders",
 		Repo: brigade.Repo{
-			Name:     "git.example.com/tennyson/light-brigade",
-			SSHKey:   "i know what you did last summer",
-			CloneURL: "http://clown.example.com/clown.git",
+			Name:      "git.example.com/tennyson/light-brigade",
+			SSHKey:    "i know what you did last summer",
+			CloneURL:  "http://clown.example.com/clown.git",
+			BundleURI: "s3://mirrors/clown.bundle",
 		},
 		Secrets: secretsMap,
 		Worker: brigade.WorkerConfig{
@@ -83,6 +87,16 @@ func TestCreateProject(t *testing.T) {
 			PullPolicy: "Always",
 		},
 		InitGitSubmodules:   true,
+		SparseCheckoutPaths: []string{"services/api", "charts"},
+		DefaultBuildArgs:    map[string]string{"suite": "unit"},
+		Matrix:              map[string][]string{"go": {"1.14", "1.15"}},
+		CostCenter:          "poetry",
+		PostBuildScript:     "kubectl delete namespace preview",
+		RequiresApproval:    true,
+		ApprovalTimeout:     2 * time.Hour,
+		AllowedBuildArgKeys: []string{"suite", "target"},
+		KnownEvents:         []string{"deploy"},
+		DebounceWindow:      30 * time.Second,
 		AllowPrivilegedJobs: true,
 		AllowHostMounts:     true,
 		WorkerCommand:       "echo hello",
@@ -128,17 +142,29 @@ func TestCreateProject(t *testing.T) {
 		"buildStorageSize":             proj.Kubernetes.BuildStorageSize,
 		"kubernetes.cacheStorageClass": proj.Kubernetes.CacheStorageClass,
 		"kubernetes.buildStorageClass": proj.Kubernetes.BuildStorageClass,
+		"kubernetes.jobNamespace":      proj.Kubernetes.JobNamespace,
 		"defaultScript":                proj.DefaultScript,
 		"defaultScriptName":            proj.DefaultScriptName,
 		"repository":                   proj.Repo.Name,
 		"sshKey":                       proj.Repo.SSHKey,
 		"cloneURL":                     proj.Repo.CloneURL,
+		"bundleURI":                    proj.Repo.BundleURI,
 		"secrets":                      string(secretsJSON),
 		"worker.registry":              proj.Worker.Registry,
 		"worker.name":                  proj.Worker.Name,
 		"worker.tag":                   proj.Worker.Tag,
 		"worker.pullPolicy":            proj.Worker.PullPolicy,
 		"initGitSubmodules":            fmt.Sprintf("%t", proj.InitGitSubmodules),
+		"sparseCheckoutPaths":          "services/api,charts",
+		"defaultBuildArgs":             `{"suite":"unit"}`,
+		"matrix":                       `{"go":["1.14","1.15"]}`,
+		"costCenter":                   "poetry",
+		"postBuildScript":              "kubectl delete namespace preview",
+		"requiresApproval":             "true",
+		"approvalTimeout":              "2h0m0s",
+		"allowedBuildArgKeys":          "suite,target",
+		"knownEvents":                  "deploy",
+		"debounceWindow":               "30s",
 		"imagePullSecrets":             proj.ImagePullSecrets,
 		"allowPrivilegedJobs":          fmt.Sprintf("%t", proj.AllowPrivilegedJobs),
 		"allowHostMounts":              fmt.Sprintf("%t", proj.AllowHostMounts),
@@ -248,11 +274,17 @@ func TestConfigureProject(t *testing.T) {
 			"buildStorageSize":             []byte("50Mi"),
 			"kubernetes.cacheStorageClass": []byte("hello"),
 			"kubernetes.buildStorageClass": []byte("goodbye"),
+			"kubernetes.jobNamespace":      []byte("builds"),
 			"allowPrivilegedJobs":          []byte("true"),
 			// Default fo allowHostMounts is false. Testing that
-			"initGitSubmodules": []byte("false"),
-			"workerCommand":     []byte("echo hello"),
-			"imagePullSecrets":  []byte("image pull secrets"),
+			"initGitSubmodules":   []byte("false"),
+			"sparseCheckoutPaths": []byte("services/api,charts"),
+			"defaultBuildArgs":    []byte(`{"suite":"unit"}`),
+			"matrix":              []byte(`{"os":["linux","darwin"]}`),
+			"allowedBuildArgKeys": []byte("suite,target"),
+			"debounceWindow":      []byte("1m"),
+			"workerCommand":       []byte("echo hello"),
+			"imagePullSecrets":    []byte("image pull secrets"),
 		},
 	}
 
@@ -322,6 +354,9 @@ func TestConfigureProject(t *testing.T) {
 	if proj.Kubernetes.CacheStorageClass != "hello" {
 		t.Errorf("cacheStorageClass is wrong")
 	}
+	if proj.Kubernetes.JobNamespace != "builds" {
+		t.Errorf("jobNamespace is wrong %s", proj.Kubernetes.JobNamespace)
+	}
 	if !proj.AllowPrivilegedJobs {
 		t.Error("allowPrivilegedJobs should be true")
 	}
@@ -331,6 +366,21 @@ func TestConfigureProject(t *testing.T) {
 	if proj.InitGitSubmodules {
 		t.Error("initGitSubmodules should be false")
 	}
+	if !reflect.DeepEqual(proj.SparseCheckoutPaths, []string{"services/api", "charts"}) {
+		t.Errorf("unexpected sparseCheckoutPaths: %v", proj.SparseCheckoutPaths)
+	}
+	if proj.DefaultBuildArgs["suite"] != "unit" {
+		t.Errorf("unexpected defaultBuildArgs: %v", proj.DefaultBuildArgs)
+	}
+	if !reflect.DeepEqual(proj.Matrix, map[string][]string{"os": {"linux", "darwin"}}) {
+		t.Errorf("unexpected matrix: %v", proj.Matrix)
+	}
+	if !reflect.DeepEqual(proj.AllowedBuildArgKeys, []string{"suite", "target"}) {
+		t.Errorf("unexpected allowedBuildArgKeys: %v", proj.AllowedBuildArgKeys)
+	}
+	if proj.DebounceWindow != time.Minute {
+		t.Errorf("unexpected debounceWindow: %s", proj.DebounceWindow)
+	}
 
 	if proj.WorkerCommand != "echo hello" {
 		t.Error("unexpected worker command")
@@ -349,3 +399,241 @@ func TestDef(t *testing.T) {
 		t.Error("Expected non-default value")
 	}
 }
+
+func TestProjectNotificationWebhooks(t *testing.T) {
+	proj := &brigade.Project{
+		Name: "tennyson/light-brigade",
+		Notifications: brigade.Notifications{
+			Webhooks: []brigade.WebhookNotification{
+				{URL: "https://dashboard.example.com/hooks", Secret: "half-a-league"},
+			},
+		},
+	}
+	secret, err := SecretFromProject(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake client does not convert StringData, so do it here.
+	secret.Data = map[string][]byte{}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+
+	got, err := NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Notifications.Webhooks, proj.Notifications.Webhooks) {
+		t.Errorf("expected webhooks %v, got %v", proj.Notifications.Webhooks, got.Notifications.Webhooks)
+	}
+}
+
+func TestProjectNotificationEmail(t *testing.T) {
+	proj := &brigade.Project{
+		Name: "tennyson/light-brigade",
+		Notifications: brigade.Notifications{
+			Email: brigade.EmailNotifications{
+				Recipients:    []string{"cardigan@example.com", "nolan@example.com"},
+				Branches:      []string{"master"},
+				OnlyOnFailure: true,
+			},
+		},
+	}
+	secret, err := SecretFromProject(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake client does not convert StringData, so do it here.
+	secret.Data = map[string][]byte{}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+
+	got, err := NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Notifications.Email, proj.Notifications.Email) {
+		t.Errorf("expected email notifications %+v, got %+v", proj.Notifications.Email, got.Notifications.Email)
+	}
+}
+
+func TestProjectNotificationChat(t *testing.T) {
+	proj := &brigade.Project{
+		Name: "tennyson/light-brigade",
+		Notifications: brigade.Notifications{
+			Chat: []brigade.ChatNotification{
+				{Type: brigade.ChatTeams, URL: "https://outlook.office.com/webhook/1234", OnlyOnFailure: true},
+				{Type: brigade.ChatMattermost, URL: "https://chat.example.com/hooks/1234", Channel: "builds"},
+			},
+		},
+	}
+	secret, err := SecretFromProject(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Data = map[string][]byte{}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+
+	got, err := NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Notifications.Chat, proj.Notifications.Chat) {
+		t.Errorf("expected chat notifications %+v, got %+v", proj.Notifications.Chat, got.Notifications.Chat)
+	}
+
+	secret.Data["notifications.chat"] = []byte(`[{"type":"irc","url":"irc://example.com"}]`)
+	if _, err := NewProjectFromSecret(&secret, "default"); err == nil {
+		t.Error("expected an error for an unknown chat type")
+	}
+}
+
+func TestNewProjectFromSecret_BuildTimeout(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"buildTimeout": []byte("1h30m")},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.BuildTimeout != 90*time.Minute {
+		t.Errorf("expected a build timeout of 1h30m, got %s", proj.BuildTimeout)
+	}
+
+	secret.Data["buildTimeout"] = []byte("forever")
+	if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+		t.Error("expected an error for an invalid build timeout")
+	}
+}
+
+func TestNewProjectFromSecret_LFSConcurrency(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"lfsConcurrency": []byte("8")},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.LFSConcurrency != 8 {
+		t.Errorf("expected an LFS concurrency of 8, got %d", proj.LFSConcurrency)
+	}
+
+	for _, invalid := range []string{"0", "eight"} {
+		secret.Data["lfsConcurrency"] = []byte(invalid)
+		if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+			t.Errorf("expected an error for an LFS concurrency of %q", invalid)
+		}
+	}
+}
+
+func TestNewProjectFromSecret_WorkspaceSizeLimit(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"workspaceSizeLimitMB": []byte("512")},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.WorkspaceSizeLimitMB != 512 {
+		t.Errorf("expected a workspace size limit of 512MB, got %d", proj.WorkspaceSizeLimitMB)
+	}
+
+	for _, invalid := range []string{"-1", "1G"} {
+		secret.Data["workspaceSizeLimitMB"] = []byte(invalid)
+		if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+			t.Errorf("expected an error for a workspace size limit of %q", invalid)
+		}
+	}
+}
+
+func TestNewProjectFromSecret_MaxDurationRegressionFactor(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"maxDurationRegressionFactor": []byte("2.5")},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.MaxDurationRegressionFactor != 2.5 {
+		t.Errorf("expected a factor of 2.5, got %v", proj.MaxDurationRegressionFactor)
+	}
+
+	for _, invalid := range []string{"1", "x"} {
+		secret.Data["maxDurationRegressionFactor"] = []byte(invalid)
+		if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+			t.Errorf("expected an error for a factor of %q", invalid)
+		}
+	}
+}
+
+func TestNewProjectFromSecret_BrigadejsPaths(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"brigadejsPaths": []byte(`{"push": "ci/push.js", "release": "ci/release.js"}`)},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.BrigadejsPaths["release"] != "ci/release.js" {
+		t.Errorf("expected the release script at ci/release.js, got %v", proj.BrigadejsPaths)
+	}
+
+	for _, invalid := range []string{`["ci/push.js"]`, `{"push": "/ci/push.js"}`} {
+		secret.Data["brigadejsPaths"] = []byte(invalid)
+		if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+			t.Errorf("expected an error for brigadejsPaths %s", invalid)
+		}
+	}
+}
+
+func TestNewProjectFromSecret_FlakyPatterns(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data: map[string][]byte{
+			"flakyPatterns":   []byte(`["ECONNRESET", "timeout after \\d+s"]`),
+			"flakyRetryCount": []byte("2"),
+		},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proj.FlakyPatterns) != 2 || proj.FlakyPatterns[1] != `timeout after \d+s` {
+		t.Errorf("unexpected flaky patterns %q", proj.FlakyPatterns)
+	}
+	if proj.FlakyRetryCount != 2 {
+		t.Errorf("expected a flaky retry count of 2, got %d", proj.FlakyRetryCount)
+	}
+
+	secret.Data["flakyPatterns"] = []byte(`["("]`)
+	if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+		t.Error("expected an error for an invalid flaky pattern")
+	}
+}
+
+func TestNewProjectFromSecret_DefaultPriority(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-1234"},
+		Data:       map[string][]byte{"defaultPriority": []byte("low")},
+	}
+	proj, err := NewProjectFromSecret(secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.DefaultPriority != brigade.PriorityLow {
+		t.Errorf("expected default priority low, got %q", proj.DefaultPriority)
+	}
+
+	secret.Data["defaultPriority"] = []byte("urgent")
+	if _, err := NewProjectFromSecret(secret, "default"); err == nil {
+		t.Error("expected an error for an invalid priority")
+	}
+}
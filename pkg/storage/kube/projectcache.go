@@ -0,0 +1,144 @@
+package kube
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Defaults of the project cache.
+const (
+	DefaultProjectCacheTTL  = 60 * time.Second
+	DefaultProjectCacheSize = 1000
+)
+
+// projectCache keeps the secrets of recently loaded projects for a while, so
+// that gateways do not ask the Kubernetes API for a project on every event.
+//
+// It holds up to size projects, and evicts the least recently used one when
+// full. Secrets rather than projects are cached, so that callers each get
+// their own project. Once watching, it drops the projects whose secrets
+// change or are deleted, so that every process sees changes made by others.
+type projectCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	stats   storage.CacheStats
+}
+
+type projectCacheEntry struct {
+	id      string
+	secret  *v1.Secret
+	expires time.Time
+}
+
+func newProjectCache(ttl time.Duration, size int) *projectCache {
+	return &projectCache{
+		ttl:     ttl,
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the cached secret of the project, or nil if it is not cached
+// or has expired.
+func (c *projectCache) get(id string) *v1.Secret {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*projectCacheEntry)
+		if time.Now().Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.stats.Hits++
+			return e.secret
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return nil
+}
+
+// add caches the secret of the project for the cache's TTL.
+func (c *projectCache) add(id string, secret *v1.Secret) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+	c.entries[id] = c.order.PushFront(&projectCacheEntry{id: id, secret: secret, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate drops the project from the cache.
+func (c *projectCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+}
+
+// watch drops cached projects whose secrets change or are deleted in the
+// namespace, for as long as the process runs.
+func (c *projectCache) watch(client kubernetes.Interface, namespace string) {
+	selector := "app=brigade,component=project"
+	_, ctr := cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return client.CoreV1().Secrets(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return client.CoreV1().Secrets(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1.Secret{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			// Secrets listed when the watch starts or restarts may have
+			// changed since they were cached.
+			AddFunc: func(obj interface{}) {
+				c.invalidate(obj.(*v1.Secret).Name)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.invalidate(newObj.(*v1.Secret).Name)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if secret, ok := obj.(*v1.Secret); ok {
+					c.invalidate(secret.Name)
+				}
+			},
+		},
+	)
+	go ctr.Run(nil)
+}
+
+func (c *projectCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*projectCacheEntry).id)
+}
+
+func (c *projectCache) statistics() storage.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestProjectCache(t *testing.T) {
+	c := newProjectCache(time.Hour, 2)
+	for _, id := range []string{"ahab", "ishmael", "queequeg"} {
+		c.add(id, &v1.Secret{ObjectMeta: meta.ObjectMeta{Name: id}})
+	}
+	if c.get("ahab") != nil {
+		t.Error("expected the least recently used project to be evicted")
+	}
+	if s := c.get("ishmael"); s == nil || s.Name != "ishmael" {
+		t.Errorf("expected ishmael to be cached, got %v", s)
+	}
+
+	// ishmael is now more recently used than queequeg.
+	c.add("starbuck", &v1.Secret{})
+	if c.get("queequeg") != nil {
+		t.Error("expected queequeg to be evicted")
+	}
+	c.invalidate("ishmael")
+	if c.get("ishmael") != nil {
+		t.Error("expected ishmael to be invalidated")
+	}
+
+	if stats := c.statistics(); stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("expected 1 hit and 3 misses, got %+v", stats)
+	}
+}
+
+func TestProjectCache_TTL(t *testing.T) {
+	c := newProjectCache(time.Millisecond, DefaultProjectCacheSize)
+	c.add("ahab", &v1.Secret{})
+	time.Sleep(5 * time.Millisecond)
+	if c.get("ahab") != nil {
+		t.Error("expected the project to expire")
+	}
+}
+
+func TestGetProject_Cached(t *testing.T) {
+	k, s := fakeStore()
+	secrets := k.CoreV1().Secrets("default")
+	setCostCenter := func(costCenter string) {
+		secret := &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{
+				Name:   brigade.ProjectID("pequod"),
+				Labels: map[string]string{"app": "brigade", "component": "project"},
+			},
+			Data: map[string][]byte{"costCenter": []byte(costCenter)},
+		}
+		if _, err := secrets.Update(context.TODO(), secret, meta.UpdateOptions{}); err != nil {
+			secrets.Create(context.TODO(), secret, meta.CreateOptions{})
+		}
+	}
+	setCostCenter("whaling")
+	for i := 0; i < 2; i++ {
+		p, err := s.GetProject("pequod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.CostCenter != "whaling" {
+			t.Errorf("expected the cost center to be whaling, got %q", p.CostCenter)
+		}
+	}
+	if stats := s.ProjectCacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// Changes made behind the store's back show once the watch sees them. The
+	// fake clientset does not replay changes made before the watch started.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		setCostCenter("oil")
+		p, err := s.GetProject("pequod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.CostCenter == "oil" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the updated cost center, got %q", p.CostCenter)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,6 +1,7 @@
 package kube
 
 import (
+	"sync"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
@@ -14,6 +15,9 @@ type store struct {
 	client    kubernetes.Interface
 	namespace string
 	apiCache  apicache.APICache
+	projects  *projectCache
+	// watchProjects starts the watch that keeps the project cache current.
+	watchProjects sync.Once
 }
 
 // New initializes a new storage backend.
@@ -22,5 +26,6 @@ func New(c kubernetes.Interface, namespace string) storage.Store {
 		client:    c,
 		namespace: namespace,
 		apiCache:  apicache.New(c, namespace, time.Duration(60)*time.Second),
+		projects:  newProjectCache(DefaultProjectCacheTTL, DefaultProjectCacheSize),
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,6 +40,7 @@ func NewWorkerFromPod(pod v1.Pod) *brigade.Worker {
 		BuildID:   l["build"],
 		ProjectID: l["project"],
 		Status:    brigade.JobStatus(pod.Status.Phase),
+		TimedOut:  pod.Status.Reason == "DeadlineExceeded",
 	}
 
 	if (worker.Status != brigade.JobPending) && (worker.Status != brigade.JobUnknown) {
@@ -53,6 +55,10 @@ func NewWorkerFromPod(pod v1.Pod) *brigade.Worker {
 		}
 	}
 
+	if pod.Status.Reason == "Evicted" && strings.Contains(pod.Status.Message, `EmptyDir volume "vcs-sidecar"`) {
+		worker.WorkspaceSizeExceeded = true
+	}
+
 	return worker
 }
 func (s *store) GetWorkerLogStream(worker *brigade.Worker) (io.ReadCloser, error) {
@@ -70,3 +70,37 @@ func TestGetWorker(t *testing.T) {
 		t.Fatal("expected correct project ID")
 	}
 }
+
+func TestNewWorkerFromPod_TimedOut(t *testing.T) {
+	start := metav1.NewTime(time.Now())
+	pod := v1.Pod{
+		Status: v1.PodStatus{
+			Phase:     v1.PodFailed,
+			Reason:    "DeadlineExceeded",
+			StartTime: &start,
+		},
+	}
+	if worker := NewWorkerFromPod(pod); !worker.TimedOut {
+		t.Error("expected a worker past its deadline to have timed out")
+	}
+}
+
+func TestNewWorkerFromPod_WorkspaceSizeExceeded(t *testing.T) {
+	start := metav1.NewTime(time.Now())
+	pod := v1.Pod{
+		Status: v1.PodStatus{
+			Phase:     v1.PodFailed,
+			Reason:    "Evicted",
+			Message:   `Usage of EmptyDir volume "vcs-sidecar" exceeds the limit "100Mi". `,
+			StartTime: &start,
+		},
+	}
+	if worker := NewWorkerFromPod(pod); !worker.WorkspaceSizeExceeded {
+		t.Error("expected a worker evicted for its checkout to have exceeded its workspace size")
+	}
+
+	pod.Status.Message = "The node was low on resource: memory."
+	if worker := NewWorkerFromPod(pod); worker.WorkspaceSizeExceeded {
+		t.Error("expected a worker evicted for memory not to have exceeded its workspace size")
+	}
+}
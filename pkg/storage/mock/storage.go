@@ -118,22 +118,20 @@ func (s *Store) CreateProject(p *brigade.Project) error {
 	return nil
 }
 
-// ReplaceProject replaces a project in the internal mock
+// ReplaceProject replaces a project in the internal mock, failing with
+// storage.ErrConflict if the project's ResourceVersion is set and differs
+// from the mock's.
 func (s *Store) ReplaceProject(p *brigade.Project) error {
-	found := false
-	for _, pr := range s.ProjectList {
+	for i, pr := range s.ProjectList {
 		if pr.Name == p.Name {
-			pr = p
-			found = true
-			break
+			if p.ResourceVersion != "" && p.ResourceVersion != pr.ResourceVersion {
+				return storage.ErrConflict
+			}
+			s.ProjectList[i] = p
+			return nil
 		}
 	}
-
-	if !found {
-		return fmt.Errorf("Project with ID %s was not found", p.ID)
-	}
-
-	return nil
+	return fmt.Errorf("Project with ID %s was not found", p.ID)
 }
 
 // DeleteProject deletes a project from the internal mock
@@ -168,9 +166,18 @@ func (s *Store) GetBuilds() ([]*brigade.Build, error) {
 	return s.Builds, nil
 }
 
-// GetBuild gets the first mock Build.
+// GetBuild gets the mock Build with the given ID, or the first one if no ID
+// is given.
 func (s *Store) GetBuild(id string) (*brigade.Build, error) {
-	return s.Builds[0], nil
+	if id == "" {
+		return s.Builds[0], nil
+	}
+	for _, b := range s.Builds {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("mock build not found for %s", id)
 }
 
 // GetBuildJobs gets the mock job wrapped in a slice.
@@ -229,6 +236,39 @@ func (s *Store) CreateBuild(b *brigade.Build) error {
 	return nil
 }
 
+// InvalidateProjectCache does nothing, as the mock has no cache.
+func (s *Store) InvalidateProjectCache(id string) {}
+
+// ProjectCacheStats returns the zero stats, as the mock has no cache.
+func (s *Store) ProjectCacheStats() storage.CacheStats {
+	return storage.CacheStats{}
+}
+
+// ApproveBuild approves the mock Build with the given ID.
+func (s *Store) ApproveBuild(id string) error {
+	for _, b := range s.Builds {
+		if b.ID == id {
+			if b.Approval != brigade.ApprovalPending {
+				return fmt.Errorf("build %s is not waiting for approval", id)
+			}
+			b.Approval = brigade.ApprovalApproved
+			return nil
+		}
+	}
+	return fmt.Errorf("mock build not found for %s", id)
+}
+
+// CreateIdempotentBuild returns the first mock Build of the project with the
+// build's key, or adds the build if there is none.
+func (s *Store) CreateIdempotentBuild(build *brigade.Build) (*brigade.Build, error) {
+	for _, b := range s.Builds {
+		if b.ProjectID == build.ProjectID && b.IdempotencyKey == build.IdempotencyKey {
+			return b, nil
+		}
+	}
+	return nil, s.CreateBuild(build)
+}
+
 // GetStorageClassNames returns the names of the StorageClass instances in the cluster
 func (s *Store) GetStorageClassNames() ([]string, error) {
 	return []string{}, nil
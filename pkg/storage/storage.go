@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 )
@@ -11,6 +14,30 @@ type DeleteBuildOptions struct {
 	SkipRunningBuilds bool
 }
 
+// ErrConflict is returned when replacing a project or approving a build whose
+// record has changed since it was read.
+var ErrConflict = errors.New("the record was changed since it was read")
+
+// IdempotencyKeyTTL is how long a build can be found by its idempotency key.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// CacheStats counts the lookups of a cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// WriteProjectCacheMetrics writes the lookups of the project cache in the
+// Prometheus text format.
+func WriteProjectCacheMetrics(w io.Writer, stats CacheStats) {
+	fmt.Fprintf(w, "# HELP brigade_project_cache_hits_total Projects loaded from the project cache.\n")
+	fmt.Fprintf(w, "# TYPE brigade_project_cache_hits_total counter\n")
+	fmt.Fprintf(w, "brigade_project_cache_hits_total %d\n", stats.Hits)
+	fmt.Fprintf(w, "# HELP brigade_project_cache_misses_total Projects loaded from Kubernetes.\n")
+	fmt.Fprintf(w, "# TYPE brigade_project_cache_misses_total counter\n")
+	fmt.Fprintf(w, "brigade_project_cache_misses_total %d\n", stats.Misses)
+}
+
 // ProjectStore represents storage for projects.
 type ProjectStore interface {
 	// GetProjects retrieves all projects from storage.
@@ -21,10 +48,17 @@ type ProjectStore interface {
 	GetProjectBuilds(proj *brigade.Project) ([]*brigade.Build, error)
 	// CreateProject creates a new project record in storage.
 	CreateProject(proj *brigade.Project) error
-	// ReplaceProject replaces a project record in storage.
+	// ReplaceProject replaces a project record in storage. If the project has
+	// a ResourceVersion, it fails with ErrConflict unless the record is still
+	// at that version.
 	ReplaceProject(proj *brigade.Project) error
 	// DeleteProject deletes a project from storage.
 	DeleteProject(id string) error
+	// InvalidateProjectCache drops the project from the cache of GetProject,
+	// so that it is loaded from storage again.
+	InvalidateProjectCache(id string)
+	// ProjectCacheStats returns the hits and misses of the cache of GetProject.
+	ProjectCacheStats() CacheStats
 }
 
 // Store represents a storage engine for a brigade projects, builds, and jobs.
@@ -38,6 +72,13 @@ type Store interface {
 	DeleteBuild(id string, options DeleteBuildOptions) error
 	// CreateBuild creates a new job for the work queue.
 	CreateBuild(build *brigade.Build) error
+	// ApproveBuild approves a build that is waiting for approval. It fails
+	// with ErrConflict if the build changed while it was approved.
+	ApproveBuild(id string) error
+	// CreateIdempotentBuild creates a build with an idempotency key. If the
+	// project already has a build with that key created within
+	// IdempotencyKeyTTL, it creates nothing and returns that build.
+	CreateIdempotentBuild(build *brigade.Build) (*brigade.Build, error)
 	// GetBuildJobs retrieves all build jobs (pods) from storage.
 	GetBuildJobs(build *brigade.Build) ([]*brigade.Job, error)
 	// GetWorker returns the worker for a given build.
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// buildArgHeaderPrefix is the prefix of the headers that set build arguments
+// for a single delivery, e.g. X-Brigade-Arg-Suite: integration.
+const buildArgHeaderPrefix = "X-Brigade-Arg-"
+
+// buildArgsFromHeaders returns the build arguments set by the request headers.
+//
+// Header names are case-insensitive, so each argument is matched against the
+// project's AllowedBuildArgKeys and stored under the allowed spelling. An
+// error is returned for any argument the project does not allow.
+func buildArgsFromHeaders(proj *brigade.Project, header http.Header) (map[string]string, error) {
+	var args map[string]string
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if !strings.HasPrefix(name, buildArgHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		arg := strings.TrimPrefix(name, buildArgHeaderPrefix)
+		key, ok := allowedBuildArgKey(proj, arg)
+		if !ok {
+			return nil, fmt.Errorf("build arg %q is not allowed for this project", arg)
+		}
+		if args == nil {
+			args = map[string]string{}
+		}
+		args[key] = values[0]
+	}
+	return args, nil
+}
+
+func allowedBuildArgKey(proj *brigade.Project, arg string) (string, bool) {
+	for _, key := range proj.AllowedBuildArgKeys {
+		if strings.EqualFold(key, arg) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestBuildArgsFromHeaders(t *testing.T) {
+	proj := &brigade.Project{AllowedBuildArgKeys: []string{"suite", "deployTarget"}}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Brigade-Arg-Suite", "integration")
+	header.Set("x-brigade-arg-deploytarget", "staging")
+
+	args, err := buildArgsFromHeaders(proj, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"suite": "integration", "deployTarget": "staging"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected build args %v, got %v", expected, args)
+	}
+
+	header.Set("X-Brigade-Arg-Region", "eu")
+	if _, err := buildArgsFromHeaders(proj, header); err == nil {
+		t.Error("expected a build arg outside of the allowlist to be rejected")
+	}
+
+	args, err = buildArgsFromHeaders(proj, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args != nil {
+		t.Errorf("expected no build args, got %v", args)
+	}
+}
+
+func TestGenericWebhookSimpleEventBuildArgsNotAllowed(t *testing.T) {
+	store := newTestStoreWithFakeProjectAndSecret("fakeCode")
+	router := newMockRouterSimpleEvent(store)
+
+	httpRequest := httptest.NewRequest("POST", "/simpleevents/v1/brigade-fakeProject/fakeCode", bytes.NewBuffer([]byte(exampleSimpleEvent)))
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("X-Brigade-Arg-Suite", "integration")
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httpRequest)
+
+	if rw.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rw.Result().StatusCode)
+	}
+	if len(store.Builds) != 0 {
+		t.Errorf("expected no builds to be created, got %d", len(store.Builds))
+	}
+}
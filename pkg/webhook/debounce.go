@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// debouncer groups the builds submitted for the same project, event and ref
+// within the project's DebounceWindow into a single build. Builds of
+// different events are never grouped, since senders may name their events.
+//
+// The first build for a project, event and ref starts a timer. Builds submitted
+// before the timer fires replace the pending build, so that only the latest
+// one is created when it does.
+//
+// Builds of projects with a matrix are expanded when they are created.
+type debouncer struct {
+	store storage.Store
+
+	mu      sync.Mutex
+	pending map[string]pendingBuild
+}
+
+type pendingBuild struct {
+	proj  *brigade.Project
+	build *brigade.Build
+	timer *time.Timer
+}
+
+func newDebouncer(store storage.Store) *debouncer {
+	d := &debouncer{
+		store:   store,
+		pending: map[string]pendingBuild{},
+	}
+	debouncersMu.Lock()
+	debouncers = append(debouncers, d)
+	debouncersMu.Unlock()
+	return d
+}
+
+// createBuild creates the build, or schedules it if the project debounces
+// its builds.
+func (d *debouncer) createBuild(proj *brigade.Project, b *brigade.Build) error {
+	if proj.DebounceWindow <= 0 {
+		return createMatrixBuilds(d.store, proj, b)
+	}
+
+	key := b.ProjectID + "/" + b.Type
+	if b.Revision != nil {
+		key += "/" + b.Revision.Ref
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.pending[key]; ok {
+		log.Printf("debounce: replacing pending build for %s", key)
+		d.pending[key] = pendingBuild{proj, b, p.timer}
+		return nil
+	}
+	inflight.Add(1)
+	timer := time.AfterFunc(proj.DebounceWindow, func() { d.fire(key) })
+	d.pending[key] = pendingBuild{proj, b, timer}
+	return nil
+}
+
+func (d *debouncer) fire(key string) {
+	d.mu.Lock()
+	p, ok := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+	if !ok {
+		// Flushed already.
+		return
+	}
+	defer inflight.Done()
+
+	if err := createMatrixBuilds(d.store, p.proj, p.build); err != nil {
+		log.Printf("debounce: failed to create build for %s: %s", key, err)
+	}
+}
+
+// flush creates the pending builds right away rather than at the end of
+// their windows.
+func (d *debouncer) flush() {
+	d.mu.Lock()
+	var keys []string
+	for key, p := range d.pending {
+		if p.timer.Stop() {
+			keys = append(keys, key)
+		}
+	}
+	d.mu.Unlock()
+
+	// The pending builds were accepted already, so they do not wait in the
+	// build queue, which may be full.
+	for _, key := range keys {
+		go d.fire(key)
+	}
+}
+
+// createMatrixBuilds creates one build per combination of the project's
+// matrix, or the build itself if the project has none. It stops at the first
+// build that cannot be created.
+func createMatrixBuilds(store storage.Store, proj *brigade.Project, b *brigade.Build) error {
+	for _, build := range proj.ExpandMatrix(b) {
+		if err := store.CreateBuild(build); err != nil {
+			return err
+		}
+	}
+	return nil
+}
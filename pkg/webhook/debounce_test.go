@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// lockedStore records created builds and can be read while a debouncer
+// timer creates builds concurrently.
+type lockedStore struct {
+	storage.Store
+	mu     sync.Mutex
+	builds []*brigade.Build
+}
+
+func (s *lockedStore) CreateBuild(b *brigade.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builds = append(s.builds, b)
+	return nil
+}
+
+func (s *lockedStore) created() []*brigade.Build {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*brigade.Build(nil), s.builds...)
+}
+
+func TestDebouncerDisabled(t *testing.T) {
+	store := &lockedStore{}
+	d := newDebouncer(store)
+	proj := &brigade.Project{ID: "brigade-1234"}
+
+	for i := 0; i < 2; i++ {
+		b := &brigade.Build{ProjectID: proj.ID, Revision: &brigade.Revision{Ref: "master"}}
+		if err := d.createBuild(proj, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(store.created()); n != 2 {
+		t.Errorf("expected 2 builds, got %d", n)
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	store := &lockedStore{}
+	d := newDebouncer(store)
+	proj := &brigade.Project{ID: "brigade-1234", DebounceWindow: 50 * time.Millisecond}
+
+	for _, rev := range []brigade.Revision{
+		{Ref: "master", Commit: "1"},
+		{Ref: "master", Commit: "2"},
+		{Ref: "develop", Commit: "3"},
+		{Ref: "master", Commit: "4"},
+	} {
+		rev := rev
+		if err := d.createBuild(proj, &brigade.Build{ProjectID: proj.ID, Revision: &rev}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(store.created()); n != 0 {
+		t.Fatalf("expected no builds within the window, got %d", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.created()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	builds := store.created()
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(builds))
+	}
+	commits := map[string]string{}
+	for _, b := range builds {
+		commits[b.Revision.Ref] = b.Revision.Commit
+	}
+	if commits["master"] != "4" || commits["develop"] != "3" {
+		t.Errorf("expected the latest commit of each ref to be built, got %v", commits)
+	}
+}
+
+func TestDebouncerEvents(t *testing.T) {
+	store := &lockedStore{}
+	d := newDebouncer(store)
+	proj := &brigade.Project{ID: "brigade-1234", DebounceWindow: 50 * time.Millisecond}
+
+	for _, event := range []string{"deploy", "notify"} {
+		b := &brigade.Build{ProjectID: proj.ID, Type: event, Revision: &brigade.Revision{Ref: "master"}}
+		if err := d.createBuild(proj, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.created()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	events := map[string]bool{}
+	for _, b := range store.created() {
+		events[b.Type] = true
+	}
+	if len(events) != 2 || !events["deploy"] || !events["notify"] {
+		t.Errorf("expected a build for each event, got %v", events)
+	}
+}
+
+func TestDebouncerMatrix(t *testing.T) {
+	store := &lockedStore{}
+	d := newDebouncer(store)
+	proj := &brigade.Project{
+		ID:     "brigade-1234",
+		Matrix: map[string][]string{"go": {"1.14", "1.15"}},
+	}
+
+	b := &brigade.Build{ProjectID: proj.ID, Revision: &brigade.Revision{Ref: "master"}}
+	if err := d.createBuild(proj, b); err != nil {
+		t.Fatal(err)
+	}
+	builds := store.created()
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(builds))
+	}
+	for i, want := range []string{"1.14", "1.15"} {
+		if got := builds[i].BuildArgs["go"]; got != want {
+			t.Errorf("expected build %d to have go=%s, got %q", i, want, got)
+		}
+	}
+}
@@ -60,18 +60,22 @@ func (s *dockerPushHook) Handle(c *gin.Context) {
 		return
 	}
 
-	go s.notifyDockerImagePush(proj, commitish, body)
+	trace := traceParent(c.Request.Header)
+	if err := goCreate(func() { s.notifyDockerImagePush(proj, commitish, body, trace) }); err != nil {
+		queueFull(c)
+		return
+	}
 	c.JSON(200, gin.H{"status": "Success"})
 }
 
-func (s *dockerPushHook) notifyDockerImagePush(proj *brigade.Project, commitish string, payload []byte) {
-	if err := s.doDockerImagePush(proj, commitish, payload); err != nil {
+func (s *dockerPushHook) notifyDockerImagePush(proj *brigade.Project, commitish string, payload []byte, traceParent string) {
+	if err := s.doDockerImagePush(proj, commitish, payload, traceParent); err != nil {
 		log.Printf("failed dockerimagepush event: %s", err)
 	}
 
 }
 
-func (s *dockerPushHook) doDockerImagePush(proj *brigade.Project, commitish string, payload []byte) error {
+func (s *dockerPushHook) doDockerImagePush(proj *brigade.Project, commitish string, payload []byte, traceParent string) error {
 	b := &brigade.Build{
 		ProjectID: proj.ID,
 		Type:      "image_push",
@@ -80,9 +84,12 @@ func (s *dockerPushHook) doDockerImagePush(proj *brigade.Project, commitish stri
 		Revision: &brigade.Revision{
 			Ref: commitish,
 		},
+		Priority:    proj.DefaultPriority,
+		CostCenter:  proj.CostCenter,
+		TraceParent: traceParent,
 	}
 	if proj.DefaultScript != "" {
 		b.Script = []byte(proj.DefaultScript)
 	}
-	return s.store.CreateBuild(b)
+	return createMatrixBuilds(s.store, proj, b)
 }
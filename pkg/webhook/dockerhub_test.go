@@ -34,7 +34,7 @@ func TestDoDockerImagePush(t *testing.T) {
 		store: store,
 	}
 
-	if err := hook.doDockerImagePush(proj, commit, []byte(exampleWebhook)); err != nil {
+	if err := hook.doDockerImagePush(proj, commit, []byte(exampleWebhook), ""); err != nil {
 		t.Errorf("failed docker image push: %s", err)
 	}
 	script := string(store.builds[0].Script)
@@ -51,7 +51,7 @@ func TestDoDockerImagePush_WithDefaultScript(t *testing.T) {
 	store := &testStore{}
 	hook := &dockerPushHook{store: store}
 
-	if err := hook.doDockerImagePush(proj, commit, []byte(exampleWebhook)); err != nil {
+	if err := hook.doDockerImagePush(proj, commit, []byte(exampleWebhook), ""); err != nil {
 		t.Errorf("failed docker image push: %s", err)
 	}
 	script := string(store.builds[0].Script)
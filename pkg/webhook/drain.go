@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// inflight counts the builds the handlers accepted but have not created
+	// yet, because they create them after responding or debounce them.
+	inflight sync.WaitGroup
+
+	debouncersMu sync.Mutex
+	debouncers   []*debouncer
+)
+
+// goCreate queues create, which creates builds, so that handlers can respond
+// before the builds are stored. It returns ErrQueueFull if too many builds
+// wait to be created, and the handler should refuse the event.
+func goCreate(create func()) error {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	return currentQueue().add(create)
+}
+
+// Drain creates the builds the handlers accepted but have not created yet,
+// including queued builds and debounced builds, which are created right away. It returns once
+// they are all created, or with the context's error if it is done first.
+//
+// The gateway calls it on shutdown, once it stopped accepting events, so
+// that accepted events are not lost. Builds being created are finished.
+func Drain(ctx context.Context) error {
+	debouncersMu.Lock()
+	for _, d := range debouncers {
+		d.flush()
+	}
+	debouncersMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestDrainFlushesDebouncedBuilds(t *testing.T) {
+	store := &lockedStore{}
+	d := newDebouncer(store)
+	proj := &brigade.Project{ID: "brigade-1234", DebounceWindow: time.Hour}
+
+	b := &brigade.Build{ProjectID: proj.ID, Revision: &brigade.Revision{Ref: "master"}}
+	if err := d.createBuild(proj, b); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.created()); n != 1 {
+		t.Errorf("expected the debounced build to be created, got %d builds", n)
+	}
+}
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+// Headers of the events in-house systems send to the generic gateway.
+const (
+	BrigadeEventHeader  = "X-Brigade-Event"
+	BrigadeTokenHeader  = "X-Brigade-Token"
+	BrigadeRefHeader    = "X-Brigade-Ref"
+	BrigadeCommitHeader = "X-Brigade-Commit"
+)
+
+// brigadeEventName matches the event names scripts can register handlers for.
+var brigadeEventName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,62}$`)
+
+// reservedBrigadeEvents are the events the worker fires itself once a build
+// finishes or fails, which senders cannot trigger.
+var reservedBrigadeEvents = map[string]bool{"after": true, "error": true}
+
+type genericWebhookBrigadeEvent struct {
+	store     storage.Store
+	debouncer *debouncer
+}
+
+// NewGenericWebhookBrigadeEvent creates a gin handler for events named by the
+// X-Brigade-Event header.
+//
+// Unlike the other generic events, the event name is chosen by the sender,
+// and the project's generic gateway secret is sent in the X-Brigade-Token
+// header, optionally as a bearer token, rather than in the URL. The body is
+// any JSON object, passed to the script as the event's payload.
+func NewGenericWebhookBrigadeEvent(s storage.Store) gin.HandlerFunc {
+	h := &genericWebhookBrigadeEvent{store: s, debouncer: newDebouncer(s)}
+	return h.Handle
+}
+
+// Handle handles a generic Gateway event named by its X-Brigade-Event header.
+func (g *genericWebhookBrigadeEvent) Handle(c *gin.Context) {
+	projectID := c.Param("projectID")
+	eventName := c.Request.Header.Get(BrigadeEventHeader)
+	if !brigadeEventName.MatchString(eventName) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "missing or invalid " + BrigadeEventHeader + " header"})
+		return
+	}
+	if reservedBrigadeEvents[eventName] {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "the " + eventName + " event is reserved for the worker"})
+		return
+	}
+
+	proj, err := g.store.GetProject(projectID)
+	if err != nil {
+		log.Printf("Project %q not found. No secret loaded. %s", projectID, err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
+		return
+	}
+
+	token := strings.TrimPrefix(c.Request.Header.Get(BrigadeTokenHeader), "Bearer ")
+	if err := validateGenericGatewaySecret(proj, token); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": err.Error()})
+		return
+	}
+
+	buildArgs, err := buildArgsFromHeaders(proj, c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+		return
+	}
+
+	payload, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("Failed to read body: %s", err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	defer c.Request.Body.Close()
+
+	if len(payload) > 0 {
+		var object map[string]interface{}
+		if err := json.Unmarshal(payload, &object); err != nil {
+			log.Printf("Failed to convert POST data into JSON: %s", err)
+			c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed POST data - must be a JSON object"})
+			return
+		}
+	}
+
+	revision := &brigade.Revision{
+		Ref:    c.Request.Header.Get(BrigadeRefHeader),
+		Commit: c.Request.Header.Get(BrigadeCommitHeader),
+	}
+
+	trace := traceParent(c.Request.Header)
+	if err := goCreate(func() { g.notifyGenericWebhookBrigadeEvent(proj, eventName, payload, revision, buildArgs, trace) }); err != nil {
+		queueFull(c)
+		return
+	}
+	c.JSON(200, gin.H{"status": "Success. Build created"})
+}
+
+func (g *genericWebhookBrigadeEvent) notifyGenericWebhookBrigadeEvent(proj *brigade.Project, eventName string, payload []byte, revision *brigade.Revision, buildArgs map[string]string, traceParent string) {
+	if err := g.genericWebhookBrigadeEvent(proj, eventName, payload, revision, buildArgs, traceParent); err != nil {
+		log.Printf("failed genericWebhook BrigadeEvent: %s", err)
+	}
+}
+
+func (g *genericWebhookBrigadeEvent) genericWebhookBrigadeEvent(proj *brigade.Project, eventName string, payload []byte, revision *brigade.Revision, buildArgs map[string]string, traceParent string) error {
+	b := &brigade.Build{
+		ProjectID:   proj.ID,
+		Type:        eventName,
+		Provider:    "GenericWebhook",
+		Payload:     payload,
+		Revision:    revision,
+		BuildArgs:   buildArgs,
+		Priority:    proj.DefaultPriority,
+		CostCenter:  proj.CostCenter,
+		TraceParent: traceParent,
+	}
+
+	// See genericWebhookSimpleEvent: the sidecar needs a ref or commit.
+	if b.Revision.Commit == "" && b.Revision.Ref == "" {
+		b.Revision.Ref = "master"
+	}
+
+	return g.debouncer.createBuild(proj, b)
+}
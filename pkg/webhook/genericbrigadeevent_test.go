@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+func TestGenericWebHookBrigadeEvent(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		description    string
+		url            string
+		header         map[string]string
+		statusExpected int
+		payload        string
+		revision       *brigade.Revision
+	}{
+		{
+			description:    "Missing event header",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Token": "Bearer fakeCode"},
+			statusExpected: http.StatusBadRequest,
+			payload:        `{}`,
+		},
+		{
+			description:    "Invalid event name",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Event": "deploy now!", "X-Brigade-Token": "fakeCode"},
+			statusExpected: http.StatusBadRequest,
+			payload:        `{}`,
+		},
+		{
+			description:    "Reserved event name",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Event": "after", "X-Brigade-Token": "fakeCode"},
+			statusExpected: http.StatusBadRequest,
+			payload:        `{}`,
+		},
+		{
+			description:    "Wrong project",
+			url:            "/brigadeevents/v1/brigade-fakeProject2",
+			header:         map[string]string{"X-Brigade-Event": "deploy", "X-Brigade-Token": "fakeCode"},
+			statusExpected: http.StatusBadRequest,
+			payload:        `{}`,
+		},
+		{
+			description:    "Wrong token",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Event": "deploy", "X-Brigade-Token": "Bearer wrongCode"},
+			statusExpected: http.StatusUnauthorized,
+			payload:        `{}`,
+		},
+		{
+			description:    "Payload is not a JSON object",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Event": "deploy", "X-Brigade-Token": "Bearer fakeCode"},
+			statusExpected: http.StatusBadRequest,
+			payload:        `["deploy"]`,
+		},
+		{
+			description: "Bearer token and revision headers",
+			url:         "/brigadeevents/v1/brigade-fakeProject",
+			header: map[string]string{
+				"X-Brigade-Event":  "deploy",
+				"X-Brigade-Token":  "Bearer fakeCode",
+				"X-Brigade-Ref":    "refs/heads/changes",
+				"X-Brigade-Commit": "63c09efb6eb544f41a48901a6d0cc6ddfa4adb28",
+			},
+			statusExpected: http.StatusOK,
+			payload:        `{"environment": "staging"}`,
+			revision: &brigade.Revision{
+				Ref:    "refs/heads/changes",
+				Commit: "63c09efb6eb544f41a48901a6d0cc6ddfa4adb28",
+			},
+		},
+		{
+			description:    "Plain token without revision",
+			url:            "/brigadeevents/v1/brigade-fakeProject",
+			header:         map[string]string{"X-Brigade-Event": "inventory.updated", "X-Brigade-Token": "fakeCode"},
+			statusExpected: http.StatusOK,
+			payload:        ``,
+			revision:       &brigade.Revision{Ref: "master"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			store := newTestStoreWithFakeProjectAndSecret("fakeCode")
+			router := newMockRouterBrigadeEvent(store)
+			httpRequest := httptest.NewRequest("POST", test.url, bytes.NewBuffer([]byte(test.payload)))
+			httpRequest.Header.Add("Content-Type", "application/json")
+			for name, value := range test.header {
+				httpRequest.Header.Add(name, value)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httpRequest)
+			if rw.Result().StatusCode != test.statusExpected {
+				t.Errorf("expected error %d, got %d", test.statusExpected, rw.Result().StatusCode)
+			}
+
+			if rw.Result().StatusCode == http.StatusOK {
+				checkBuild(t, store, test.revision.Ref, test.revision.Commit, []byte(test.payload))
+				if len(store.Builds) > 0 && store.Builds[0].Type != test.header["X-Brigade-Event"] {
+					t.Errorf("expected event %q, got %q", test.header["X-Brigade-Event"], store.Builds[0].Type)
+				}
+			}
+		})
+	}
+}
+
+func newMockRouterBrigadeEvent(store storage.Store) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	events := router.Group("/brigadeevents/v1")
+	events.POST("/:projectID", NewGenericWebhookBrigadeEvent(store))
+
+	return router
+}
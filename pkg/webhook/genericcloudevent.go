@@ -15,12 +15,13 @@ import (
 )
 
 type genericWebhookCloudEvent struct {
-	store storage.Store
+	store     storage.Store
+	debouncer *debouncer
 }
 
 // NewGenericWebhookCloudEvent creates a go-restful handler for generic Gateway that will handle CloudEvents.
 func NewGenericWebhookCloudEvent(s storage.Store) gin.HandlerFunc {
-	h := &genericWebhookCloudEvent{store: s}
+	h := &genericWebhookCloudEvent{store: s, debouncer: newDebouncer(s)}
 	return h.Handle
 }
 
@@ -43,6 +44,12 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 		return
 	}
 
+	buildArgs, err := buildArgsFromHeaders(proj, c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+		return
+	}
+
 	payload, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %s", err)
@@ -73,17 +80,21 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 		return
 	}
 
-	go g.notifyGenericWebhookCloudEvent(proj, payload, event)
+	trace := traceParent(c.Request.Header)
+	if err := goCreate(func() { g.notifyGenericWebhookCloudEvent(proj, payload, event, buildArgs, trace) }); err != nil {
+		queueFull(c)
+		return
+	}
 	c.JSON(200, gin.H{"status": "Success"})
 }
 
-func (g *genericWebhookCloudEvent) notifyGenericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event) {
-	if err := g.genericWebhookCloudEvent(proj, payload, event); err != nil {
+func (g *genericWebhookCloudEvent) notifyGenericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event, buildArgs map[string]string, traceParent string) {
+	if err := g.genericWebhookCloudEvent(proj, payload, event, buildArgs, traceParent); err != nil {
 		log.Printf("failed genericWebhook Cloud Event: %s", err)
 	}
 }
 
-func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event) error {
+func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event, buildArgs map[string]string, traceParent string) error {
 	var revision brigade.Revision
 	if event.Data != nil {
 		data := event.Data.(map[string]interface{})
@@ -104,12 +115,16 @@ func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(proj *brigade.Projec
 
 	// create a Build for the specified Revision
 	b := &brigade.Build{
-		ProjectID: proj.ID,
-		Type:      "cloudevent",
-		Provider:  "GenericWebhook",
-		Payload:   payload,
-		Revision:  &revision,
+		ProjectID:   proj.ID,
+		Type:        "cloudevent",
+		Provider:    "GenericWebhook",
+		Payload:     payload,
+		Revision:    &revision,
+		BuildArgs:   buildArgs,
+		Priority:    proj.DefaultPriority,
+		CostCenter:  proj.CostCenter,
+		TraceParent: traceParent,
 	}
 
-	return g.store.CreateBuild(b)
+	return g.debouncer.createBuild(proj, b)
 }
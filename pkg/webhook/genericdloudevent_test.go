@@ -17,7 +17,7 @@ import (
 )
 
 func newTestGenericWebhookHandlerCloudEvent(store storage.Store) *genericWebhookCloudEvent {
-	return &genericWebhookCloudEvent{store}
+	return &genericWebhookCloudEvent{store, newDebouncer(store)}
 }
 
 func TestGenericWebhookCloudEventHandler(t *testing.T) {
@@ -31,7 +31,7 @@ func TestGenericWebhookCloudEventHandler(t *testing.T) {
 		ID:     "ea35b24ede421",
 	}
 
-	if err := h.genericWebhookCloudEvent(proj, []byte(exampleCloudEvent), event); err != nil {
+	if err := h.genericWebhookCloudEvent(proj, []byte(exampleCloudEvent), event, nil, ""); err != nil {
 		t.Errorf("failed generic gateway cloud event: %s", err)
 	}
 
@@ -14,12 +14,13 @@ import (
 )
 
 type genericWebhookSimpleEvent struct {
-	store storage.Store
+	store     storage.Store
+	debouncer *debouncer
 }
 
 // NewGenericWebhookSimpleEvent creates a go-restful handler for generic Gateway.
 func NewGenericWebhookSimpleEvent(s storage.Store) gin.HandlerFunc {
-	h := &genericWebhookSimpleEvent{store: s}
+	h := &genericWebhookSimpleEvent{store: s, debouncer: newDebouncer(s)}
 	return h.Handle
 }
 
@@ -42,6 +43,12 @@ func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
 		return
 	}
 
+	buildArgs, err := buildArgsFromHeaders(proj, c.Request.Header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+		return
+	}
+
 	payload, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %s", err)
@@ -62,23 +69,31 @@ func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
 		}
 	}
 
-	go g.notifyGenericWebhookSimpleEvent(proj, payload, revision)
+	trace := traceParent(c.Request.Header)
+	if err := goCreate(func() { g.notifyGenericWebhookSimpleEvent(proj, payload, revision, buildArgs, trace) }); err != nil {
+		queueFull(c)
+		return
+	}
 	c.JSON(200, gin.H{"status": "Success. Build created"})
 }
 
-func (g *genericWebhookSimpleEvent) notifyGenericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision) {
-	if err := g.genericWebhookSimpleEvent(proj, payload, revision); err != nil {
+func (g *genericWebhookSimpleEvent) notifyGenericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision, buildArgs map[string]string, traceParent string) {
+	if err := g.genericWebhookSimpleEvent(proj, payload, revision, buildArgs, traceParent); err != nil {
 		log.Printf("failed genericWebhook SimpleEvent: %s", err)
 	}
 }
 
-func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision) error {
+func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision, buildArgs map[string]string, traceParent string) error {
 	b := &brigade.Build{
-		ProjectID: proj.ID,
-		Type:      "simpleevent",
-		Provider:  "GenericWebhook",
-		Payload:   payload,
-		Revision:  revision,
+		ProjectID:   proj.ID,
+		Type:        "simpleevent",
+		Provider:    "GenericWebhook",
+		Payload:     payload,
+		Revision:    revision,
+		BuildArgs:   buildArgs,
+		Priority:    proj.DefaultPriority,
+		CostCenter:  proj.CostCenter,
+		TraceParent: traceParent,
 	}
 
 	// set a default Revision if user has not provided any information about commit or ref
@@ -88,7 +103,7 @@ func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(proj *brigade.Proj
 		b.Revision = &brigade.Revision{Ref: "master"}
 	}
 
-	return g.store.CreateBuild(b)
+	return g.debouncer.createBuild(proj, b)
 }
 
 // validateGenericGatewaySecret will return an error if given Project does not have a GenericGatewaySecret or if the provided secret is wrong
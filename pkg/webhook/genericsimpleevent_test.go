@@ -15,7 +15,7 @@ import (
 )
 
 func newTestGenericWebhookSimpleEventHandler(store storage.Store) *genericWebhookSimpleEvent {
-	return &genericWebhookSimpleEvent{store}
+	return &genericWebhookSimpleEvent{store, newDebouncer(store)}
 }
 
 func newGenericProject() *brigade.Project {
@@ -50,7 +50,7 @@ func TestGenericWebhookSimpleEventHandler(t *testing.T) {
 		Commit: "63c09efb6eb544f41a48901a6d0cc6ddfa4adb28",
 	}
 
-	if err := h.genericWebhookSimpleEvent(proj, []byte(exampleSimpleEvent), revision); err != nil {
+	if err := h.genericWebhookSimpleEvent(proj, []byte(exampleSimpleEvent), revision, nil, ""); err != nil {
 		t.Errorf("failed generic gateway event: %s", err)
 	}
 
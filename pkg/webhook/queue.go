@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+const (
+	// DefaultQueueWorkers is the default number of builds created at once.
+	DefaultQueueWorkers = 4
+	// DefaultQueueLength is the default number of accepted builds that can
+	// wait for a worker.
+	DefaultQueueLength = 100
+
+	// queueRetryAfter is how long senders are asked to wait before they
+	// deliver an event again when the queue is full.
+	queueRetryAfter = 30 * time.Second
+)
+
+// ErrQueueFull is returned when an event is refused because too many builds
+// are waiting to be created.
+var ErrQueueFull = errors.New("the build queue is full")
+
+// QueueStats describes the build queue.
+type QueueStats struct {
+	// Depth is the number of builds waiting for a worker.
+	Depth int
+	// Busy is the number of workers creating builds.
+	Busy int
+	// Rejected is the number of events refused because the queue was full.
+	Rejected uint64
+}
+
+// buildQueue creates accepted builds with a fixed number of workers, so that
+// a burst of events does not create all of their builds at once.
+type buildQueue struct {
+	creates  chan func()
+	busy     int64
+	rejected uint64
+}
+
+var (
+	queueMu sync.Mutex
+	queue   *buildQueue
+)
+
+func newBuildQueue(workers, length int) *buildQueue {
+	q := &buildQueue{creates: make(chan func(), length)}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+func (q *buildQueue) work() {
+	for create := range q.creates {
+		atomic.AddInt64(&q.busy, 1)
+		create()
+		atomic.AddInt64(&q.busy, -1)
+		inflight.Done()
+	}
+}
+
+// add queues create, or returns ErrQueueFull if too many builds wait.
+func (q *buildQueue) add(create func()) error {
+	inflight.Add(1)
+	select {
+	case q.creates <- create:
+		return nil
+	default:
+		inflight.Done()
+		atomic.AddUint64(&q.rejected, 1)
+		return ErrQueueFull
+	}
+}
+
+func (q *buildQueue) stats() QueueStats {
+	return QueueStats{
+		Depth:    len(q.creates),
+		Busy:     int(atomic.LoadInt64(&q.busy)),
+		Rejected: atomic.LoadUint64(&q.rejected),
+	}
+}
+
+// ConfigureQueue sets the number of workers creating the builds of accepted
+// events, and the number of builds that can wait for them before events are
+// refused. It is meant to be called before the handlers accept events; the
+// builds already queued are still created.
+func ConfigureQueue(workers, length int) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if queue != nil {
+		close(queue.creates)
+	}
+	queue = newBuildQueue(workers, length)
+}
+
+// currentQueue returns the build queue, with the default size unless it was
+// configured. queueMu must be held.
+func currentQueue() *buildQueue {
+	if queue == nil {
+		queue = newBuildQueue(DefaultQueueWorkers, DefaultQueueLength)
+	}
+	return queue
+}
+
+// QueueStatistics returns the depth, busy workers and rejected events of the
+// build queue.
+func QueueStatistics() QueueStats {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	return currentQueue().stats()
+}
+
+// queueFull answers an event refused because the build queue is full, asking
+// the sender to deliver it again later.
+func queueFull(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(int(queueRetryAfter/time.Second)))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"status": ErrQueueFull.Error()})
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+func TestBuildQueueRejectsWhenFull(t *testing.T) {
+	q := newBuildQueue(1, 1)
+	defer close(q.creates)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := q.add(func() { close(started); <-release }); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	done := make(chan struct{})
+	if err := q.add(func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.add(func() {}); err != ErrQueueFull {
+		t.Errorf("expected %v, got %v", ErrQueueFull, err)
+	}
+	expect := QueueStats{Depth: 1, Busy: 1, Rejected: 1}
+	if stats := q.stats(); stats != expect {
+		t.Errorf("expected %+v, got %+v", expect, stats)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestQueueFull(t *testing.T) {
+	rw := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rw)
+	queueFull(c)
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rw.Code)
+	}
+	if rw.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected Retry-After 30, got %q", rw.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+// EventHandlerFactory creates the handler for an event type, backed by the given store.
+type EventHandlerFactory func(storage.Store) gin.HandlerFunc
+
+// eventHandlers maps event types to their EventHandlerFactory.
+var eventHandlers sync.Map
+
+func init() {
+	RegisterEventHandler("simpleevents/v1", NewGenericWebhookSimpleEvent)
+	RegisterEventHandler("cloudevents/v02", NewGenericWebhookCloudEvent)
+}
+
+// RegisterEventHandler makes a handler available to the generic gateway for
+// the given event type. The gateway serves the handler under
+// /<eventType>/:projectID/:secret.
+//
+// Packages that add support for new event types call it from their init()
+// function. It panics if the event type is empty or already registered.
+func RegisterEventHandler(eventType string, factory EventHandlerFactory) {
+	if eventType == "" {
+		panic("webhook: RegisterEventHandler with an empty event type")
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("webhook: RegisterEventHandler for %s with a nil factory", eventType))
+	}
+	if _, dup := eventHandlers.LoadOrStore(eventType, factory); dup {
+		panic(fmt.Sprintf("webhook: RegisterEventHandler called twice for %s", eventType))
+	}
+}
+
+// EventTypes returns the sorted list of registered event types.
+func EventTypes() []string {
+	var types []string
+	eventHandlers.Range(func(k, _ interface{}) bool {
+		types = append(types, k.(string))
+		return true
+	})
+	sort.Strings(types)
+	return types
+}
+
+// EventHandler returns the handler for a registered event type, backed by
+// the given store.
+func EventHandler(eventType string, s storage.Store) (gin.HandlerFunc, bool) {
+	factory, ok := eventHandlers.Load(eventType)
+	if !ok {
+		return nil, false
+	}
+	return factory.(EventHandlerFactory)(s), true
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+func TestRegisterEventHandler(t *testing.T) {
+	called := false
+	RegisterEventHandler("test/v1", func(storage.Store) gin.HandlerFunc {
+		return func(*gin.Context) { called = true }
+	})
+	defer eventHandlers.Delete("test/v1")
+
+	expected := []string{"cloudevents/v02", "simpleevents/v1", "test/v1"}
+	if types := EventTypes(); !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected event types %v, got %v", expected, types)
+	}
+
+	handler, ok := EventHandler("test/v1", newTestStore())
+	if !ok {
+		t.Fatal("expected a handler for test/v1")
+	}
+	handler(nil)
+	if !called {
+		t.Error("expected the registered handler to be returned")
+	}
+
+	if _, ok := EventHandler("missing/v1", newTestStore()); ok {
+		t.Error("expected no handler for an unregistered event type")
+	}
+}
+
+func TestRegisterEventHandlerDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a built-in event type to panic")
+		}
+	}()
+	RegisterEventHandler("simpleevents/v1", NewGenericWebhookSimpleEvent)
+}
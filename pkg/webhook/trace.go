@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// w3cTraceParent matches a W3C traceparent header. Later versions extend
+	// the format of version 00, and are passed on as version 00.
+	w3cTraceParent = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})`)
+	b3TraceID      = regexp.MustCompile(`^([0-9a-f]{16}|[0-9a-f]{32})$`)
+	b3SpanID       = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// traceParent returns the W3C trace context of an event's request, so that
+// its build joins the trace of the proxies and gateways that forwarded it.
+// It is read from the traceparent header or, failing that, from the Zipkin
+// X-B3-* headers. Requests without a valid trace context start a new trace.
+func traceParent(h http.Header) string {
+	if m := w3cTraceParent.FindStringSubmatch(strings.ToLower(h.Get("traceparent"))); m != nil {
+		valid := m[1] != "ff" && m[2] != strings.Repeat("0", 32) && m[3] != strings.Repeat("0", 16)
+		if valid {
+			return "00-" + m[2] + "-" + m[3] + "-" + m[4]
+		}
+	}
+
+	traceID := strings.ToLower(h.Get("X-B3-TraceId"))
+	spanID := strings.ToLower(h.Get("X-B3-SpanId"))
+	if b3TraceID.MatchString(traceID) && b3SpanID.MatchString(spanID) {
+		flags := "00"
+		if h.Get("X-B3-Sampled") == "1" || h.Get("X-B3-Sampled") == "true" || h.Get("X-B3-Flags") == "1" {
+			flags = "01"
+		}
+		// 64-bit trace IDs are left-padded to 128 bits.
+		traceID = strings.Repeat("0", 32-len(traceID)) + traceID
+		return "00-" + traceID + "-" + spanID + "-" + flags
+	}
+
+	return newTraceParent()
+}
+
+// newTraceParent returns the trace context of a new, sampled trace.
+func newTraceParent() string {
+	ids := make([]byte, 24)
+	if _, err := rand.Read(ids); err != nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(ids[:16]) + "-" + hex.EncodeToString(ids[16:]) + "-01"
+}
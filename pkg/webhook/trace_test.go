@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "W3C",
+			headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			want:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:    "later W3C version",
+			headers: map[string]string{"traceparent": "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"},
+			want:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		},
+		{
+			name: "Zipkin",
+			headers: map[string]string{
+				"X-B3-TraceId": "80f198ee56343ba864fe8b2a57d3eff7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+				"X-B3-Sampled": "1",
+			},
+			want: "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01",
+		},
+		{
+			name: "Zipkin 64-bit trace ID",
+			headers: map[string]string{
+				"X-B3-TraceId": "64fe8b2a57d3eff7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+			},
+			want: "00-000000000000000064fe8b2a57d3eff7-e457b5a2e4d86bd1-00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			if got := traceParent(h); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTraceParentNewTrace(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	got := traceParent(h)
+	if !w3cTraceParent.MatchString(got) || got == traceParent(h) {
+		t.Errorf("expected a new trace for an invalid trace context, got %q", got)
+	}
+}